@@ -0,0 +1,111 @@
+package goose
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+
+	"github.com/SergeiSkv/goose/v3/internal/dialect"
+)
+
+// Baseline marks all migrations up to, and including, version as applied
+// without running them. It is meant for adopting goose on a database whose
+// schema was built by other means.
+func Baseline(db DB, dir string, version int64, opts ...OptionsFunc) error {
+	return BaselineContext(context.Background(), db, dir, version, opts...)
+}
+
+// BaselineContext marks all migrations up to, and including, version as
+// applied without running them.
+func BaselineContext(ctx context.Context, db DB, dir string, version int64, opts ...OptionsFunc) error {
+	p, err := newGlobalProvider(db, dir)
+	if err != nil {
+		return err
+	}
+	return p.Baseline(ctx, version, opts...)
+}
+
+// Baseline marks all migrations up to, and including, version as applied
+// without running them. The version table is created if needed, see
+// EnsureDBVersion. Baseline refuses to run if a migration has already been
+// applied, and version must be the version of a migration.
+func (p *Provider) Baseline(ctx context.Context, version int64, opts ...OptionsFunc) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	option := p.applyOptions(opts)
+	if option.noVersioning {
+		return errors.New("baseline requires versioning")
+	}
+	return p.traceCommand(ctx, "baseline", option, func(ctx context.Context) error {
+		return p.withLock(ctx, option, func(ctx context.Context) error {
+			return p.baseline(ctx, version, option)
+		})
+	})
+}
+
+func (p *Provider) baseline(ctx context.Context, version int64, option *options) error {
+	migrations, err := p.collectMigrations(minVersion, version)
+	if err != nil {
+		return fmt.Errorf("failed to collect migrations: %w", err)
+	}
+	if _, err := migrations.Current(version); err != nil {
+		return fmt.Errorf("no migration %d", version)
+	}
+	current, err := p.ensureDBVersion(ctx, option)
+	if err != nil && !errors.Is(err, ErrNoNextVersion) {
+		return err
+	}
+	if current > 0 {
+		return fmt.Errorf("version table already has applied migrations (current version %d), baseline only applies to new databases", current)
+	}
+
+	checksums := make([]string, len(migrations))
+	for i, m := range migrations {
+		if migrationType(m) != TypeSQL {
+			continue
+		}
+		data, err := fs.ReadFile(p.fsys, m.Source)
+		if err != nil {
+			return fmt.Errorf("failed to read SQL migration file %q: %w", filepath.Base(m.Source), err)
+		}
+		checksums[i] = checksum(data)
+	}
+
+	if option.dryRun {
+		var statements []string
+		for i, m := range migrations {
+			p.log(ctx, LevelInfo, "baseline planned", "PLAN baseline %[2]s\n",
+				Field{Key: "version", Value: m.Version}, Field{Key: "file", Value: filepath.Base(m.Source)})
+			statements = append(statements, p.store.InsertVersionSQL(m.Version, checksums[i]))
+			option.dryRunVersions = append(
+				[]*dialect.ListMigrationsResult{{VersionID: m.Version, IsApplied: true, Checksum: checksums[i]}},
+				option.dryRunVersions...,
+			)
+		}
+		if option.script != nil {
+			header := fmt.Sprintf("-- +goose baseline %d", version)
+			return p.writeScript(option, header, statements, true)
+		}
+		return nil
+	}
+
+	// All or nothing, a partial baseline would look like applied migrations.
+	tx, err := p.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	for i, m := range migrations {
+		if err := p.store.InsertVersion(ctx, tx, m.Version, checksums[i]); err != nil {
+			_ = rollback(tx)
+			return fmt.Errorf("failed to insert version %d: %w", m.Version, err)
+		}
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	p.log(ctx, LevelInfo, "baseline recorded", "goose: baseline at version %d, %d migrations marked as applied\n",
+		Field{Key: "version", Value: version}, Field{Key: "migrations", Value: len(migrations)})
+	return nil
+}
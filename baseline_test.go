@@ -0,0 +1,59 @@
+package goose
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"testing/fstest"
+
+	"github.com/SergeiSkv/goose/v3/internal/check"
+	"github.com/SergeiSkv/goose/v3/internal/dialect"
+)
+
+func TestBaseline(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	fsys := fstest.MapFS{
+		"00001_a.sql": {Data: []byte("-- +goose Up\nCREATE TABLE a (id int);\n")},
+		"00002_b.sql": {Data: []byte("-- +goose Up\nCREATE TABLE b (id int);\n")},
+		"00004_d.sql": {Data: []byte("-- +goose Up\nCREATE TABLE d (id int);\n")},
+	}
+	newProvider := func(t *testing.T, versions []*dialect.ListMigrationsResult) *Provider {
+		t.Helper()
+		return newTestProvider(t, DialectPostgres, fsys, versions, WithGoMigrations(NewGoMigration("00003_c.go", nil, nil)))
+	}
+
+	t.Run("new database", func(t *testing.T) {
+		p := newProvider(t, nil)
+		var buf bytes.Buffer
+		err := p.Baseline(ctx, 3, WithSQLScript(&buf))
+		check.NoError(t, err)
+		check.Contains(t, buf.String(), `-- +goose baseline 3
+BEGIN;
+INSERT INTO goose_db_version (version_id, is_applied, checksum) VALUES (1, true, '`+checksum(fsys["00001_a.sql"].Data)+`');
+INSERT INTO goose_db_version (version_id, is_applied, checksum) VALUES (2, true, '`+checksum(fsys["00002_b.sql"].Data)+`');
+INSERT INTO goose_db_version (version_id, is_applied, checksum) VALUES (3, true, NULL);
+COMMIT;
+`)
+	})
+	t.Run("empty version table", func(t *testing.T) {
+		p := newProvider(t, []*dialect.ListMigrationsResult{{VersionID: 0, IsApplied: true}})
+		check.NoError(t, p.Baseline(ctx, 2, WithDryRun()))
+	})
+	t.Run("applied migrations", func(t *testing.T) {
+		p := newProvider(t, []*dialect.ListMigrationsResult{
+			{VersionID: 1, IsApplied: true},
+			{VersionID: 0, IsApplied: true},
+		})
+		err := p.Baseline(ctx, 2, WithDryRun())
+		check.HasError(t, err)
+		check.Contains(t, err.Error(), "already has applied migrations")
+	})
+	t.Run("unknown version", func(t *testing.T) {
+		p := newProvider(t, nil)
+		err := p.Baseline(ctx, 5, WithDryRun())
+		check.HasError(t, err)
+		check.Contains(t, err.Error(), "no migration 5")
+	})
+}
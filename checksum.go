@@ -0,0 +1,169 @@
+package goose
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+
+	"github.com/SergeiSkv/goose/v3/internal/dialect"
+)
+
+// ErrChecksumMismatch is returned when applied migrations were modified after
+// they were applied, see Verify and WithStrictChecksums.
+var ErrChecksumMismatch = errors.New("applied migrations were modified")
+
+// ChecksumMismatch is an applied SQL migration whose file changed after it was
+// applied.
+type ChecksumMismatch struct {
+	Version int64
+	Source  string
+	// AppliedChecksum is the checksum recorded when the migration was applied.
+	AppliedChecksum string
+	// Checksum is the checksum of the current file.
+	Checksum string
+}
+
+// WithStrictChecksums makes up commands fail with ErrChecksumMismatch, instead
+// of logging a warning, when applied migrations were modified.
+func WithStrictChecksums() OptionsFunc {
+	return func(o *options) { o.strictChecksums = true }
+}
+
+// checksum returns the checksum recorded for the contents of a SQL migration.
+func checksum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// Verify checks that applied SQL migrations were not modified since they
+// were applied, see Provider.Verify.
+func Verify(db DB, dir string, opts ...OptionsFunc) error {
+	return VerifyContext(context.Background(), db, dir, opts...)
+}
+
+// VerifyContext checks that applied SQL migrations were not modified since
+// they were applied.
+func VerifyContext(ctx context.Context, db DB, dir string, opts ...OptionsFunc) error {
+	p, err := newGlobalProvider(db, dir)
+	if err != nil {
+		return err
+	}
+	mismatches, err := p.Verify(ctx, opts...)
+	if err != nil {
+		return err
+	}
+	if len(mismatches) > 0 {
+		return fmt.Errorf("%w: %d migrations", ErrChecksumMismatch, len(mismatches))
+	}
+	p.log(ctx, LevelInfo, "checksums verified", "goose: applied migrations match their checksums\n")
+	return nil
+}
+
+// Verify returns the applied SQL migrations that were modified since they were
+// applied, each of them is logged as well. Migrations applied without a
+// checksum, before goose recorded them, are skipped.
+//
+// The checksum is that of the migration file: for .sql.tmpl migrations, it is
+// the template, so changes of the SQL it renders to that come from the
+// template data or the environment are not detected.
+//
+// Verify only reads the database: without a version table nothing is applied
+// and there is nothing to verify, and the table is not upgraded either.
+func (p *Provider) Verify(ctx context.Context, opts ...OptionsFunc) ([]*ChecksumMismatch, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	option := p.applyOptions(opts)
+	var mismatches []*ChecksumMismatch
+	err := p.traceCommand(ctx, "verify", option, func(ctx context.Context) error {
+		migrations, err := p.collectMigrations(minVersion, maxVersion)
+		if err != nil {
+			return err
+		}
+		exists, err := p.checkVersionTable(ctx)
+		if err != nil || !exists {
+			return err
+		}
+		dbMigrations, err := p.store.ListMigrations(ctx, p.db)
+		if err != nil {
+			return err
+		}
+		mismatches, err = p.findChecksumMismatches(ctx, migrations, dbMigrations)
+		return err
+	})
+	return mismatches, err
+}
+
+// checkChecksums logs the applied migrations that were modified, and fails in
+// strict mode.
+func (p *Provider) checkChecksums(ctx context.Context, migrations Migrations, option *options) error {
+	dbMigrations, err := p.listMigrations(ctx, option)
+	if err != nil {
+		return err
+	}
+	mismatches, err := p.findChecksumMismatches(ctx, migrations, dbMigrations)
+	if err != nil {
+		return err
+	}
+	if len(mismatches) > 0 && option.strictChecksums {
+		return fmt.Errorf("%w: %d migrations", ErrChecksumMismatch, len(mismatches))
+	}
+	return nil
+}
+
+// findChecksumMismatches returns the migrations whose checksum differs from
+// the one recorded in dbMigrations, the rows of the version table.
+func (p *Provider) findChecksumMismatches(ctx context.Context, migrations Migrations, dbMigrations []*dialect.ListMigrationsResult) ([]*ChecksumMismatch, error) {
+	// The most recent record for each migration specifies whether it has been
+	// applied or rolled back.
+	seen := make(map[int64]bool)
+	applied := make(map[int64]string)
+	for _, m := range dbMigrations {
+		if seen[m.VersionID] {
+			continue
+		}
+		seen[m.VersionID] = true
+		if m.IsApplied && m.Checksum != "" {
+			applied[m.VersionID] = m.Checksum
+		}
+	}
+
+	var mismatches []*ChecksumMismatch
+	for _, m := range migrations {
+		want, ok := applied[m.Version]
+		if !ok || migrationType(m) != TypeSQL {
+			continue
+		}
+		data, err := fs.ReadFile(p.fsys, m.Source)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read SQL migration file %s: %w", m.Source, err)
+		}
+		if got := checksum(data); got != want {
+			p.log(ctx, LevelWarn, "migration modified after it was applied",
+				"goose: WARNING %[2]s was modified after it was applied (checksum %[3]s, applied %[4]s)\n",
+				Field{Key: "version", Value: m.Version},
+				Field{Key: "file", Value: filepath.Base(m.Source)},
+				Field{Key: "checksum", Value: shortChecksum(got)},
+				Field{Key: "applied_checksum", Value: shortChecksum(want)},
+			)
+			mismatches = append(mismatches, &ChecksumMismatch{
+				Version:         m.Version,
+				Source:          m.Source,
+				AppliedChecksum: want,
+				Checksum:        got,
+			})
+		}
+	}
+	return mismatches, nil
+}
+
+func shortChecksum(s string) string {
+	if len(s) > 12 {
+		return s[:12]
+	}
+	return s
+}
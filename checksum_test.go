@@ -0,0 +1,59 @@
+package goose
+
+import (
+	"context"
+	"testing"
+	"testing/fstest"
+
+	"github.com/SergeiSkv/goose/v3/internal/check"
+	"github.com/SergeiSkv/goose/v3/internal/dialect"
+)
+
+func TestChecksums(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	original := []byte("-- +goose Up\nCREATE TABLE a (id int);\n")
+	fsys := fstest.MapFS{
+		"00001_a.sql": {Data: []byte("-- +goose Up\nCREATE TABLE a (id int, name text);\n")},
+		"00002_b.sql": {Data: []byte("-- +goose Up\nCREATE TABLE b (id int);\n")},
+		"00003_c.sql": {Data: []byte("-- +goose Up\nCREATE TABLE c (id int);\n")},
+		"00004_d.sql": {Data: []byte("-- +goose Up\nCREATE TABLE d (id int);\n")},
+	}
+	p := newTestProvider(t, DialectPostgres, fsys, []*dialect.ListMigrationsResult{
+		// Applied before checksums were recorded.
+		{VersionID: 3, IsApplied: true},
+		{VersionID: 2, IsApplied: true, Checksum: checksum(fsys["00002_b.sql"].Data)},
+		// Modified after it was applied.
+		{VersionID: 1, IsApplied: true, Checksum: checksum(original)},
+		{VersionID: 0, IsApplied: true},
+	}, WithLocker(nil))
+
+	mismatches, err := p.Verify(ctx)
+	check.NoError(t, err)
+	check.Number(t, len(mismatches), 1)
+	check.Number(t, mismatches[0].Version, 1)
+	check.Equal(t, mismatches[0].AppliedChecksum, checksum(original))
+	check.Equal(t, mismatches[0].Checksum, checksum(fsys["00001_a.sql"].Data))
+
+	// Drift is only reported, unless strict.
+	results, err := p.Up(ctx, WithDryRun())
+	check.NoError(t, err)
+	check.Number(t, len(results), 1)
+	_, err = p.Up(ctx, WithDryRun(), WithStrictChecksums())
+	check.IsError(t, err, ErrChecksumMismatch)
+}
+
+func TestVerifyPristineDatabase(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	p, db := newSQLiteProvider(t, fstest.MapFS{
+		"00001_a.sql": {Data: []byte("-- +goose Up\nCREATE TABLE a (id INTEGER);\n")},
+	})
+	// Nothing is applied, and the version table is not created.
+	mismatches, err := p.Verify(ctx)
+	check.NoError(t, err)
+	check.Number(t, len(mismatches), 0)
+	check.Number(t, count(t, db, "SELECT COUNT(*) FROM sqlite_master WHERE name = 'goose_db_version'"), 0)
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+
+	"github.com/SergeiSkv/goose/v3/internal/cfg"
+)
+
+// setting is the effective value of a setting of the binary and where it
+// comes from: a flag, an environment variable, a configuration file or the
+// default.
+type setting struct {
+	key    string
+	value  string
+	source string
+}
+
+const (
+	sourceFlag    = "flag"
+	sourceDefault = "default"
+)
+
+var (
+	// settings are the effective settings, see loadConfig.
+	settings []setting
+	// configDriver and configDBString are the driver and dbstring set by
+	// environment variables or configuration files, if any.
+	configDriver, configDBString string
+)
+
+// loadConfig applies the environment variables and the configuration files to
+// the flags not set on the command line, and records where the effective
+// value of every setting comes from.
+func loadConfig() error {
+	explicit := make(map[string]bool)
+	flags.Visit(func(f *flag.Flag) { explicit[f.Name] = true })
+
+	// The environment and the configuration file can only be selected by
+	// flags and environment variables.
+	for _, key := range []string{cfg.KeyEnv, cfg.KeyConfig} {
+		if v := os.Getenv(cfg.EnvName(key)); v != "" && !explicit[key] {
+			if err := flags.Set(key, v); err != nil {
+				return err
+			}
+		}
+	}
+	c, err := cfg.Load(".", *configFile, *environment, os.LookupEnv)
+	if err != nil {
+		return err
+	}
+	known := map[string]bool{cfg.KeyDriver: true, cfg.KeyDBString: true}
+	flags.VisitAll(func(f *flag.Flag) {
+		if configurable(f.Name) {
+			known[cfg.FlagKey(f.Name)] = true
+		}
+	})
+	if err := c.CheckKeys(known); err != nil {
+		return err
+	}
+
+	settings = settings[:0]
+	for _, key := range []string{cfg.KeyDriver, cfg.KeyDBString} {
+		s := setting{key: key, source: sourceDefault}
+		if value, source, ok := c.Lookup(key); ok {
+			s.value, s.source = value, source
+		}
+		settings = append(settings, s)
+	}
+	configDriver, configDBString = settings[0].value, settings[1].value
+
+	flags.VisitAll(func(f *flag.Flag) {
+		if err != nil || f.Name == "h" || f.Name == "version" {
+			return
+		}
+		key := cfg.FlagKey(f.Name)
+		s := setting{key: key, source: sourceDefault}
+		switch {
+		case explicit[f.Name]:
+			s.source = sourceFlag
+		case key == cfg.KeyEnv || key == cfg.KeyConfig:
+			if os.Getenv(cfg.EnvName(key)) != "" {
+				s.source = cfg.EnvName(key)
+			}
+		default:
+			if value, source, ok := c.Lookup(key); ok {
+				if setErr := flags.Set(f.Name, value); setErr != nil {
+					err = fmt.Errorf("%s: invalid value %q for %s: %w", source, value, key, setErr)
+					return
+				}
+				s.source = source
+			}
+		}
+		s.value = f.Value.String()
+		settings = append(settings, s)
+	})
+	return err
+}
+
+// configurable reports whether the flag can be set by configuration files.
+func configurable(name string) bool {
+	switch name {
+	case "h", "version", cfg.KeyEnv, cfg.KeyConfig:
+		return false
+	}
+	return true
+}
+
+// printSettings prints the effective settings, as environment variables, and
+// where each value comes from.
+func printSettings(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, s := range settings {
+		fmt.Fprintf(tw, "%s=%q\t# %s\n", cfg.EnvName(s.key), s.value, s.source)
+	}
+	source := sourceDefault
+	if os.Getenv("NO_COLOR") != "" {
+		source = "NO_COLOR"
+	}
+	fmt.Fprintf(tw, "%s=%q\t# %s\n", "NO_COLOR", cfg.GOOSENOCOLOR, source)
+	return tw.Flush()
+}
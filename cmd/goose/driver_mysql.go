@@ -7,7 +7,6 @@ import (
 	"crypto/tls"
 	"crypto/x509"
 	"fmt"
-	"log"
 	"os"
 
 	"github.com/go-sql-driver/mysql"
@@ -18,25 +17,35 @@ import (
 // the parameter `parseTime` set to true. This allows internal goose logic
 // to assume that DATETIME/DATE/TIMESTAMP can be scanned into the time.Time
 // type.
-func normalizeDBString(driver string, str string, certfile string, sslcert string, sslkey string) string {
+func normalizeDBString(driver string, str string, certfile string, sslcert string, sslkey string) (string, error) {
 	if driver == "mysql" {
 		isTLS := certfile != ""
 		if isTLS {
 			if err := registerTLSConfig(certfile, sslcert, sslkey); err != nil {
-				log.Fatalf("goose run: %v", err)
+				return "", err
 			}
 		}
 		var err error
 		str, err = normalizeMySQLDSN(str, isTLS)
 		if err != nil {
-			log.Fatalf("failed to normalize MySQL connection string: %v", err)
+			return "", fmt.Errorf("failed to normalize MySQL connection string: %w", err)
 		}
 	}
-	return str
+	return str, nil
 }
 
 const tlsConfigKey = "custom"
 
+// mysqlDatabaseName names a database after the address and database of its
+// MySQL DSN.
+func mysqlDatabaseName(dsn string) (string, bool) {
+	config, err := mysql.ParseDSN(dsn)
+	if err != nil {
+		return "", false
+	}
+	return config.Addr + "/" + config.DBName, true
+}
+
 func normalizeMySQLDSN(dsn string, tls bool) (string, error) {
 	config, err := mysql.ParseDSN(dsn)
 	if err != nil {
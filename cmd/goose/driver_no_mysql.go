@@ -3,6 +3,10 @@
 
 package main
 
-func normalizeDBString(driver string, str string, certfile string, sslcert string, sslkey string) string {
-	return str
+func normalizeDBString(driver string, str string, certfile string, sslcert string, sslkey string) (string, error) {
+	return str, nil
+}
+
+func mysqlDatabaseName(dsn string) (string, bool) {
+	return "", false
 }
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/SergeiSkv/goose/v3"
+	"github.com/jackc/pgx/v5"
+)
+
+type fleetOutput struct {
+	Database string `json:"database" yaml:"database"`
+	Canary   bool   `json:"canary" yaml:"canary"`
+	Status   string `json:"status" yaml:"status"`
+	Version  int64  `json:"version" yaml:"version"`
+	Pending  int    `json:"pending" yaml:"pending"`
+	Applied  int    `json:"applied" yaml:"applied"`
+	Duration string `json:"duration" yaml:"duration"`
+	Error    string `json:"error,omitempty" yaml:"error,omitempty"`
+}
+
+// runFleet runs the fleet command, whose arguments are:
+//
+//	DRIVER COMMAND [VERSION] [DSN...]
+//
+// The DSNs are appended to those of the -dsn-file file.
+func runFleet(ctx context.Context, w io.Writer, args []string, dir string, options []goose.OptionsFunc) error {
+	if len(args) < 2 {
+		return errors.New("usage: goose fleet DRIVER COMMAND [VERSION] [DSN...]")
+	}
+	driver, command, args := normalizeDriver(args[0]), args[1], args[2:]
+	dialect, err := goose.ParseDialect(driver)
+	if err != nil {
+		return err
+	}
+	version := goose.MaxVersion
+	switch command {
+	case "up", "status":
+	case "up-to":
+		if len(args) == 0 {
+			return errors.New("up-to must be followed by a version")
+		}
+		v, err := strconv.ParseInt(args[0], 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid version %q: %w", args[0], err)
+		}
+		version, args = v, args[1:]
+	default:
+		return fmt.Errorf("%q: command is not supported, use up, up-to or status", command)
+	}
+	dsns, err := readDSNs(args)
+	if err != nil {
+		return err
+	}
+	if len(dsns) == 0 {
+		return errors.New("no databases, pass DSNs as arguments or with -dsn-file")
+	}
+	databases := make([]goose.FleetDatabase, 0, len(dsns))
+	seen := make(map[string]bool, len(dsns))
+	for i, dsn := range dsns {
+		normalized, err := normalizeDBString(driver, dsn, *certfile, *sslcert, *sslkey)
+		if err != nil {
+			return fmt.Errorf("database %d: %w", i+1, err)
+		}
+		connect, err := goose.NewConnectFunc(driver, normalized)
+		if err != nil {
+			return fmt.Errorf("database %d: %w", i+1, err)
+		}
+		name := databaseName(driver, dsn, i)
+		if seen[name] {
+			name = fmt.Sprintf("%s#%d", name, i+1)
+		}
+		seen[name] = true
+		databases = append(databases, goose.FleetDatabase{Name: name, Connect: connect})
+	}
+
+	opts := []goose.FleetOption{
+		goose.WithFleetConcurrency(*fleetConcurrency),
+		goose.WithCanary(*canary),
+		goose.WithFleetOptions(options...),
+		goose.WithFleetProviderOptions(goose.WithDialect(dialect)),
+	}
+	if *continueOnError {
+		opts = append(opts, goose.WithFleetContinueOnError())
+	}
+	var report *goose.FleetReport
+	if command == "status" {
+		report, err = goose.StatusFleetContext(ctx, databases, dir, opts...)
+	} else {
+		report, err = goose.UpToFleetContext(ctx, databases, dir, version, opts...)
+	}
+	if report == nil {
+		return err
+	}
+	if printErr := printFleetReport(w, *format, report); printErr != nil && err == nil {
+		err = printErr
+	}
+	return err
+}
+
+// readDSNs returns the DSNs of the -dsn-file file, "-" being stdin, followed
+// by dsns.
+func readDSNs(dsns []string) ([]string, error) {
+	if *dsnFile == "" {
+		return dsns, nil
+	}
+	var fromFile []string
+	var err error
+	if *dsnFile == "-" {
+		fromFile, err = goose.ReadDSNs(os.Stdin)
+	} else {
+		var f *os.File
+		if f, err = os.Open(*dsnFile); err != nil {
+			return nil, fmt.Errorf("failed to open DSN file: %w", err)
+		}
+		defer f.Close()
+		fromFile, err = goose.ReadDSNs(f)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return append(fromFile, dsns...), nil
+}
+
+// databaseName names the i-th database of the fleet after the host, port and
+// database of its DSN, or after the file of SQLite databases, leaving the
+// credentials and parameters out. DSNs are parsed in the format of their
+// driver; a DSN that cannot be is named after its position.
+func databaseName(driver, dsn string, i int) string {
+	switch driver {
+	case "sqlite":
+		if path := strings.TrimPrefix(strings.SplitN(dsn, "?", 2)[0], "file:"); path != "" {
+			return path
+		}
+	case "pgx", "redshift":
+		if config, err := pgx.ParseConfig(dsn); err == nil {
+			return fmt.Sprintf("%s:%d/%s", config.Host, config.Port, config.Database)
+		}
+	case "mysql", "tidb":
+		if name, ok := mysqlDatabaseName(dsn); ok {
+			return name
+		}
+	default:
+		if name, ok := urlDatabaseName(dsn); ok {
+			return name
+		}
+	}
+	return fmt.Sprintf("database-%d", i+1)
+}
+
+// urlDatabaseName names a database after the host and database of a URL DSN,
+// such as the sqlserver://, clickhouse:// and vertica:// ones. The database is
+// either the path of the URL or its database parameter.
+func urlDatabaseName(dsn string) (string, bool) {
+	u, err := url.Parse(dsn)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return "", false
+	}
+	database := strings.TrimPrefix(u.Path, "/")
+	if database == "" {
+		database = u.Query().Get("database")
+	}
+	return u.Host + "/" + database, true
+}
+
+func printFleetReport(w io.Writer, format string, report *goose.FleetReport) error {
+	out := make([]fleetOutput, 0, len(report.Databases))
+	for _, d := range report.Databases {
+		status, errMsg := outcome(d.Skipped, d.Error)
+		out = append(out, fleetOutput{
+			Database: d.Database,
+			Canary:   d.Canary,
+			Status:   status,
+			Version:  d.Version,
+			Pending:  d.Pending,
+			Applied:  countApplied(d.Results),
+			Duration: d.Duration.String(),
+			Error:    errMsg,
+		})
+	}
+	if format != formatTable {
+		return encode(w, format, out)
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 3, ' ', 0)
+	fmtPattern := "%v\t%v\t%v\t%v\t%v\t%v\t%v\n"
+	fmt.Fprintf(tw, fmtPattern, "Database", "Status", "Version", "Pending", "Applied", "Duration", "Error")
+	fmt.Fprintf(tw, fmtPattern, "────────", "──────", "───────", "───────", "───────", "────────", "─────")
+	for _, o := range out {
+		database := o.Database
+		if o.Canary {
+			database += " (canary)"
+		}
+		fmt.Fprintf(tw, fmtPattern, database, o.Status, formatVersion(o.Version), o.Pending, o.Applied, o.Duration, o.Error)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	printVersionSummary(w, report.ByVersion(), len(report.Failed()), len(report.Skipped()), "database(s)")
+	return nil
+}
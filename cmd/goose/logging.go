@@ -0,0 +1,147 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/SergeiSkv/goose/v3"
+)
+
+const (
+	logFormatText  = "text"
+	logFormatJSON  = "json"
+	logFormatPlain = "plain"
+)
+
+// Exit codes of the goose binary, by class of failure.
+const (
+	exitFailure    = 1 // any other failure, including missing arguments
+	exitUsage      = 2 // invalid flags
+	exitConnection = 3 // the database could not be reached, or the connection was lost
+	exitParse      = 4 // a migration could not be collected, read, rendered or parsed
+	exitExecution  = 5 // a migration failed to run
+	exitLock       = 6 // the migration lock could not be acquired
+)
+
+// exitCode returns the exit code for err, see the exit codes above.
+func exitCode(err error) int {
+	switch {
+	case isConnectionError(err):
+		return exitConnection
+	case errors.Is(err, goose.ErrLock):
+		return exitLock
+	case errors.Is(err, goose.ErrParse):
+		return exitParse
+	case errors.Is(err, goose.ErrExecution):
+		return exitExecution
+	}
+	return exitFailure
+}
+
+// isConnectionError reports whether err comes from connecting to the
+// database or from the connection failing, as opposed to the database
+// rejecting a statement.
+func isConnectionError(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr) ||
+		errors.Is(err, driver.ErrBadConn) ||
+		errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// cliLogger is the structured logger of the -log-format=json mode, nil
+// otherwise.
+var cliLogger *jsonLogger
+
+// setupLogging switches the output of the binary, and of goose, to format.
+func setupLogging(format string) error {
+	switch format {
+	case logFormatText:
+	case logFormatPlain:
+		log.SetFlags(0)
+	case logFormatJSON:
+		cliLogger = &jsonLogger{w: os.Stderr}
+		goose.SetStructuredLogger(cliLogger)
+		// Lines printed through the log package, by goose or by the binary,
+		// become events too.
+		log.SetFlags(0)
+		log.SetOutput(cliLogger)
+	default:
+		return fmt.Errorf("unknown log format %q: must be one of %s, %s or %s",
+			format, logFormatText, logFormatJSON, logFormatPlain)
+	}
+	return nil
+}
+
+// fail reports msg and err, and returns code for the binary to exit with.
+func fail(code int, msg string, err error) int {
+	if cliLogger != nil {
+		cliLogger.Log(context.Background(), goose.LevelError, msg,
+			goose.Field{Key: "error", Value: err},
+			goose.Field{Key: "exit_code", Value: code},
+		)
+	} else {
+		log.Printf("%s: %v", msg, err)
+	}
+	return code
+}
+
+// jsonLogger writes events as line-delimited JSON objects with the time,
+// level and msg keys, followed by their fields.
+type jsonLogger struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+var _ goose.StructuredLogger = (*jsonLogger)(nil)
+
+func (l *jsonLogger) Log(_ context.Context, level goose.Level, msg string, fields ...goose.Field) {
+	var buf bytes.Buffer
+	buf.WriteString(`{"time":`)
+	writeJSON(&buf, time.Now().Format(time.RFC3339Nano))
+	buf.WriteString(`,"level":`)
+	writeJSON(&buf, level.String())
+	buf.WriteString(`,"msg":`)
+	writeJSON(&buf, msg)
+	for _, f := range fields {
+		buf.WriteByte(',')
+		writeJSON(&buf, f.Key)
+		buf.WriteByte(':')
+		switch v := f.Value.(type) {
+		case error:
+			writeJSON(&buf, v.Error())
+		case time.Duration:
+			writeJSON(&buf, v.String())
+		default:
+			writeJSON(&buf, v)
+		}
+	}
+	buf.WriteString("}\n")
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	_, _ = l.w.Write(buf.Bytes())
+}
+
+// Write reports a line printed through the log package as an info event.
+func (l *jsonLogger) Write(p []byte) (int, error) {
+	l.Log(context.Background(), goose.LevelInfo, string(bytes.TrimRight(p, "\n")))
+	return len(p), nil
+}
+
+func writeJSON(buf *bytes.Buffer, v interface{}) {
+	by, err := json.Marshal(v)
+	if err != nil {
+		by, _ = json.Marshal(fmt.Sprint(v))
+	}
+	buf.Write(by)
+}
@@ -2,17 +2,18 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"flag"
 	"fmt"
 	"io/fs"
-	"log"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"runtime/debug"
 	"sort"
 	"strconv"
-	"strings"
+	"syscall"
 	"text/tabwriter"
 	"text/template"
 
@@ -23,29 +24,58 @@ import (
 )
 
 var (
-	flags        = flag.NewFlagSet("goose", flag.ExitOnError)
-	dir          = flags.String("dir", cfg.DefaultMigrationDir, "directory with migration files")
-	table        = flags.String("table", "goose_db_version", "migrations table name")
-	verbose      = flags.Bool("v", false, "enable verbose mode")
-	help         = flags.Bool("h", false, "print help")
-	version      = flags.Bool("version", false, "print version")
-	certfile     = flags.String("certfile", "", "file path to root CA's certificates in pem format (only support on mysql)")
-	sequential   = flags.Bool("s", false, "use sequential numbering for new migrations")
-	allowMissing = flags.Bool("allow-missing", false, "applies missing (out-of-order) migrations")
-	sslcert      = flags.String("ssl-cert", "", "file path to SSL certificates in pem format (only support on mysql)")
-	sslkey       = flags.String("ssl-key", "", "file path to SSL key in pem format (only support on mysql)")
-	noVersioning = flags.Bool("no-versioning", false, "apply migration commands with no versioning, in file order, from directory pointed to")
-	noColor      = flags.Bool("no-color", false, "disable color output (NO_COLOR env variable supported)")
+	flags             = flag.NewFlagSet("goose", flag.ExitOnError)
+	dir               = flags.String("dir", cfg.DefaultMigrationDir, "directory with migration files")
+	table             = flags.String("table", "goose_db_version", "migrations table name")
+	verbose           = flags.Bool("v", false, "enable verbose mode")
+	help              = flags.Bool("h", false, "print help")
+	version           = flags.Bool("version", false, "print version")
+	certfile          = flags.String("certfile", "", "file path to root CA's certificates in pem format (only support on mysql)")
+	sequential        = flags.Bool("s", false, "use sequential numbering for new migrations")
+	allowMissing      = flags.Bool("allow-missing", false, "applies missing (out-of-order) migrations")
+	sslcert           = flags.String("ssl-cert", "", "file path to SSL certificates in pem format (only support on mysql)")
+	sslkey            = flags.String("ssl-key", "", "file path to SSL key in pem format (only support on mysql)")
+	noVersioning      = flags.Bool("no-versioning", false, "apply migration commands with no versioning, in file order, from directory pointed to")
+	noColor           = flags.Bool("no-color", false, "disable color output (NO_COLOR env variable supported)")
+	format            = flags.String("format", formatTable, "output format of the status, version and validate commands, and of tenant and fleet reports: table, json or yaml")
+	noLock            = flags.Bool("no-lock", false, "do not acquire the migration lock before modifying the database")
+	lockTimeout       = flags.Duration("lock-timeout", 0, "how long to wait for the migration lock, 0 waits indefinitely")
+	dryRun            = flags.Bool("dry-run", false, "print what the up, up-by-one, up-to, down, down-to, redo, reset, baseline, mark-applied, mark-pending and repair commands would do, without doing it")
+	strictChecksums   = flags.Bool("strict-checksums", false, "refuse to migrate up when applied migrations were modified")
+	lockTable         = flags.Bool("lock-table", false, "hold the migration lock as a row of the goose_lock table, for databases without advisory locks other than ClickHouse")
+	templateData      = flags.String("template-data", "", "JSON file with the data .sql.tmpl migrations are rendered with")
+	logFormat         = flags.String("log-format", logFormatText, "format of the log output: text, json (line-delimited JSON events) or plain (text without timestamps)")
+	tenantsFile       = flags.String("tenants-file", "", "file listing the tenant schemas to run the up or version command for, one per line, - for stdin (postgres only)")
+	tenantsQuery      = flags.String("tenants-query", "", "SQL query listing the tenant schemas to run the up or version command for in its first column (postgres only)")
+	tenantConcurrency = flags.Int("tenant-concurrency", 1, "how many tenant schemas are migrated at the same time")
+	continueOnError   = flags.Bool("continue-on-error", false, "keep migrating the remaining tenant schemas, or fleet databases, after one failed")
+	dsnFile           = flags.String("dsn-file", "", "file listing the databases of the fleet command, one DSN per line, - for stdin")
+	fleetConcurrency  = flags.Int("fleet-concurrency", 1, "how many databases the fleet command migrates at the same time")
+	canary            = flags.Int("canary", 0, "number of databases the fleet command migrates first, the others being started once they all succeeded")
+	environment       = flags.String("env", "", "named environment of the configuration files to use, such as staging")
+	configFile        = flags.String("config", "", "configuration file, defaults to the first of goose.yaml, goose.yml and goose.toml found in the working directory")
 )
 var (
 	gooseVersion = ""
 )
 
 func main() {
+	os.Exit(run())
+}
+
+// run runs the binary and returns its exit code. It does not exit itself, so
+// that deferred calls, such as closing the database, run on failures too.
+func run() (code int) {
 	flags.Usage = usage
 	if err := flags.Parse(os.Args[1:]); err != nil {
-		log.Fatalf("failed to parse args: %v", err)
-		return
+		return fail(exitUsage, "failed to parse args", err)
+	}
+	if err := loadConfig(); err != nil {
+		return fail(exitUsage, "goose: failed to load configuration", err)
+	}
+
+	if err := setupLogging(*logFormat); err != nil {
+		return fail(exitUsage, "goose", err)
 	}
 
 	if *version {
@@ -53,7 +83,10 @@ func main() {
 			gooseVersion = buildInfo.Main.Version
 		}
 		fmt.Printf("goose version:%s\n", gooseVersion)
-		return
+		return 0
+	}
+	if err := checkFormat(*format); err != nil {
+		return fail(exitUsage, "goose", err)
 	}
 	if *verbose {
 		goose.SetVerbose(true)
@@ -62,77 +95,100 @@ func main() {
 		goose.SetSequential(true)
 	}
 	goose.SetTableName(*table)
+	goose.SetLockTimeout(*lockTimeout)
+	switch {
+	case *noLock:
+		goose.SetLocker(nil)
+	case *lockTable:
+		goose.SetTableLocker(goose.DefaultLockLease)
+	}
+	data, err := readTemplateData(*templateData)
+	if err != nil {
+		return fail(exitFailure, "goose", err)
+	}
+	goose.SetTemplateData(data)
 
 	args := flags.Args()
 
 	if *help {
 		flags.Usage()
-		return
+		return 0
 	}
 
 	if len(args) == 0 {
 		flags.Usage()
-		os.Exit(1)
-	}
-
-	// The -dir option has not been set, check whether the env variable is set
-	// before defaulting to ".".
-	if *dir == cfg.DefaultMigrationDir && cfg.GOOSEMIGRATIONDIR != "" {
-		*dir = cfg.GOOSEMIGRATIONDIR
+		return exitFailure
 	}
 
 	switch args[0] {
 	case "init":
 		if err := gooseInit(*dir); err != nil {
-			log.Fatalf("goose run: %v", err)
+			return fail(exitCode(err), "goose run", err)
 		}
-		return
+		return 0
 	case "create":
 		if err := goose.Run("create", nil, *dir, args[1:]...); err != nil {
-			log.Fatalf("goose run: %v", err)
+			return fail(exitCode(err), "goose run", err)
 		}
-		return
+		return 0
 	case "fix":
 		if err := goose.Run("fix", nil, *dir); err != nil {
-			log.Fatalf("goose run: %v", err)
+			return fail(exitCode(err), "goose run", err)
 		}
-		return
+		return 0
 	case "env":
-		for _, env := range cfg.List() {
-			fmt.Printf("%s=%q\n", env.Name, env.Value)
+		if err := printSettings(os.Stdout); err != nil {
+			return fail(exitFailure, "goose env", err)
 		}
-		return
+		return 0
 	case "validate":
-		if err := printValidate(*dir, *verbose); err != nil {
-			log.Fatalf("goose validate: %v", err)
+		if err := printValidate(*dir, *verbose, *format, data); err != nil {
+			return fail(exitParse, "goose validate", err)
 		}
-		return
+		return 0
+	case "fleet":
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		if err := runFleet(ctx, os.Stdout, args[1:], *dir, commandOptions()); err != nil {
+			return fail(exitCode(err), "goose fleet", err)
+		}
+		return 0
 	}
 
 	args = mergeArgs(args)
 	if len(args) < 3 {
 		flags.Usage()
-		os.Exit(1)
+		return exitFailure
 	}
 
-	driver, dbstring, command := args[0], args[1], args[2]
-	// To avoid breaking existing consumers. An implementation detail
-	// that consumers should not care which underlying driver is used.
-	switch driver {
-	case "sqlite3":
-		//  Internally uses the CGo-free port of SQLite: modernc.org/sqlite
-		driver = "sqlite"
-	case "postgres":
-		driver = "pgx"
+	driver, dbstring, command := normalizeDriver(args[0]), args[1], args[2]
+	// Cancel the running command on SIGINT or SIGTERM, so an in-flight
+	// migration transaction is rolled back instead of being cut off.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	dsn, err := normalizeDBString(driver, dbstring, *certfile, *sslcert, *sslkey)
+	if err != nil {
+		return fail(exitConnection, fmt.Sprintf("-dbstring=%q", dbstring), err)
+	}
+	connect, err := goose.NewConnectFunc(driver, dsn)
+	if err != nil {
+		return fail(exitConnection, fmt.Sprintf("-dbstring=%q", dbstring), err)
+	}
+	if err := goose.SetDialect(driver); err != nil {
+		return fail(exitConnection, fmt.Sprintf("-dbstring=%q", dbstring), err)
 	}
-	db, err := goose.OpenDBWithDriver(driver, normalizeDBString(driver, dbstring, *certfile, *sslcert, *sslkey))
+	db, err := connect(ctx)
 	if err != nil {
-		log.Fatalf("-dbstring=%q: %v\n", dbstring, err)
+		return fail(exitConnection, fmt.Sprintf("-dbstring=%q", dbstring), err)
 	}
 
 	defer func() {
-		if err = db.Close(context.Background()); err != nil {
-			log.Fatalf("goose: failed to close DB: %v\n", err)
+		if err := db.Close(context.Background()); err != nil {
+			closeCode := fail(exitConnection, "goose: failed to close DB", err)
+			if code == 0 {
+				code = closeCode
+			}
 		}
 	}()
 
@@ -140,6 +196,52 @@ func main() {
 	if len(args) > 3 {
 		arguments = append(arguments, args[3:]...)
 	}
+	options := commandOptions()
+	if tenantMode() {
+		tenants, err := readTenants(ctx, db)
+		if err != nil {
+			return fail(exitFailure, "goose run", err)
+		}
+		if err := runTenants(ctx, os.Stdout, command, connect, tenants, *dir, options); err != nil {
+			return fail(exitCode(err), "goose run", err)
+		}
+		return 0
+	}
+	if *format != formatTable && hasFormattedOutput(command) {
+		if err := printFormatted(ctx, os.Stdout, *format, command, db, *dir, options); err != nil {
+			return fail(exitCode(err), "goose run", err)
+		}
+		return 0
+	}
+	if err := goose.RunWithOptionsContext(
+		ctx,
+		command,
+		db,
+		*dir,
+		arguments,
+		options...,
+	); err != nil {
+		return fail(exitCode(err), "goose run", err)
+	}
+	return 0
+}
+
+// normalizeDriver maps the driver names of the binary to those of goose.
+func normalizeDriver(driver string) string {
+	// To avoid breaking existing consumers. An implementation detail
+	// that consumers should not care which underlying driver is used.
+	switch driver {
+	case "sqlite3":
+		//  Internally uses the CGo-free port of SQLite: modernc.org/sqlite
+		return "sqlite"
+	case "postgres":
+		return "pgx"
+	}
+	return driver
+}
+
+// commandOptions returns the options of the migration commands set by flags.
+func commandOptions() []goose.OptionsFunc {
 	options := []goose.OptionsFunc{}
 	if *noColor || checkNoColorFromEnv() {
 		options = append(options, goose.WithNoColor(true))
@@ -150,15 +252,13 @@ func main() {
 	if *noVersioning {
 		options = append(options, goose.WithNoVersioning())
 	}
-	if err := goose.RunWithOptions(
-		command,
-		db,
-		*dir,
-		arguments,
-		options...,
-	); err != nil {
-		log.Fatalf("goose run: %v", err)
+	if *dryRun {
+		options = append(options, goose.WithDryRun())
 	}
+	if *strictChecksums {
+		options = append(options, goose.WithStrictChecksums())
+	}
+	return options
 }
 
 func checkNoColorFromEnv() bool {
@@ -170,10 +270,10 @@ func mergeArgs(args []string) []string {
 	if len(args) < 1 {
 		return args
 	}
-	if s := cfg.GOOSEDRIVER; s != "" {
+	if s := configDriver; s != "" {
 		args = append([]string{s}, args...)
 	}
-	if s := cfg.GOOSEDBSTRING; s != "" {
+	if s := configDBString; s != "" {
 		args = append([]string{args[0], s}, args[1:]...)
 	}
 	return args
@@ -239,10 +339,30 @@ Commands:
     down-to VERSION      Roll back to a specific VERSION
     redo                 Re-run the latest migration
     reset                Roll back all migrations
+    baseline VERSION     Mark migrations up to VERSION as applied without running them
+    mark-applied VERSION Record VERSION as applied without running it
+    mark-pending VERSION Record VERSION as rolled back without running it
+    repair               Remove duplicate version table rows and report versions without a migration file
     status               Dump the migration status for the current DB
     version              Print the current version of the database
+    validate             Check migration files without running them
+    verify               Check that applied migrations were not modified
+    sql COMMAND          Print the SQL of a migration command, such as up or up-to VERSION, instead of running it
+    unlock               Release the goose_lock table lock held by a crashed process
+    env                  Print the effective settings and where each value comes from
+    fleet DRIVER COMMAND [VERSION] [DSN...]
+                         Run up, up-to VERSION or status against several databases, see -dsn-file
     create NAME [sql|go] Creates new migration file with the current timestamp
     fix                  Apply sequential ordering to migrations
+
+Exit codes:
+    0                    Success
+    1                    Any other failure, including missing arguments
+    2                    Invalid flags
+    3                    The database could not be reached, or the connection was lost
+    4                    A migration could not be collected, read, rendered or parsed
+    5                    A migration failed to run
+    6                    The migration lock could not be acquired
 `
 )
 
@@ -299,7 +419,7 @@ func gatherFilenames(filename string) ([]string, error) {
 	}
 	var filenames []string
 	if stat.IsDir() {
-		for _, pattern := range []string{"*.sql", "*.go"} {
+		for _, pattern := range []string{"*.sql", "*.sql.tmpl", "*.go"} {
 			file, err := filepath.Glob(filepath.Join(filename, pattern))
 			if err != nil {
 				return nil, err
@@ -313,16 +433,19 @@ func gatherFilenames(filename string) ([]string, error) {
 	return filenames, nil
 }
 
-func printValidate(filename string, verbose bool) error {
+func printValidate(filename string, verbose bool, format string, templateData map[string]interface{}) error {
 	filenames, err := gatherFilenames(filename)
 	if err != nil {
 		return err
 	}
 	fileWalker := migrationstatsos.NewFileWalker(filenames...)
-	stats, err := migrationstats.GatherStats(fileWalker, false)
+	stats, err := migrationstats.GatherStats(fileWalker, false, migrationstats.WithTemplate(templateData, nil))
 	if err != nil {
 		return err
 	}
+	if format != formatTable {
+		return printValidateFormatted(os.Stdout, format, stats)
+	}
 	// TODO(mf): we should introduce a --debug flag, which allows printing
 	// more internal debug information and leave verbose for additional information.
 	if !verbose {
@@ -338,7 +461,7 @@ func printValidate(filename string, verbose bool) error {
 			txnStr = "✘"
 		}
 		fmt.Fprintf(w, fmtPattern,
-			strings.TrimPrefix(filepath.Ext(m.FileName), "."),
+			m.Type,
 			txnStr,
 			m.UpCount,
 			m.DownCount,
@@ -347,3 +470,19 @@ func printValidate(filename string, verbose bool) error {
 	}
 	return w.Flush()
 }
+
+// readTemplateData reads the -template-data file, if any.
+func readTemplateData(filename string) (map[string]interface{}, error) {
+	if filename == "" {
+		return nil, nil
+	}
+	by, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read template data: %w", err)
+	}
+	var data map[string]interface{}
+	if err := json.Unmarshal(by, &data); err != nil {
+		return nil, fmt.Errorf("failed to parse template data %s: %w", filename, err)
+	}
+	return data, nil
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"time"
+
+	"github.com/SergeiSkv/goose/v3"
+	"github.com/SergeiSkv/goose/v3/internal/migrationstats"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	formatTable = "table"
+	formatJSON  = "json"
+	formatYAML  = "yaml"
+)
+
+func checkFormat(format string) error {
+	switch format {
+	case formatTable, formatJSON, formatYAML:
+		return nil
+	}
+	return fmt.Errorf("unknown format %q: must be one of %s, %s or %s", format, formatTable, formatJSON, formatYAML)
+}
+
+type statusOutput struct {
+	Version   int64      `json:"version" yaml:"version"`
+	Source    string     `json:"source" yaml:"source"`
+	Type      string     `json:"type" yaml:"type"`
+	Tx        bool       `json:"tx" yaml:"tx"`
+	Applied   bool       `json:"applied" yaml:"applied"`
+	AppliedAt *time.Time `json:"applied_at,omitempty" yaml:"applied_at,omitempty"`
+}
+
+type versionOutput struct {
+	Version int64 `json:"version" yaml:"version"`
+}
+
+type validateOutput struct {
+	Version   int64  `json:"version" yaml:"version"`
+	Source    string `json:"source" yaml:"source"`
+	Type      string `json:"type" yaml:"type"`
+	Tx        bool   `json:"tx" yaml:"tx"`
+	UpCount   int    `json:"up_count" yaml:"up_count"`
+	DownCount int    `json:"down_count" yaml:"down_count"`
+}
+
+// hasFormattedOutput reports whether the command prints its result in the
+// format selected with -format. Other commands ignore the flag.
+func hasFormattedOutput(command string) bool {
+	switch command {
+	case "status", "version":
+		return true
+	}
+	return false
+}
+
+// printFormatted prints the result of the status or version command in a
+// machine-readable format.
+func printFormatted(
+	ctx context.Context,
+	w io.Writer,
+	format string,
+	command string,
+	db goose.DB,
+	dir string,
+	options []goose.OptionsFunc,
+) error {
+	switch command {
+	case "status":
+		statuses, err := goose.ListStatusContext(ctx, db, dir, options...)
+		if err != nil {
+			return err
+		}
+		out := make([]statusOutput, 0, len(statuses))
+		for _, s := range statuses {
+			o := statusOutput{
+				Version: s.Version,
+				Source:  filepath.Base(s.Source),
+				Type:    string(s.Type),
+				Tx:      s.UseTx,
+				Applied: s.Applied,
+			}
+			if s.Applied {
+				appliedAt := s.AppliedAt
+				o.AppliedAt = &appliedAt
+			}
+			out = append(out, o)
+		}
+		return encode(w, format, out)
+	case "version":
+		var current int64
+		if *noVersioning {
+			migrations, err := goose.CollectMigrations(dir, 0, goose.MaxVersion)
+			if err != nil {
+				return fmt.Errorf("failed to collect migrations: %w", err)
+			}
+			if len(migrations) > 0 {
+				current = migrations[len(migrations)-1].Version
+			}
+		} else {
+			var err error
+			if current, err = goose.GetDBVersionContext(ctx, db); err != nil {
+				return err
+			}
+		}
+		return encode(w, format, versionOutput{Version: current})
+	}
+	return fmt.Errorf("%q: command does not support -format %s", command, format)
+}
+
+func printValidateFormatted(w io.Writer, format string, stats []*migrationstats.Stats) error {
+	out := make([]validateOutput, 0, len(stats))
+	for _, m := range stats {
+		out = append(out, validateOutput{
+			Version:   m.Version,
+			Source:    filepath.Base(m.FileName),
+			Type:      string(m.Type),
+			Tx:        m.Tx,
+			UpCount:   m.UpCount,
+			DownCount: m.DownCount,
+		})
+	}
+	return encode(w, format, out)
+}
+
+func encode(w io.Writer, format string, v interface{}) error {
+	switch format {
+	case formatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	case formatYAML:
+		enc := yaml.NewEncoder(w)
+		enc.SetIndent(2)
+		if err := enc.Encode(v); err != nil {
+			return err
+		}
+		return enc.Close()
+	}
+	return fmt.Errorf("unknown format %q", format)
+}
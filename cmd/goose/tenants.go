@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/SergeiSkv/goose/v3"
+)
+
+type tenantOutput struct {
+	Tenant   string `json:"tenant" yaml:"tenant"`
+	Status   string `json:"status" yaml:"status"`
+	Version  int64  `json:"version" yaml:"version"`
+	Applied  int    `json:"applied" yaml:"applied"`
+	Duration string `json:"duration" yaml:"duration"`
+	Error    string `json:"error,omitempty" yaml:"error,omitempty"`
+}
+
+// tenantMode reports whether the -tenants-file or -tenants-query flag is set.
+func tenantMode() bool {
+	return *tenantsFile != "" || *tenantsQuery != ""
+}
+
+// readTenants reads the tenant list from the -tenants-file file, "-" being
+// stdin, or from the result of the -tenants-query query run on db.
+func readTenants(ctx context.Context, db goose.DB) ([]string, error) {
+	switch {
+	case *tenantsFile != "" && *tenantsQuery != "":
+		return nil, errors.New("-tenants-file and -tenants-query are mutually exclusive")
+	case *tenantsQuery != "":
+		return goose.QueryTenants(ctx, db, *tenantsQuery)
+	case *tenantsFile == "-":
+		return goose.ReadTenants(os.Stdin)
+	}
+	f, err := os.Open(*tenantsFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open tenants file: %w", err)
+	}
+	defer f.Close()
+	return goose.ReadTenants(f)
+}
+
+// runTenants runs the up or version command across the tenant schemas and
+// prints the report, even if some tenants failed.
+func runTenants(
+	ctx context.Context,
+	w io.Writer,
+	command string,
+	connect goose.ConnectFunc,
+	tenants []string,
+	dir string,
+	options []goose.OptionsFunc,
+) error {
+	opts := []goose.TenantOption{
+		goose.WithTenantConcurrency(*tenantConcurrency),
+		goose.WithTenantOptions(options...),
+	}
+	if *continueOnError {
+		opts = append(opts, goose.WithContinueOnError())
+	}
+	var (
+		report *goose.TenantReport
+		err    error
+	)
+	switch command {
+	case "up":
+		report, err = goose.UpTenantsContext(ctx, connect, dir, tenants, opts...)
+	case "version":
+		report, err = goose.TenantVersionsContext(ctx, connect, dir, tenants, opts...)
+	default:
+		return fmt.Errorf("%q: command is not supported with -tenants-file or -tenants-query, use up or version", command)
+	}
+	if report == nil {
+		return err
+	}
+	if printErr := printTenantReport(w, *format, report); printErr != nil && err == nil {
+		err = printErr
+	}
+	return err
+}
+
+func printTenantReport(w io.Writer, format string, report *goose.TenantReport) error {
+	out := make([]tenantOutput, 0, len(report.Tenants))
+	for _, t := range report.Tenants {
+		status, errMsg := outcome(t.Skipped, t.Error)
+		out = append(out, tenantOutput{
+			Tenant:   t.Tenant,
+			Status:   status,
+			Version:  t.Version,
+			Applied:  countApplied(t.Results),
+			Duration: t.Duration.String(),
+			Error:    errMsg,
+		})
+	}
+	if format != formatTable {
+		return encode(w, format, out)
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 3, ' ', 0)
+	fmtPattern := "%v\t%v\t%v\t%v\t%v\t%v\n"
+	fmt.Fprintf(tw, fmtPattern, "Tenant", "Status", "Version", "Applied", "Duration", "Error")
+	fmt.Fprintf(tw, fmtPattern, "──────", "──────", "───────", "───────", "────────", "─────")
+	for _, o := range out {
+		fmt.Fprintf(tw, fmtPattern, o.Tenant, o.Status, formatVersion(o.Version), o.Applied, o.Duration, o.Error)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	printVersionSummary(w, report.ByVersion(), len(report.Failed()), len(report.Skipped()), "tenant(s)")
+	return nil
+}
+
+// printVersionSummary prints how many of the tenants or databases are at each
+// version, newest first, and how many failed or were skipped.
+func printVersionSummary(w io.Writer, byVersion map[int64][]string, failed, skipped int, noun string) {
+	versions := make([]int64, 0, len(byVersion))
+	for v := range byVersion {
+		versions = append(versions, v)
+	}
+	sort.Slice(versions, func(i, j int) bool { return versions[i] > versions[j] })
+	fmt.Fprintln(w)
+	for _, v := range versions {
+		fmt.Fprintf(w, "version %d: %d %s\n", v, len(byVersion[v]), noun)
+	}
+	if failed > 0 {
+		fmt.Fprintf(w, "failed: %d %s\n", failed, noun)
+	}
+	if skipped > 0 {
+		fmt.Fprintf(w, "skipped: %d %s\n", skipped, noun)
+	}
+}
+
+// outcome returns the status of a tenant or database in a report, and its
+// error message.
+func outcome(skipped bool, err error) (status, errMsg string) {
+	switch {
+	case skipped:
+		return "skipped", ""
+	case err != nil:
+		return "failed", err.Error()
+	}
+	return "ok", ""
+}
+
+// countApplied returns how many of the results were applied.
+func countApplied(results []*goose.MigrationResult) int {
+	var n int
+	for _, r := range results {
+		if r.Error == nil && !r.DryRun {
+			n++
+		}
+	}
+	return n
+}
+
+// formatVersion formats a version of a report, -1 being unknown.
+func formatVersion(version int64) string {
+	if version < 0 {
+		return "-"
+	}
+	return fmt.Sprint(version)
+}
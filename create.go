@@ -6,8 +6,6 @@ import (
 	"path/filepath"
 	"text/template"
 	"time"
-
-	"github.com/jackc/pgx/v5"
 )
 
 type tmplVars struct {
@@ -25,11 +23,11 @@ func SetSequential(s bool) {
 }
 
 // Create writes a new blank migration file.
-func CreateWithTemplate(_ *pgx.Conn, dir string, tmpl *template.Template, name, migrationType string) error {
+func CreateWithTemplate(_ DB, dir string, tmpl *template.Template, name, migrationType string) error {
 	var version string
 	if sequential {
 		// always use DirFS here because it's modifying operation
-		migrations, err := collectMigrationsFS(osFS{}, dir, minVersion, maxVersion)
+		migrations, err := collectMigrationsFS(osFS{}, dir, minVersion, maxVersion, registeredGoMigrations)
 		if err != nil {
 			return err
 		}
@@ -82,7 +80,7 @@ func CreateWithTemplate(_ *pgx.Conn, dir string, tmpl *template.Template, name,
 }
 
 // Create writes a new blank migration file.
-func Create(db *pgx.Conn, dir, name, migrationType string) error {
+func Create(db DB, dir, name, migrationType string) error {
 	return CreateWithTemplate(db, dir, nil, name, migrationType)
 }
 
@@ -2,17 +2,64 @@ package goose
 
 import (
 	"context"
+	"database/sql"
 	"fmt"
 
 	"github.com/jackc/pgx/v5"
 )
 
+// DB is the database goose runs migrations on: it runs statements and begins
+// transactions. *pgx.Conn implements it, and NewSQLDB adapts database/sql
+// databases and connections to it, for the drivers pgx does not cover.
+type DB interface {
+	Queryer
+	Begin(ctx context.Context) (pgx.Tx, error)
+}
+
+// Conn is a DB opened by goose, see OpenDBWithDriver and ConnectFunc. It must
+// be closed once done.
+type Conn interface {
+	DB
+	Close(ctx context.Context) error
+}
+
+var _ Conn = (*pgx.Conn)(nil)
+
+// ConnectFunc opens a new connection to the database.
+type ConnectFunc func(ctx context.Context) (Conn, error)
+
 // OpenDBWithDriver creates a connection to a database, and modifies goose
 // internals to be compatible with the supplied driver by calling SetDialect.
-func OpenDBWithDriver(driver string, dbstring string) (*pgx.Conn, error) {
+//
+// Postgres is connected to with pgx, other drivers with database/sql, see
+// NewSQLDB. Their database/sql driver must be registered, such as by
+// importing modernc.org/sqlite for the sqlite driver.
+func OpenDBWithDriver(driver string, dbstring string) (Conn, error) {
+	return OpenDBWithDriverContext(context.Background(), driver, dbstring)
+}
+
+// OpenDBWithDriverContext is like OpenDBWithDriver, but connects using the
+// given context.
+func OpenDBWithDriverContext(ctx context.Context, driver string, dbstring string) (Conn, error) {
 	if err := SetDialect(driver); err != nil {
 		return nil, err
 	}
+	connect, err := NewConnectFunc(driver, dbstring)
+	if err != nil {
+		return nil, err
+	}
+	return connect(ctx)
+}
+
+// NewConnectFunc returns a ConnectFunc opening connections to dbstring with
+// the supplied driver. Unlike OpenDBWithDriver, it does not call SetDialect,
+// so that connections to databases of different drivers may be opened and
+// migrated concurrently: pass the dialect of the driver to the commands with
+// WithDialect, see ParseDialect, or call SetDialect.
+func NewConnectFunc(driver string, dbstring string) (ConnectFunc, error) {
+	if _, err := ParseDialect(driver); err != nil {
+		return nil, err
+	}
 
 	switch driver {
 	case "mssql":
@@ -30,8 +77,37 @@ func OpenDBWithDriver(driver string, dbstring string) (*pgx.Conn, error) {
 			return nil, err
 		}
 		conConfig.DefaultQueryExecMode = pgx.QueryExecModeSimpleProtocol
-		return pgx.ConnectConfig(context.Background(), conConfig)
+		return func(ctx context.Context) (Conn, error) {
+			conn, err := pgx.ConnectConfig(ctx, conConfig)
+			if err != nil {
+				return nil, err
+			}
+			return conn, nil
+		}, nil
 	default:
-		return nil, fmt.Errorf("unsupported driver %s", driver)
+		if !isRegisteredDriver(driver) {
+			return nil, fmt.Errorf("unsupported driver %s: no database/sql driver registered with that name", driver)
+		}
+		return func(ctx context.Context) (Conn, error) {
+			db, err := sql.Open(driver, dbstring)
+			if err != nil {
+				return nil, err
+			}
+			// sql.Open does not connect, surface connection errors now.
+			if err := db.PingContext(ctx); err != nil {
+				_ = db.Close()
+				return nil, err
+			}
+			return NewSQLDB(db), nil
+		}, nil
+	}
+}
+
+func isRegisteredDriver(name string) bool {
+	for _, d := range sql.Drivers() {
+		if d == name {
+			return true
+		}
 	}
+	return false
 }
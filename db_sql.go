@@ -0,0 +1,259 @@
+package goose
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// SQLConn is a database/sql database or connection. *sql.DB and *sql.Conn
+// implement it.
+type SQLConn interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+	BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error)
+	Close() error
+}
+
+var (
+	_ SQLConn = (*sql.DB)(nil)
+	_ SQLConn = (*sql.Conn)(nil)
+)
+
+// errSQLUnsupported is returned by the pgx.Tx and pgx.Rows methods that have no
+// database/sql counterpart.
+var errSQLUnsupported = errors.New("not supported by database/sql drivers")
+
+// NewSQLDB adapts a database/sql database or connection to DB, so goose can
+// run against any registered database/sql driver. Closing the returned Conn
+// closes db.
+//
+// Transactions implement pgx.Tx, so that Go migrations work unchanged across
+// drivers, but only Exec, Query, QueryRow, Commit and Rollback are supported:
+// the other methods fail with an error, Conn returns nil and LargeObjects
+// panics.
+// Arguments are passed to the driver as is, queries must use its placeholder
+// style.
+//
+// A *sql.DB is a pool: the Postgres advisory lock and the session settings of
+// migrations without a transaction need every statement to run on the same
+// connection, so pass a *sql.Conn, or a *sql.DB limited to a single open
+// connection, with them. The table-based lock, on the other hand, needs a
+// *sql.DB to take a connection of its own from.
+func NewSQLDB(db SQLConn) Conn {
+	return &sqlDB{db: db}
+}
+
+type sqlDB struct {
+	db SQLConn
+}
+
+var _ Conn = (*sqlDB)(nil)
+
+func (d *sqlDB) Exec(ctx context.Context, query string, args ...any) (pgconn.CommandTag, error) {
+	res, err := d.db.ExecContext(ctx, query, args...)
+	return sqlCommandTag(res, err)
+}
+
+func (d *sqlDB) Query(ctx context.Context, query string, args ...any) (pgx.Rows, error) {
+	rows, err := d.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	return &sqlRows{rows: rows}, nil
+}
+
+func (d *sqlDB) QueryRow(ctx context.Context, query string, args ...any) pgx.Row {
+	return d.db.QueryRowContext(ctx, query, args...)
+}
+
+func (d *sqlDB) Begin(ctx context.Context) (pgx.Tx, error) {
+	tx, err := d.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &sqlTx{tx: tx}, nil
+}
+
+func (d *sqlDB) Close(context.Context) error {
+	return d.db.Close()
+}
+
+// sqlCommandTag returns a command tag reporting the rows affected by a
+// statement, when the driver knows them.
+func sqlCommandTag(res sql.Result, err error) (pgconn.CommandTag, error) {
+	if err != nil {
+		return pgconn.CommandTag{}, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return pgconn.CommandTag{}, nil
+	}
+	return pgconn.NewCommandTag(fmt.Sprintf("EXEC %d", n)), nil
+}
+
+// sqlTx adapts a database/sql transaction to pgx.Tx.
+type sqlTx struct {
+	tx *sql.Tx
+}
+
+var _ pgx.Tx = (*sqlTx)(nil)
+
+func (t *sqlTx) Exec(ctx context.Context, query string, args ...any) (pgconn.CommandTag, error) {
+	res, err := t.tx.ExecContext(ctx, query, args...)
+	return sqlCommandTag(res, err)
+}
+
+func (t *sqlTx) Query(ctx context.Context, query string, args ...any) (pgx.Rows, error) {
+	rows, err := t.tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	return &sqlRows{rows: rows}, nil
+}
+
+func (t *sqlTx) QueryRow(ctx context.Context, query string, args ...any) pgx.Row {
+	return t.tx.QueryRowContext(ctx, query, args...)
+}
+
+// Commit commits the transaction. Like Rollback, it reports a transaction
+// already done with pgx.ErrTxClosed, as pgx does.
+func (t *sqlTx) Commit(context.Context) error {
+	return sqlTxErr(t.tx.Commit())
+}
+
+func (t *sqlTx) Rollback(context.Context) error {
+	return sqlTxErr(t.tx.Rollback())
+}
+
+func sqlTxErr(err error) error {
+	if errors.Is(err, sql.ErrTxDone) {
+		return pgx.ErrTxClosed
+	}
+	return err
+}
+
+func (t *sqlTx) Begin(context.Context) (pgx.Tx, error) {
+	return nil, fmt.Errorf("nested transactions are %w", errSQLUnsupported)
+}
+
+func (t *sqlTx) CopyFrom(context.Context, pgx.Identifier, []string, pgx.CopyFromSource) (int64, error) {
+	return 0, fmt.Errorf("CopyFrom is %w", errSQLUnsupported)
+}
+
+func (t *sqlTx) SendBatch(context.Context, *pgx.Batch) pgx.BatchResults {
+	return sqlBatchResults{err: fmt.Errorf("SendBatch is %w", errSQLUnsupported)}
+}
+
+// LargeObjects panics, large objects being specific to Postgres.
+// pgx.LargeObjects cannot hold an error: its only field is the unexported
+// transaction its methods run on, so any value built here would panic on
+// first use with a nil dereference instead of naming the cause.
+func (t *sqlTx) LargeObjects() pgx.LargeObjects {
+	panic(fmt.Errorf("goose: LargeObjects is %w", errSQLUnsupported))
+}
+
+func (t *sqlTx) Prepare(context.Context, string, string) (*pgconn.StatementDescription, error) {
+	return nil, fmt.Errorf("Prepare is %w", errSQLUnsupported)
+}
+
+// Conn returns nil, there is no pgx connection underneath. *pgx.Conn is a
+// concrete type that only pgx.Connect can build, so it cannot be stubbed to
+// fail with errSQLUnsupported.
+func (t *sqlTx) Conn() *pgx.Conn {
+	return nil
+}
+
+type sqlBatchResults struct {
+	err error
+}
+
+func (b sqlBatchResults) Exec() (pgconn.CommandTag, error) { return pgconn.CommandTag{}, b.err }
+func (b sqlBatchResults) Query() (pgx.Rows, error)         { return nil, b.err }
+func (b sqlBatchResults) QueryRow() pgx.Row                { return sqlErrRow{err: b.err} }
+func (b sqlBatchResults) Close() error                     { return b.err }
+
+type sqlErrRow struct {
+	err error
+}
+
+func (r sqlErrRow) Scan(...any) error { return r.err }
+
+// sqlRows adapts database/sql rows to pgx.Rows.
+type sqlRows struct {
+	rows *sql.Rows
+	// err is the error closing the rows, if any.
+	err error
+}
+
+var _ pgx.Rows = (*sqlRows)(nil)
+
+func (r *sqlRows) Close() {
+	if err := r.rows.Close(); err != nil && r.err == nil {
+		r.err = err
+	}
+}
+
+func (r *sqlRows) Err() error {
+	if err := r.rows.Err(); err != nil {
+		return err
+	}
+	return r.err
+}
+
+func (r *sqlRows) CommandTag() pgconn.CommandTag {
+	return pgconn.CommandTag{}
+}
+
+// FieldDescriptions only holds the column names.
+func (r *sqlRows) FieldDescriptions() []pgconn.FieldDescription {
+	columns, err := r.rows.Columns()
+	if err != nil {
+		return nil
+	}
+	fields := make([]pgconn.FieldDescription, len(columns))
+	for i, name := range columns {
+		fields[i].Name = name
+	}
+	return fields
+}
+
+func (r *sqlRows) Next() bool {
+	return r.rows.Next()
+}
+
+func (r *sqlRows) Scan(dest ...any) error {
+	return r.rows.Scan(dest...)
+}
+
+func (r *sqlRows) Values() ([]any, error) {
+	columns, err := r.rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	values := make([]any, len(columns))
+	dest := make([]any, len(columns))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	if err := r.rows.Scan(dest...); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+// RawValues returns nil, database/sql does not expose the wire format.
+func (r *sqlRows) RawValues() [][]byte {
+	return nil
+}
+
+// Conn returns nil, as pgx documents for rows that did not come from a
+// *pgx.Conn.
+func (r *sqlRows) Conn() *pgx.Conn {
+	return nil
+}
@@ -6,36 +6,55 @@ import (
 	"github.com/SergeiSkv/goose/v3/internal/dialect"
 )
 
-func init() {
-	store, _ = dialect.NewStore(dialect.Postgres, TableName())
-}
+// Dialect is the type of database dialect.
+type Dialect = dialect.Dialect
+
+const (
+	DialectPostgres   Dialect = dialect.Postgres
+	DialectMySQL      Dialect = dialect.Mysql
+	DialectSQLite3    Dialect = dialect.Sqlite3
+	DialectMSSQL      Dialect = dialect.Sqlserver
+	DialectRedshift   Dialect = dialect.Redshift
+	DialectTiDB       Dialect = dialect.Tidb
+	DialectClickHouse Dialect = dialect.Clickhouse
+	DialectVertica    Dialect = dialect.Vertica
+)
 
-var store dialect.Store
+// currentDialect is the dialect used by the package-level functions.
+var currentDialect = DialectPostgres
 
-// SetDialect sets the dialect to use for the goose package.
+// SetDialect sets the dialect to use for the goose package. See also
+// WithDialect.
 func SetDialect(s string) error {
-	var d dialect.Dialect
+	d, err := ParseDialect(s)
+	if err != nil {
+		return err
+	}
+	currentDialect = d
+	return nil
+}
+
+// ParseDialect returns the dialect of a dialect or driver name, such as
+// "postgres" or "sqlite3", as accepted by SetDialect.
+func ParseDialect(s string) (Dialect, error) {
 	switch s {
 	case "postgres", "pgx":
-		d = dialect.Postgres
+		return DialectPostgres, nil
 	case "mysql":
-		d = dialect.Mysql
+		return DialectMySQL, nil
 	case "sqlite3", "sqlite":
-		d = dialect.Sqlite3
+		return DialectSQLite3, nil
 	case "mssql", "azuresql":
-		d = dialect.Sqlserver
+		return DialectMSSQL, nil
 	case "redshift":
-		d = dialect.Redshift
+		return DialectRedshift, nil
 	case "tidb":
-		d = dialect.Tidb
+		return DialectTiDB, nil
 	case "clickhouse":
-		d = dialect.Clickhouse
+		return DialectClickHouse, nil
 	case "vertica":
-		d = dialect.Vertica
+		return DialectVertica, nil
 	default:
-		return fmt.Errorf("%q: unknown dialect", s)
+		return "", fmt.Errorf("%q: unknown dialect", s)
 	}
-	var err error
-	store, err = dialect.NewStore(d, TableName())
-	return err
 }
@@ -1,95 +1,149 @@
 package goose
 
 import (
+	"context"
 	"fmt"
-
-	"github.com/jackc/pgx/v5"
 )
 
 // Down rolls back a single migration from the current version.
-func Down(db *pgx.Conn, dir string, opts ...OptionsFunc) error {
-	option := &options{}
-	for _, f := range opts {
-		f(option)
+func Down(db DB, dir string, opts ...OptionsFunc) error {
+	return DownContext(context.Background(), db, dir, opts...)
+}
+
+// DownContext rolls back a single migration from the current version.
+func DownContext(ctx context.Context, db DB, dir string, opts ...OptionsFunc) error {
+	p, err := newGlobalProvider(db, dir)
+	if err != nil {
+		return err
 	}
-	migrations, err := CollectMigrations(dir, minVersion, maxVersion)
+	_, err = p.Down(ctx, opts...)
+	return err
+}
+
+// DownTo rolls back migrations to a specific version.
+func DownTo(db DB, dir string, version int64, opts ...OptionsFunc) error {
+	return DownToContext(context.Background(), db, dir, version, opts...)
+}
+
+// DownToContext rolls back migrations to a specific version.
+func DownToContext(ctx context.Context, db DB, dir string, version int64, opts ...OptionsFunc) error {
+	p, err := newGlobalProvider(db, dir)
 	if err != nil {
 		return err
 	}
+	_, err = p.DownTo(ctx, version, opts...)
+	return err
+}
+
+// Down rolls back a single migration from the current version and returns
+// its result.
+func (p *Provider) Down(ctx context.Context, opts ...OptionsFunc) ([]*MigrationResult, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	option := p.applyOptions(opts)
+	return p.runCommand(ctx, "down", option, func(ctx context.Context) ([]*MigrationResult, error) {
+		return p.down(ctx, option)
+	})
+}
+
+func (p *Provider) down(ctx context.Context, option *options) ([]*MigrationResult, error) {
+	migrations, err := p.collectMigrations(minVersion, maxVersion)
+	if err != nil {
+		return nil, err
+	}
 	if option.noVersioning {
 		if len(migrations) == 0 {
-			return nil
+			return nil, nil
 		}
 		currentVersion := migrations[len(migrations)-1].Version
 		// Migrate only the latest migration down.
-		return downToNoVersioning(db, migrations, currentVersion-1)
+		return p.downToNoVersioning(ctx, migrations, currentVersion-1, option)
 	}
-	currentVersion, err := GetDBVersion(db)
+	currentVersion, err := p.getDBVersion(ctx, option)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	current, err := migrations.Current(currentVersion)
 	if err != nil {
-		return fmt.Errorf("no migration %v", currentVersion)
+		return nil, fmt.Errorf("no migration %v", currentVersion)
 	}
-	return current.Down(db)
+	result, err := p.runMigration(ctx, current, false, option)
+	return []*MigrationResult{result}, err
 }
 
-// DownTo rolls back migrations to a specific version.
-func DownTo(db *pgx.Conn, dir string, version int64, opts ...OptionsFunc) error {
-	option := &options{}
-	for _, f := range opts {
-		f(option)
-	}
-	migrations, err := CollectMigrations(dir, minVersion, maxVersion)
+// DownTo rolls back migrations to a specific version and returns the result of
+// each migration that was rolled back. On failure, the results include the
+// failed migration.
+func (p *Provider) DownTo(ctx context.Context, version int64, opts ...OptionsFunc) ([]*MigrationResult, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	option := p.applyOptions(opts)
+	return p.runCommand(ctx, "down-to", option, func(ctx context.Context) ([]*MigrationResult, error) {
+		return p.downTo(ctx, version, option)
+	})
+}
+
+func (p *Provider) downTo(ctx context.Context, version int64, option *options) ([]*MigrationResult, error) {
+	migrations, err := p.collectMigrations(minVersion, maxVersion)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	if option.noVersioning {
-		return downToNoVersioning(db, migrations, version)
+		return p.downToNoVersioning(ctx, migrations, version, option)
 	}
 
+	var results []*MigrationResult
 	for {
-		currentVersion, err := GetDBVersion(db)
+		currentVersion, err := p.getDBVersion(ctx, option)
 		if err != nil {
-			return err
+			return results, err
 		}
 
 		if currentVersion == 0 {
-			log.Printf("goose: no migrations to run. current version: %d\n", currentVersion)
-			return nil
+			p.log(ctx, LevelInfo, "no migrations to run", "goose: no migrations to run. current version: %d\n",
+				Field{Key: "current_version", Value: currentVersion})
+			return results, nil
 		}
 		current, err := migrations.Current(currentVersion)
 		if err != nil {
-			log.Printf("goose: migration file not found for current version (%d), error: %s\n", currentVersion, err)
-			return err
+			p.log(ctx, LevelError, "migration file not found for current version", "goose: migration file not found for current version (%d), error: %s\n",
+				Field{Key: "current_version", Value: currentVersion}, Field{Key: "error", Value: err})
+			return results, err
 		}
 
 		if current.Version <= version {
-			log.Printf("goose: no migrations to run. current version: %d\n", currentVersion)
-			return nil
+			p.log(ctx, LevelInfo, "no migrations to run", "goose: no migrations to run. current version: %d\n",
+				Field{Key: "current_version", Value: currentVersion})
+			return results, nil
 		}
 
-		if err = current.Down(db); err != nil {
-			return err
+		result, err := p.runMigration(ctx, current, false, option)
+		results = append(results, result)
+		if err != nil {
+			return results, err
 		}
 	}
 }
 
 // downToNoVersioning applies down migrations down to, but not including, the
 // target version.
-func downToNoVersioning(db *pgx.Conn, migrations Migrations, version int64) error {
-	var finalVersion int64
+func (p *Provider) downToNoVersioning(ctx context.Context, migrations Migrations, version int64, option *options) ([]*MigrationResult, error) {
+	var (
+		finalVersion int64
+		results      []*MigrationResult
+	)
 	for i := len(migrations) - 1; i >= 0; i-- {
 		if version >= migrations[i].Version {
 			finalVersion = migrations[i].Version
 			break
 		}
-		migrations[i].noVersioning = true
-		if err := migrations[i].Down(db); err != nil {
-			return err
+		result, err := p.runMigration(ctx, migrations[i], false, option)
+		results = append(results, result)
+		if err != nil {
+			return results, err
 		}
 	}
-	log.Printf("goose: down to current file version: %d\n", finalVersion)
-	return nil
+	p.log(ctx, LevelInfo, "down to current file version", "goose: down to current file version: %d\n",
+		Field{Key: "current_version", Value: finalVersion})
+	return results, nil
 }
@@ -0,0 +1,37 @@
+package goose
+
+import (
+	"os"
+
+	"github.com/SergeiSkv/goose/v3/internal/sqlparser"
+)
+
+// SetEnvVars sets the variables expanded in SQL migrations by the
+// package-level functions, see WithEnvVars.
+func SetEnvVars(vars map[string]string) {
+	WithEnvVars(vars)(&defaultOptions)
+}
+
+// WithEnvVars sets the variables expanded in the sections of SQL migrations
+// between '-- +goose ENVSUB ON' and '-- +goose ENVSUB OFF':
+//
+//	-- +goose ENVSUB ON
+//	CREATE TABLE t (id int) TABLESPACE ${TABLESPACE:-pg_default};
+//	-- +goose ENVSUB OFF
+//
+// A variable without a default must be set. By default variables are looked
+// up in the process environment, a non-nil vars replaces it.
+//
+// The values of variables whose name contains password, secret, token,
+// credential, private or key are masked in verbose output and errors.
+func WithEnvVars(vars map[string]string) ProviderOptionsFunc {
+	return func(o *providerOptions) { o.templates.envVars = vars }
+}
+
+// env returns the lookup of the variables expanded in SQL migrations.
+func (p *Provider) env() sqlparser.Env {
+	if p.templates.envVars != nil {
+		return sqlparser.EnvMap(p.templates.envVars)
+	}
+	return os.LookupEnv
+}
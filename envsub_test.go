@@ -0,0 +1,38 @@
+package goose
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"testing/fstest"
+
+	"github.com/SergeiSkv/goose/v3/internal/check"
+)
+
+func TestEnvVars(t *testing.T) {
+	t.Parallel()
+
+	fsys := fstest.MapFS{
+		"00001_a.sql": {Data: []byte("-- +goose Up\n-- +goose ENVSUB ON\nCREATE SCHEMA ${SCHEMA};\nCREATE TABLE ${SCHEMA}.a (id int) TABLESPACE ${TABLESPACE:-pg_default};\n-- +goose Down\nDROP SCHEMA ${SCHEMA};\n")},
+	}
+	newProvider := func(t *testing.T, vars map[string]string) *Provider {
+		t.Helper()
+		return newTestProvider(t, DialectPostgres, fsys, nil, WithEnvVars(vars))
+	}
+
+	var buf bytes.Buffer
+	_, err := newProvider(t, map[string]string{"SCHEMA": "tenant"}).Up(context.Background(), WithSQLScript(&buf), WithNoVersioning())
+	check.NoError(t, err)
+	want := `-- +goose up 00001_a.sql
+BEGIN;
+CREATE SCHEMA tenant;
+CREATE TABLE tenant.a (id int) TABLESPACE pg_default;
+COMMIT;
+
+`
+	check.Equal(t, buf.String(), want)
+
+	_, err = newProvider(t, map[string]string{}).Up(context.Background(), WithDryRun())
+	check.HasError(t, err)
+	check.Contains(t, err.Error(), `environment variable "SCHEMA" is not set`)
+}
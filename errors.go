@@ -0,0 +1,32 @@
+package goose
+
+import "errors"
+
+// Errors returned by commands match, with errors.Is, the class of their
+// failure, if known.
+var (
+	// ErrParse is matched by the errors of migrations that could not be
+	// collected, read, rendered or parsed.
+	ErrParse = errors.New("invalid migration")
+	// ErrExecution is matched by the errors of migrations that failed to
+	// run.
+	ErrExecution = errors.New("migration failed")
+	// ErrLock is matched by failures to acquire the migration lock,
+	// including ErrLockTimeout.
+	ErrLock = errors.New("migration lock not acquired")
+)
+
+// classError attaches a class, such as ErrParse, to err without changing its
+// message or hiding the errors it wraps.
+type classError struct {
+	class error
+	err   error
+}
+
+func withClass(class, err error) error {
+	return &classError{class: class, err: err}
+}
+
+func (e *classError) Error() string        { return e.err.Error() }
+func (e *classError) Unwrap() error        { return e.err }
+func (e *classError) Is(target error) bool { return target == e.class }
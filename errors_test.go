@@ -0,0 +1,54 @@
+package goose
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"testing/fstest"
+
+	"github.com/SergeiSkv/goose/v3/internal/check"
+)
+
+type failingLocker struct{ err error }
+
+func (l failingLocker) Lock(context.Context, DB) error   { return l.err }
+func (l failingLocker) Unlock(context.Context, DB) error { return nil }
+
+func TestErrorClasses(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	newProvider := func(t *testing.T, fsys fstest.MapFS, opts ...ProviderOptionsFunc) *Provider {
+		t.Helper()
+		return newTestProvider(t, DialectPostgres, fsys, nil, opts...)
+	}
+
+	t.Run("parse", func(t *testing.T) {
+		p := newProvider(t, fstest.MapFS{"00001_a.sql": {Data: []byte("SELECT 1;\n")}})
+		_, err := p.Up(ctx, WithDryRun())
+		check.HasError(t, err)
+		check.Bool(t, errors.Is(err, ErrParse), true)
+		check.Bool(t, errors.Is(err, ErrExecution), false)
+		check.Contains(t, err.Error(), "failed to parse SQL migration file")
+
+		p = newProvider(t, fstest.MapFS{"a.sql": {Data: []byte("-- +goose Up\nSELECT 1;\n")}})
+		_, err = p.Up(ctx, WithDryRun())
+		check.Bool(t, errors.Is(err, ErrParse), true)
+	})
+	t.Run("execution", func(t *testing.T) {
+		p := newProvider(t, nil, WithLocker(nil))
+		m := &Migration{Version: 1, Source: "00001_a.go", UseTx: true}
+		_, err := p.ApplyMigration(ctx, m, true)
+		check.HasError(t, err)
+		check.Bool(t, errors.Is(err, ErrExecution), true)
+	})
+	t.Run("lock", func(t *testing.T) {
+		connErr := errors.New("connection reset")
+		p := newProvider(t, fstest.MapFS{}, WithLocker(failingLocker{err: connErr}))
+		_, err := p.Up(ctx)
+		check.HasError(t, err)
+		check.Bool(t, errors.Is(err, ErrLock), true)
+		check.Bool(t, errors.Is(err, connErr), true)
+		check.Equal(t, err.Error(), "failed to acquire lock: connection reset")
+	})
+}
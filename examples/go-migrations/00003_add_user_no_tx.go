@@ -6,14 +6,13 @@ import (
 	"errors"
 
 	"github.com/SergeiSkv/goose/v3"
-	"github.com/jackc/pgx/v5"
 )
 
 func init() {
 	goose.AddMigrationNoTx(Up00003, Down00003)
 }
 
-func Up00003(db *pgx.Conn) error {
+func Up00003(db goose.DB) error {
 	id, err := getUserID(db, "jamesbond")
 	if err != nil {
 		return err
@@ -27,7 +26,7 @@ func Up00003(db *pgx.Conn) error {
 	return nil
 }
 
-func getUserID(db *pgx.Conn, username string) (int, error) {
+func getUserID(db goose.DB, username string) (int, error) {
 	var id int
 	err := db.QueryRow(context.Background(), "SELECT id FROM users WHERE username = $1", username).Scan(&id)
 	if err != nil && !errors.Is(err, sql.ErrNoRows) {
@@ -36,7 +35,7 @@ func getUserID(db *pgx.Conn, username string) (int, error) {
 	return id, nil
 }
 
-func Down00003(db *pgx.Conn) error {
+func Down00003(db goose.DB) error {
 	query := "DELETE FROM users WHERE username = $1"
 	if _, err := db.Exec(context.Background(), query, "jamesbond"); err != nil {
 		return err
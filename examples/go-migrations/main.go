@@ -3,10 +3,12 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"log"
 	"os"
 
+	"github.com/SergeiSkv/goose/v3"
 	_ "modernc.org/sqlite"
 )
 
@@ -32,7 +34,7 @@ func main() {
 	}
 
 	defer func() {
-		if err := db.Close(); err != nil {
+		if err := db.Close(context.Background()); err != nil {
 			log.Fatalf("goose: failed to close DB: %v\n", err)
 		}
 	}()
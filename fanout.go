@@ -0,0 +1,108 @@
+package goose
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+)
+
+// fanOut runs fn for the items from, included, to to, excluded, at most
+// concurrency at a time. Once fn failed, or ctx is done, no new item is
+// started, unless continueOnError for failures, and skip is called for the
+// remaining items instead. It reports whether fn failed for any item.
+func fanOut(ctx context.Context, from, to, concurrency int, continueOnError bool, fn func(i int) error, skip func(i int)) bool {
+	var (
+		wg     sync.WaitGroup
+		mu     sync.Mutex
+		failed bool
+	)
+	slots := make(chan struct{}, concurrency)
+	for i := from; i < to; i++ {
+		slots <- struct{}{}
+		mu.Lock()
+		stop := failed && !continueOnError
+		mu.Unlock()
+		if stop || ctx.Err() != nil {
+			<-slots
+			skip(i)
+			continue
+		}
+		wg.Add(1)
+		go func(i int) {
+			defer func() {
+				<-slots
+				wg.Done()
+			}()
+			if err := fn(i); err != nil {
+				mu.Lock()
+				failed = true
+				mu.Unlock()
+			}
+		}(i)
+	}
+	wg.Wait()
+	return failed
+}
+
+// withLogTag tags the log output of the provider with value: text output is
+// prefixed with it and structured events get a key field. It must be applied
+// after the logger options.
+func withLogTag(key, value string) ProviderOptionsFunc {
+	return func(o *providerOptions) {
+		if o.logging.logger != nil {
+			o.logging.logger = &prefixLogger{Logger: o.logging.logger, prefix: "[" + value + "] "}
+		}
+		if o.logging.structured != nil {
+			o.logging.structured = &fieldLogger{StructuredLogger: o.logging.structured, field: Field{Key: key, Value: value}}
+		}
+	}
+}
+
+// prefixLogger prefixes the output of a Logger.
+type prefixLogger struct {
+	Logger
+	prefix string
+}
+
+func (l *prefixLogger) Print(v ...interface{}) {
+	l.Logger.Print(append([]interface{}{l.prefix}, v...)...)
+}
+
+func (l *prefixLogger) Println(v ...interface{}) {
+	l.Logger.Print(l.prefix + fmt.Sprintln(v...))
+}
+
+func (l *prefixLogger) Printf(format string, v ...interface{}) {
+	l.Logger.Printf(l.prefix+format, v...)
+}
+
+// fieldLogger adds a field to the events of a StructuredLogger.
+type fieldLogger struct {
+	StructuredLogger
+	field Field
+}
+
+func (l *fieldLogger) Log(ctx context.Context, level Level, msg string, fields ...Field) {
+	l.StructuredLogger.Log(ctx, level, msg, append(fields[:len(fields):len(fields)], l.field)...)
+}
+
+// readList reads a list of what, one item per line. Blank lines and lines
+// starting with # are ignored.
+func readList(r io.Reader, what string) ([]string, error) {
+	var items []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		items = append(items, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", what, err)
+	}
+	return items, nil
+}
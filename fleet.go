@@ -0,0 +1,261 @@
+package goose
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+)
+
+// FleetDatabase is one of the databases of a fleet, such as a shard.
+type FleetDatabase struct {
+	// Name identifies the database in the report and the log output. It
+	// must not hold credentials.
+	Name    string
+	Connect ConnectFunc
+}
+
+// FleetResult is the outcome of a command for a single database of a fleet.
+type FleetResult struct {
+	Database string
+	// Version is the version of the database once the command is done, or -1
+	// if it could not be read.
+	Version int64
+	// Pending is the number of migrations not applied to the database once
+	// the command is done.
+	Pending int
+	// Results are the migrations run for the database.
+	Results  []*MigrationResult
+	Duration time.Duration
+	// Canary is true if the database was migrated ahead of the others, see
+	// WithCanary.
+	Canary bool
+	// Skipped is true if the command was not run for the database, because
+	// the rollout was stopped or the context was cancelled.
+	Skipped bool
+	// Error is the error the command failed with for the database, if any.
+	Error error
+}
+
+// FleetReport is the outcome of a command run across a fleet of databases, see
+// UpToFleetContext.
+type FleetReport struct {
+	// Databases holds a result for every database, in the order the
+	// databases were given.
+	Databases []*FleetResult
+}
+
+// Failed returns the results of the databases the command failed for.
+func (r *FleetReport) Failed() []*FleetResult {
+	var failed []*FleetResult
+	for _, d := range r.Databases {
+		if d.Error != nil {
+			failed = append(failed, d)
+		}
+	}
+	return failed
+}
+
+// Skipped returns the results of the databases the command was not run for.
+func (r *FleetReport) Skipped() []*FleetResult {
+	var skipped []*FleetResult
+	for _, d := range r.Databases {
+		if d.Skipped {
+			skipped = append(skipped, d)
+		}
+	}
+	return skipped
+}
+
+// ByVersion groups the databases whose version is known by version. The
+// databases of each version are sorted by name.
+func (r *FleetReport) ByVersion() map[int64][]string {
+	versions := make(map[int64][]string)
+	for _, d := range r.Databases {
+		if d.Skipped || d.Version < 0 {
+			continue
+		}
+		versions[d.Version] = append(versions[d.Version], d.Database)
+	}
+	for _, databases := range versions {
+		sort.Strings(databases)
+	}
+	return versions
+}
+
+type fleetOptions struct {
+	concurrency     int
+	canary          int
+	continueOnError bool
+	providerOptions []ProviderOptionsFunc
+	options         []OptionsFunc
+}
+
+// FleetOption configures a command run across a fleet of databases.
+type FleetOption func(o *fleetOptions)
+
+// WithFleetConcurrency sets how many databases are migrated at the same time.
+// Defaults to 1.
+func WithFleetConcurrency(n int) FleetOption {
+	return func(o *fleetOptions) { o.concurrency = n }
+}
+
+// WithCanary migrates the first n databases before the others, which are only
+// started once all the canaries succeeded.
+func WithCanary(n int) FleetOption {
+	return func(o *fleetOptions) { o.canary = n }
+}
+
+// WithFleetContinueOnError keeps migrating the remaining databases after a
+// database failed. By default, the rollout is stopped: no new database is
+// started once one failed and the remaining ones are reported as skipped. A
+// failed canary always stops the rollout.
+func WithFleetContinueOnError() FleetOption {
+	return func(o *fleetOptions) { o.continueOnError = true }
+}
+
+// WithFleetProviderOptions configures the provider of every database, on top of
+// the package-level state.
+func WithFleetProviderOptions(opts ...ProviderOptionsFunc) FleetOption {
+	return func(o *fleetOptions) { o.providerOptions = append(o.providerOptions, opts...) }
+}
+
+// WithFleetOptions sets the options of the command run for every database, such
+// as WithAllowMissing or WithDryRun.
+func WithFleetOptions(opts ...OptionsFunc) FleetOption {
+	return func(o *fleetOptions) { o.options = append(o.options, opts...) }
+}
+
+// ReadDSNs reads a list of database connection strings, one per line. Blank
+// lines and lines starting with # are ignored.
+func ReadDSNs(r io.Reader) ([]string, error) {
+	return readList(r, "DSNs")
+}
+
+// UpToFleetContext migrates every database of a fleet up to version, such as
+// MaxVersion. It is meant for sharded deployments, where all the databases
+// must end up at the same version.
+//
+// Each database is migrated with a provider configured from the package-level
+// state, which is only read, and the options set with
+// WithFleetProviderOptions. Log output is prefixed with the database name, or
+// has a database field for structured loggers. See WithCanary and
+// WithFleetContinueOnError for how the rollout proceeds.
+//
+// The report has a result for every database. The returned error, if any,
+// tells how many databases failed and wraps the error of the first of them.
+func UpToFleetContext(ctx context.Context, databases []FleetDatabase, dir string, version int64, opts ...FleetOption) (*FleetReport, error) {
+	return runFleet(ctx, databases, dir, opts, func(ctx context.Context, p *Provider, option *fleetOptions) ([]*MigrationResult, error) {
+		return p.UpTo(ctx, version, option.options...)
+	})
+}
+
+// StatusFleetContext reads the version and the number of pending migrations of
+// every database of a fleet, without migrating them. See UpToFleetContext.
+func StatusFleetContext(ctx context.Context, databases []FleetDatabase, dir string, opts ...FleetOption) (*FleetReport, error) {
+	return runFleet(ctx, databases, dir, opts, func(ctx context.Context, p *Provider, option *fleetOptions) ([]*MigrationResult, error) {
+		return nil, nil
+	})
+}
+
+type fleetCommand func(ctx context.Context, p *Provider, option *fleetOptions) ([]*MigrationResult, error)
+
+func runFleet(ctx context.Context, databases []FleetDatabase, dir string, opts []FleetOption, fn fleetCommand) (*FleetReport, error) {
+	option := &fleetOptions{concurrency: 1}
+	for _, f := range opts {
+		f(option)
+	}
+	if option.concurrency < 1 {
+		return nil, fmt.Errorf("fleet concurrency must be at least 1, got %d", option.concurrency)
+	}
+	if option.canary < 0 {
+		return nil, fmt.Errorf("canary count must not be negative, got %d", option.canary)
+	}
+	seen := make(map[string]bool, len(databases))
+	report := &FleetReport{Databases: make([]*FleetResult, 0, len(databases))}
+	for i, d := range databases {
+		if d.Name == "" {
+			return nil, fmt.Errorf("database %d: name must not be empty", i)
+		}
+		if d.Connect == nil {
+			return nil, fmt.Errorf("database %s: connect must not be nil", d.Name)
+		}
+		if seen[d.Name] {
+			return nil, fmt.Errorf("duplicate database %q", d.Name)
+		}
+		seen[d.Name] = true
+		report.Databases = append(report.Databases, &FleetResult{
+			Database: d.Name,
+			Version:  -1,
+			Canary:   i < option.canary,
+		})
+	}
+
+	run := func(i int) error {
+		result := report.Databases[i]
+		start := time.Now()
+		result.Error = runFleetDatabase(ctx, databases[i], dir, result, option, fn)
+		result.Duration = time.Since(start)
+		return result.Error
+	}
+	skip := func(i int) {
+		report.Databases[i].Skipped = true
+	}
+	canary := option.canary
+	if canary > len(databases) {
+		canary = len(databases)
+	}
+	failed := fanOut(ctx, 0, canary, option.concurrency, option.continueOnError, run, skip)
+	if failed {
+		for i := canary; i < len(databases); i++ {
+			skip(i)
+		}
+	} else {
+		fanOut(ctx, canary, len(databases), option.concurrency, option.continueOnError, run, skip)
+	}
+
+	if failed := report.Failed(); len(failed) > 0 {
+		return report, fmt.Errorf("%d of %d databases failed, %s: %w", len(failed), len(report.Databases), failed[0].Database, failed[0].Error)
+	}
+	return report, nil
+}
+
+// runFleetDatabase runs fn for a single database and records its version and
+// pending migrations.
+func runFleetDatabase(ctx context.Context, d FleetDatabase, dir string, result *FleetResult, option *fleetOptions, fn fleetCommand) error {
+	db, err := d.Connect(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to connect: %w", err)
+	}
+	if db == nil {
+		return errors.New("failed to connect: no connection")
+	}
+	defer db.Close(context.Background())
+	opts := append([]ProviderOptionsFunc{}, option.providerOptions...)
+	opts = append(opts, withLogTag("database", d.Name))
+	p, err := newGlobalProvider(db, dir, opts...)
+	if err != nil {
+		return err
+	}
+	result.Results, err = fn(ctx, p, option)
+	// Report the state even if the command failed, the failed migration
+	// having been rolled back.
+	statuses, statusErr := p.ListStatus(ctx)
+	if statusErr == nil {
+		for _, s := range statuses {
+			if !s.Applied {
+				result.Pending++
+			}
+		}
+		result.Version, statusErr = p.GetDBVersion(ctx)
+	}
+	if statusErr != nil {
+		result.Version = -1
+		if err == nil {
+			err = statusErr
+		}
+	}
+	return err
+}
@@ -0,0 +1,123 @@
+package goose
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/SergeiSkv/goose/v3/internal/check"
+)
+
+func TestReadDSNs(t *testing.T) {
+	t.Parallel()
+
+	dsns, err := ReadDSNs(strings.NewReader("# shards\npostgres://shard1/app\n\npostgres://shard2/app\n"))
+	check.NoError(t, err)
+	check.Equal(t, strings.Join(dsns, ","), "postgres://shard1/app,postgres://shard2/app")
+}
+
+func TestFleetRollout(t *testing.T) {
+	t.Parallel()
+
+	var connects int32
+	databases := make([]FleetDatabase, 5)
+	for i := range databases {
+		databases[i] = FleetDatabase{
+			Name: "shard" + string(rune('1'+i)),
+			Connect: func(ctx context.Context) (Conn, error) {
+				atomic.AddInt32(&connects, 1)
+				return nil, errors.New("connection refused")
+			},
+		}
+	}
+
+	// The rollout stops at the first failure.
+	report, err := UpToFleetContext(context.Background(), databases, ".", MaxVersion)
+	check.HasError(t, err)
+	check.Contains(t, err.Error(), "1 of 5 databases failed, shard1: failed to connect: connection refused")
+	check.Number(t, atomic.LoadInt32(&connects), 1)
+	check.Number(t, len(report.Failed()), 1)
+	check.Number(t, len(report.Skipped()), 4)
+
+	// Failed canaries stop the rollout, even when continuing on error.
+	atomic.StoreInt32(&connects, 0)
+	report, err = UpToFleetContext(context.Background(), databases, ".", MaxVersion,
+		WithCanary(2),
+		WithFleetConcurrency(2),
+		WithFleetContinueOnError(),
+	)
+	check.HasError(t, err)
+	check.Contains(t, err.Error(), "2 of 5 databases failed")
+	check.Number(t, atomic.LoadInt32(&connects), 2)
+	for i, result := range report.Databases {
+		check.Bool(t, result.Canary, i < 2)
+		check.Bool(t, result.Skipped, i >= 2)
+		check.Number(t, result.Version, -1)
+	}
+
+	atomic.StoreInt32(&connects, 0)
+	report, err = StatusFleetContext(context.Background(), databases, ".",
+		WithFleetConcurrency(3),
+		WithFleetContinueOnError(),
+	)
+	check.HasError(t, err)
+	check.Contains(t, err.Error(), "5 of 5 databases failed")
+	check.Number(t, atomic.LoadInt32(&connects), 5)
+	check.Number(t, len(report.Skipped()), 0)
+
+	_, err = UpToFleetContext(context.Background(), append(databases, databases[0]), ".", MaxVersion)
+	check.HasError(t, err)
+	check.Contains(t, err.Error(), `duplicate database "shard1"`)
+}
+
+func TestStatusFleet(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	check.NoError(t, os.WriteFile(filepath.Join(dir, "00001_a.sql"), []byte("-- +goose Up\nCREATE TABLE a (id INTEGER);\n"), 0644))
+	var databases []FleetDatabase
+	for _, name := range []string{"shard1", "shard2"} {
+		connect, err := NewConnectFunc("sqlite", filepath.Join(t.TempDir(), name+".db"))
+		check.NoError(t, err)
+		databases = append(databases, FleetDatabase{Name: name, Connect: connect})
+	}
+
+	report, err := StatusFleetContext(context.Background(), databases, dir,
+		WithFleetProviderOptions(WithDialect(DialectSQLite3), WithLogger(NopLogger())),
+	)
+	check.NoError(t, err)
+	for _, result := range report.Databases {
+		check.Number(t, result.Version, 0)
+		check.Number(t, result.Pending, 1)
+	}
+	// The status does not create the version tables.
+	db, err := databases[0].Connect(context.Background())
+	check.NoError(t, err)
+	defer db.Close(context.Background())
+	var tables int
+	check.NoError(t, db.QueryRow(context.Background(), "SELECT COUNT(*) FROM sqlite_master").Scan(&tables))
+	check.Number(t, tables, 0)
+
+	check.Equal(t, globalDialect([]ProviderOptionsFunc{WithDialect(DialectSQLite3)}), DialectSQLite3)
+}
+
+func TestFleetReport(t *testing.T) {
+	t.Parallel()
+
+	report := &FleetReport{Databases: []*FleetResult{
+		{Database: "shard2", Version: 42},
+		{Database: "shard1", Version: 42},
+		{Database: "shard3", Version: 41, Pending: 1, Error: errors.New("boom")},
+		{Database: "shard4", Version: -1, Skipped: true},
+	}}
+	versions := report.ByVersion()
+	check.Number(t, len(versions), 2)
+	check.Equal(t, strings.Join(versions[42], ","), "shard1,shard2")
+	check.Equal(t, strings.Join(versions[41], ","), "shard3")
+	check.Number(t, len(report.Failed()), 1)
+	check.Number(t, len(report.Skipped()), 1)
+}
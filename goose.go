@@ -1,11 +1,11 @@
 package goose
 
 import (
+	"context"
 	"fmt"
 	"io/fs"
+	"os"
 	"strconv"
-
-	"github.com/jackc/pgx/v5"
 )
 
 // Deprecated: VERSION will no longer be supported in v4.
@@ -15,7 +15,6 @@ var (
 	maxVersion      = int64((1 << 63) - 1)
 	timestampFormat = "20060102150405"
 	verbose         = false
-	noColor         = false
 
 	// base fs to lookup migrations
 	baseFS fs.FS = osFS{}
@@ -38,23 +37,33 @@ func SetBaseFS(fsys fs.FS) {
 }
 
 // Run runs a goose command.
-func Run(command string, db *pgx.Conn, dir string, args ...string) error {
-	return run(command, db, dir, args)
+func Run(command string, db DB, dir string, args ...string) error {
+	return run(context.Background(), command, db, dir, args)
+}
+
+// RunContext runs a goose command.
+func RunContext(ctx context.Context, command string, db DB, dir string, args ...string) error {
+	return run(ctx, command, db, dir, args)
 }
 
 // Run runs a goose command with options.
-func RunWithOptions(command string, db *pgx.Conn, dir string, args []string, options ...OptionsFunc) error {
-	return run(command, db, dir, args, options...)
+func RunWithOptions(command string, db DB, dir string, args []string, options ...OptionsFunc) error {
+	return run(context.Background(), command, db, dir, args, options...)
+}
+
+// RunWithOptionsContext runs a goose command with options.
+func RunWithOptionsContext(ctx context.Context, command string, db DB, dir string, args []string, options ...OptionsFunc) error {
+	return run(ctx, command, db, dir, args, options...)
 }
 
-func run(command string, db *pgx.Conn, dir string, args []string, options ...OptionsFunc) error {
+func run(ctx context.Context, command string, db DB, dir string, args []string, options ...OptionsFunc) error {
 	switch command {
 	case "up":
-		if err := Up(db, dir, options...); err != nil {
+		if err := UpContext(ctx, db, dir, options...); err != nil {
 			return err
 		}
 	case "up-by-one":
-		if err := UpByOne(db, dir, options...); err != nil {
+		if err := UpByOneContext(ctx, db, dir, options...); err != nil {
 			return err
 		}
 	case "up-to":
@@ -66,7 +75,39 @@ func run(command string, db *pgx.Conn, dir string, args []string, options ...Opt
 		if err != nil {
 			return fmt.Errorf("version must be a number (got '%s')", args[0])
 		}
-		if err := UpTo(db, dir, version, options...); err != nil {
+		if err := UpToContext(ctx, db, dir, version, options...); err != nil {
+			return err
+		}
+	case "baseline":
+		if len(args) == 0 {
+			return fmt.Errorf("baseline must be of form: goose [OPTIONS] DRIVER DBSTRING baseline VERSION")
+		}
+
+		version, err := strconv.ParseInt(args[0], 10, 64)
+		if err != nil {
+			return fmt.Errorf("version must be a number (got '%s')", args[0])
+		}
+		if err := BaselineContext(ctx, db, dir, version, options...); err != nil {
+			return err
+		}
+	case "mark-applied", "mark-pending":
+		if len(args) == 0 {
+			return fmt.Errorf("%s must be of form: goose [OPTIONS] DRIVER DBSTRING %s VERSION", command, command)
+		}
+
+		version, err := strconv.ParseInt(args[0], 10, 64)
+		if err != nil {
+			return fmt.Errorf("version must be a number (got '%s')", args[0])
+		}
+		mark := MarkAppliedContext
+		if command == "mark-pending" {
+			mark = MarkPendingContext
+		}
+		if err := mark(ctx, db, dir, version, options...); err != nil {
+			return err
+		}
+	case "repair":
+		if err := RepairContext(ctx, db, dir, options...); err != nil {
 			return err
 		}
 	case "create":
@@ -82,7 +123,7 @@ func run(command string, db *pgx.Conn, dir string, args []string, options ...Opt
 			return err
 		}
 	case "down":
-		if err := Down(db, dir, options...); err != nil {
+		if err := DownContext(ctx, db, dir, options...); err != nil {
 			return err
 		}
 	case "down-to":
@@ -94,7 +135,7 @@ func run(command string, db *pgx.Conn, dir string, args []string, options ...Opt
 		if err != nil {
 			return fmt.Errorf("version must be a number (got '%s')", args[0])
 		}
-		if err := DownTo(db, dir, version, options...); err != nil {
+		if err := DownToContext(ctx, db, dir, version, options...); err != nil {
 			return err
 		}
 	case "fix":
@@ -102,19 +143,39 @@ func run(command string, db *pgx.Conn, dir string, args []string, options ...Opt
 			return err
 		}
 	case "redo":
-		if err := Redo(db, dir, options...); err != nil {
+		if err := RedoContext(ctx, db, dir, options...); err != nil {
 			return err
 		}
 	case "reset":
-		if err := Reset(db, dir, options...); err != nil {
+		if err := ResetContext(ctx, db, dir, options...); err != nil {
 			return err
 		}
 	case "status":
-		if err := Status(db, dir, options...); err != nil {
+		if err := StatusContext(ctx, db, dir, options...); err != nil {
 			return err
 		}
 	case "version":
-		if err := Version(db, dir, options...); err != nil {
+		if err := VersionContext(ctx, db, dir, options...); err != nil {
+			return err
+		}
+	case "sql":
+		if len(args) == 0 {
+			return fmt.Errorf("sql must be of form: goose [OPTIONS] DRIVER DBSTRING sql COMMAND [VERSION]")
+		}
+		switch args[0] {
+		case "up", "up-by-one", "up-to", "down", "down-to", "redo", "reset", "baseline",
+			"mark-applied", "mark-pending", "repair":
+		default:
+			return fmt.Errorf("%q: cannot be rendered as SQL", args[0])
+		}
+		options = append(options, WithSQLScript(os.Stdout))
+		return run(ctx, args[0], db, dir, args[1:], options...)
+	case "verify":
+		if err := VerifyContext(ctx, db, dir, options...); err != nil {
+			return err
+		}
+	case "unlock":
+		if err := ForceUnlockContext(ctx, db); err != nil {
 			return err
 		}
 	default:
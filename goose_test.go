@@ -154,7 +154,7 @@ var migrations embed.FS
 
 func TestEmbeddedMigrations(t *testing.T) {
 	// not using t.Parallel here to avoid races
-	db, err := sql.Open("sqlite", "sql_embed.db")
+	pool, err := sql.Open("sqlite", "sql_embed.db")
 	if err != nil {
 		t.Fatalf("Database open failed: %s", err)
 	}
@@ -164,7 +164,8 @@ func TestEmbeddedMigrations(t *testing.T) {
 		}
 	})
 
-	db.SetMaxOpenConns(1)
+	pool.SetMaxOpenConns(1)
+	db := NewSQLDB(pool)
 
 	// decouple from existing structure
 	fsys, err := fs.Sub(migrations, "examples/sql-migrations")
@@ -174,7 +175,10 @@ func TestEmbeddedMigrations(t *testing.T) {
 
 	SetBaseFS(fsys)
 	check.NoError(t, SetDialect("sqlite3"))
-	t.Cleanup(func() { SetBaseFS(nil) })
+	t.Cleanup(func() {
+		SetBaseFS(nil)
+		check.NoError(t, SetDialect("postgres"))
+	})
 
 	t.Run("Migration cycle", func(t *testing.T) {
 		if err := Up(db, ""); err != nil {
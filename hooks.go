@@ -0,0 +1,133 @@
+package goose
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/SergeiSkv/goose/v3/internal/sqlparser"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Queryer runs statements on the connection, or within the transaction, a
+// migration runs on. DB and pgx.Tx implement it.
+type Queryer interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+var (
+	_ Queryer = (*pgx.Conn)(nil)
+	_ Queryer = (pgx.Tx)(nil)
+)
+
+// Hooks are called around the commands that run migrations (up, down, redo,
+// reset, ...) and around each migration they run. Any hook may be nil. Hooks
+// are not called for dry runs.
+type Hooks struct {
+	// BeforeAll is called before the command looks for migrations to run.
+	BeforeAll func(ctx context.Context, db DB) error
+	// AfterAll is called once the command has succeeded, with the results of
+	// the migrations it ran.
+	AfterAll func(ctx context.Context, db DB, results []*MigrationResult) error
+
+	// BeforeEach is called before each migration. For migrations that run in
+	// a transaction, db is that transaction, otherwise it is the connection.
+	BeforeEach func(ctx context.Context, m *Migration, direction Direction, db Queryer) error
+	// AfterEach is called after each migration, once the version table has
+	// been updated. For migrations that run in a transaction, db is that
+	// transaction and it is committed afterwards, otherwise db is the
+	// connection.
+	AfterEach func(ctx context.Context, m *Migration, direction Direction, db Queryer) error
+
+	// OnError is called when the command fails. m is the migration that
+	// failed, if any, in which case its transaction has been rolled back.
+	OnError func(ctx context.Context, m *Migration, direction Direction, db DB, err error)
+}
+
+// AddHooks registers hooks for the package-level functions. Hooks registered
+// several times are called in registration order.
+func AddHooks(h Hooks) {
+	WithHooks(h)(&defaultOptions)
+}
+
+// WithHooks registers hooks with the provider. Hooks registered several times
+// are called in registration order.
+func WithHooks(h Hooks) ProviderOptionsFunc {
+	return func(o *providerOptions) { o.hooks = append(o.hooks, h) }
+}
+
+// runCommand runs command name, which applies or rolls back migrations,
+// holding the provider lock and calling the command hooks around it.
+func (p *Provider) runCommand(ctx context.Context, name string, option *options, fn func(ctx context.Context) ([]*MigrationResult, error)) ([]*MigrationResult, error) {
+	var results []*MigrationResult
+	err := p.traceCommand(ctx, name, option, func(ctx context.Context) error {
+		return p.withLock(ctx, option, func(ctx context.Context) error {
+			if option.dryRun {
+				var err error
+				results, err = fn(ctx)
+				return err
+			}
+			for _, h := range p.hooks {
+				if h.BeforeAll != nil {
+					if err := h.BeforeAll(ctx, p.db); err != nil {
+						return p.onError(ctx, option, fmt.Errorf("before all hook: %w", err))
+					}
+				}
+			}
+			var err error
+			results, err = fn(ctx)
+			if err != nil {
+				return p.onError(ctx, option, err)
+			}
+			for _, h := range p.hooks {
+				if h.AfterAll != nil {
+					if err := h.AfterAll(ctx, p.db, results); err != nil {
+						return p.onError(ctx, option, fmt.Errorf("after all hook: %w", err))
+					}
+				}
+			}
+			return nil
+		})
+	})
+	return results, err
+}
+
+// onError calls the OnError hooks and returns err.
+func (p *Provider) onError(ctx context.Context, option *options, err error) error {
+	var direction Direction
+	if option.failed != nil {
+		direction = option.failedDirection
+	}
+	for _, h := range p.hooks {
+		if h.OnError != nil {
+			h.OnError(ctx, option.failed, direction, p.db, err)
+		}
+	}
+	return err
+}
+
+// beforeEach calls the BeforeEach hooks for migration m.
+func (p *Provider) beforeEach(ctx context.Context, m *Migration, direction bool, db Queryer) error {
+	for _, h := range p.hooks {
+		if h.BeforeEach != nil {
+			if err := h.BeforeEach(ctx, m, sqlparser.FromBool(direction), db); err != nil {
+				return fmt.Errorf("before each hook: %w", err)
+			}
+		}
+	}
+	return nil
+}
+
+// afterEach calls the AfterEach hooks for migration m.
+func (p *Provider) afterEach(ctx context.Context, m *Migration, direction bool, db Queryer) error {
+	for _, h := range p.hooks {
+		if h.AfterEach != nil {
+			if err := h.AfterEach(ctx, m, sqlparser.FromBool(direction), db); err != nil {
+				return fmt.Errorf("after each hook: %w", err)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,195 @@
+package goose
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"testing"
+	"testing/fstest"
+
+	"github.com/SergeiSkv/goose/v3/internal/check"
+	"github.com/jackc/pgx/v5"
+)
+
+func TestHooks(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	errBoom := errors.New("boom")
+	newProvider := func(t *testing.T, calls *[]string, hooks Hooks) *Provider {
+		t.Helper()
+		record := func(name string) GoMigrationNoTxContext {
+			return func(context.Context, DB) error {
+				*calls = append(*calls, name)
+				if name == "3 up" {
+					return errBoom
+				}
+				return nil
+			}
+		}
+		p, _ := newSQLiteProvider(t, fstest.MapFS{},
+			WithHooks(hooks),
+			WithGoMigrations(
+				NewGoMigrationNoTx("00001_a.go", record("1 up"), record("1 down")),
+				NewGoMigrationNoTx("00002_b.go", record("2 up"), record("2 down")),
+				NewGoMigrationNoTx("00003_c.go", record("3 up"), nil),
+			),
+		)
+		return p
+	}
+	recordHooks := func(calls *[]string) Hooks {
+		return Hooks{
+			BeforeAll: func(_ context.Context, db DB) error {
+				check.Bool(t, db != nil, true)
+				*calls = append(*calls, "before all")
+				return nil
+			},
+			AfterAll: func(_ context.Context, _ DB, results []*MigrationResult) error {
+				*calls = append(*calls, fmt.Sprintf("after all %d", len(results)))
+				return nil
+			},
+			BeforeEach: func(_ context.Context, m *Migration, direction Direction, db Queryer) error {
+				// Migrations without a transaction get the database.
+				_, isTx := db.(pgx.Tx)
+				check.Bool(t, isTx, false)
+				*calls = append(*calls, fmt.Sprintf("before %d %s", m.Version, direction))
+				return nil
+			},
+			AfterEach: func(_ context.Context, m *Migration, direction Direction, _ Queryer) error {
+				*calls = append(*calls, fmt.Sprintf("after %d %s", m.Version, direction))
+				return nil
+			},
+			OnError: func(_ context.Context, m *Migration, direction Direction, _ DB, err error) {
+				version := int64(-1)
+				if m != nil {
+					version = m.Version
+				}
+				check.IsError(t, err, errBoom)
+				*calls = append(*calls, fmt.Sprintf("error %d %s", version, direction))
+			},
+		}
+	}
+
+	t.Run("success", func(t *testing.T) {
+		var calls []string
+		p := newProvider(t, &calls, recordHooks(&calls))
+		_, err := p.UpTo(ctx, 2, WithNoVersioning())
+		check.NoError(t, err)
+		check.Equal(t, calls, []string{
+			"before all",
+			"before 1 up", "1 up", "after 1 up",
+			"before 2 up", "2 up", "after 2 up",
+			"after all 2",
+		})
+	})
+	t.Run("migration error", func(t *testing.T) {
+		var calls []string
+		p := newProvider(t, &calls, recordHooks(&calls))
+		_, err := p.Up(ctx, WithNoVersioning())
+		check.IsError(t, err, errBoom)
+		check.Equal(t, calls, []string{
+			"before all",
+			"before 1 up", "1 up", "after 1 up",
+			"before 2 up", "2 up", "after 2 up",
+			"before 3 up", "3 up",
+			"error 3 up",
+		})
+	})
+	t.Run("hook error", func(t *testing.T) {
+		var calls []string
+		hooks := recordHooks(&calls)
+		hooks.BeforeEach = func(_ context.Context, m *Migration, _ Direction, _ Queryer) error {
+			if m.Version == 2 {
+				return errBoom
+			}
+			return nil
+		}
+		p := newProvider(t, &calls, hooks)
+		results, err := p.Up(ctx, WithNoVersioning())
+		check.IsError(t, err, errBoom)
+		check.Contains(t, err.Error(), "before each hook")
+		check.Number(t, len(results), 2)
+		check.Equal(t, calls, []string{
+			"before all",
+			"1 up", "after 1 up",
+			"error 2 up",
+		})
+	})
+	t.Run("before all error", func(t *testing.T) {
+		var calls []string
+		hooks := recordHooks(&calls)
+		hooks.BeforeAll = func(context.Context, DB) error { return errBoom }
+		p := newProvider(t, &calls, hooks)
+		_, err := p.Up(ctx, WithNoVersioning())
+		check.IsError(t, err, errBoom)
+		check.Equal(t, calls, []string{"error -1 "})
+	})
+	t.Run("within the transaction", func(t *testing.T) {
+		fsys := fstest.MapFS{
+			"00001_a.sql": {Data: []byte("-- +goose Up\nCREATE TABLE a (id INTEGER);\n")},
+			"00002_b.sql": {Data: []byte("-- +goose Up\nCREATE TABLE b (id INTEGER);\nINSERT INTO missing VALUES (1);\n")},
+		}
+		audit := func(ctx context.Context, event string, m *Migration, db Queryer) error {
+			// Migrations within a transaction get it.
+			if _, isTx := db.(pgx.Tx); !isTx {
+				return errors.New("not a transaction")
+			}
+			_, err := db.Exec(ctx, "INSERT INTO audit (event) VALUES (?)", fmt.Sprintf("%s %d", event, m.Version))
+			return err
+		}
+		events := func(t *testing.T, db *sql.DB) []string {
+			t.Helper()
+			rows, err := db.Query("SELECT event FROM audit ORDER BY rowid")
+			check.NoError(t, err)
+			defer rows.Close()
+			var events []string
+			for rows.Next() {
+				var event string
+				check.NoError(t, rows.Scan(&event))
+				events = append(events, event)
+			}
+			check.NoError(t, rows.Err())
+			return events
+		}
+		hooks := Hooks{
+			BeforeEach: func(ctx context.Context, m *Migration, _ Direction, db Queryer) error {
+				return audit(ctx, "before", m, db)
+			},
+			AfterEach: func(ctx context.Context, m *Migration, _ Direction, db Queryer) error {
+				return audit(ctx, "after", m, db)
+			},
+		}
+
+		p, db := newSQLiteProvider(t, fsys, WithHooks(hooks))
+		_, err := db.Exec("CREATE TABLE audit (event TEXT)")
+		check.NoError(t, err)
+		_, err = p.Up(ctx)
+		check.HasError(t, err)
+		// The writes of the hooks of the failed migration are rolled back with it.
+		check.Equal(t, events(t, db), []string{"before 1", "after 1"})
+		version, err := p.GetDBVersion(ctx)
+		check.NoError(t, err)
+		check.Number(t, version, 1)
+
+		// So is the migration when a hook fails after it.
+		hooks.AfterEach = func(context.Context, *Migration, Direction, Queryer) error { return errBoom }
+		p, db = newSQLiteProvider(t, fsys, WithHooks(hooks))
+		_, err = db.Exec("CREATE TABLE audit (event TEXT)")
+		check.NoError(t, err)
+		_, err = p.UpTo(ctx, 1)
+		check.IsError(t, err, errBoom)
+		check.Number(t, len(events(t, db)), 0)
+		check.Number(t, count(t, db, "SELECT COUNT(*) FROM sqlite_master WHERE name = 'a'"), 0)
+		version, err = p.GetDBVersion(ctx)
+		check.NoError(t, err)
+		check.Number(t, version, 0)
+	})
+	t.Run("dry run", func(t *testing.T) {
+		var calls []string
+		p := newProvider(t, &calls, recordHooks(&calls))
+		_, err := p.UpTo(ctx, 2, WithNoVersioning(), WithDryRun())
+		check.NoError(t, err)
+		check.Number(t, len(calls), 0)
+	})
+}
@@ -1,11 +1,11 @@
 package cfg
 
-import "os"
+import (
+	"os"
+	"strings"
+)
 
 var (
-	GOOSEDRIVER       = envOr("GOOSE_DRIVER", "")
-	GOOSEDBSTRING     = envOr("GOOSE_DBSTRING", "")
-	GOOSEMIGRATIONDIR = envOr("GOOSE_MIGRATION_DIR", DefaultMigrationDir)
 	// https://no-color.org/
 	GOOSENOCOLOR = envOr("NO_COLOR", "false")
 )
@@ -14,19 +14,47 @@ var (
 	DefaultMigrationDir = "."
 )
 
-// An EnvVar is an environment variable Name=Value.
-type EnvVar struct {
-	Name  string
-	Value string
+// Keys of the settings that are not flags.
+const (
+	KeyDriver   = "driver"
+	KeyDBString = "dbstring"
+)
+
+// KeyEnv and KeyConfig are the keys of the -env and -config flags, which can
+// only be set by flags and environment variables.
+const (
+	KeyEnv    = "env"
+	KeyConfig = "config"
+)
+
+// flagKeys maps the single letter flags to the key of their setting.
+var flagKeys = map[string]string{
+	"s": "sequential",
+	"v": "verbose",
+}
+
+// envNames maps the keys whose environment variable predates the
+// GOOSE_<KEY> convention to it.
+var envNames = map[string]string{
+	"dir": "GOOSE_MIGRATION_DIR",
+}
+
+// FlagKey returns the key of the setting of a flag, its name unless it is a
+// single letter.
+func FlagKey(flag string) string {
+	if key, ok := flagKeys[flag]; ok {
+		return key
+	}
+	return flag
 }
 
-func List() []EnvVar {
-	return []EnvVar{
-		{Name: "GOOSE_DRIVER", Value: GOOSEDRIVER},
-		{Name: "GOOSE_DBSTRING", Value: GOOSEDBSTRING},
-		{Name: "GOOSE_MIGRATION_DIR", Value: GOOSEMIGRATIONDIR},
-		{Name: "NO_COLOR", Value: GOOSENOCOLOR},
+// EnvName returns the environment variable of a setting, such as
+// GOOSE_ALLOW_MISSING for allow-missing.
+func EnvName(key string) string {
+	if name, ok := envNames[key]; ok {
+		return name
 	}
+	return "GOOSE_" + strings.ToUpper(strings.ReplaceAll(key, "-", "_"))
 }
 
 // envOr returns os.Getenv(key) if set, or else default.
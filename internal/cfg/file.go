@@ -0,0 +1,447 @@
+package cfg
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ConfigFiles are the configuration files looked up, in order, when no
+// configuration file is given.
+var ConfigFiles = []string{"goose.yaml", "goose.yml", "goose.toml"}
+
+// environmentsKey is the key of the named environments in configuration
+// files.
+const environmentsKey = "environments"
+
+// File is a configuration file: settings by key, such as allow-missing, and
+// named environments overriding them.
+type File struct {
+	Path         string
+	Settings     map[string]string
+	Environments map[string]map[string]string
+}
+
+// ReadFile reads a goose.yaml or goose.toml configuration file, by
+// extension.
+func ReadFile(path string) (*File, error) {
+	by, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var f *File
+	switch filepath.Ext(path) {
+	case ".yaml", ".yml":
+		f, err = parseYAML(by)
+	case ".toml":
+		f, err = parseTOML(string(by))
+	default:
+		return nil, fmt.Errorf("%s: unknown configuration file type, must be .yaml, .yml or .toml", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	f.Path = path
+	return f, nil
+}
+
+func parseYAML(by []byte) (*File, error) {
+	var doc map[string]interface{}
+	if err := yaml.Unmarshal(by, &doc); err != nil {
+		return nil, err
+	}
+	f := &File{Settings: map[string]string{}, Environments: map[string]map[string]string{}}
+	for key, v := range doc {
+		if key != environmentsKey {
+			if err := setScalar(f.Settings, key, v); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		envs, ok := v.(map[string]interface{})
+		if !ok && v != nil {
+			return nil, fmt.Errorf("%s must be a mapping of environment names to settings", environmentsKey)
+		}
+		for name, v := range envs {
+			settings, ok := v.(map[string]interface{})
+			if !ok && v != nil {
+				return nil, fmt.Errorf("environment %s must be a mapping of settings", name)
+			}
+			f.Environments[name] = map[string]string{}
+			for key, v := range settings {
+				if err := setScalar(f.Environments[name], key, v); err != nil {
+					return nil, fmt.Errorf("environment %s: %w", name, err)
+				}
+			}
+		}
+	}
+	return f, nil
+}
+
+func setScalar(settings map[string]string, key string, v interface{}) error {
+	switch v.(type) {
+	case nil:
+	case map[string]interface{}, []interface{}:
+		return fmt.Errorf("%s must be a string, a number or a boolean", key)
+	default:
+		settings[key] = fmt.Sprint(v)
+	}
+	return nil
+}
+
+// parseTOML parses the subset of TOML configuration files need: key = value
+// pairs, values being strings, numbers or booleans, in the root table and in
+// [environments.NAME] tables. Other constructs, such as arrays, inline tables,
+// multi-line strings and dotted keys, are rejected rather than misread.
+func parseTOML(s string) (*File, error) {
+	f := &File{Settings: map[string]string{}, Environments: map[string]map[string]string{}}
+	settings := f.Settings
+	for i, line := range strings.Split(s, "\n") {
+		fail := func(format string, args ...interface{}) error {
+			return fmt.Errorf("line %d: %s", i+1, fmt.Sprintf(format, args...))
+		}
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") {
+			table := strings.TrimSpace(stripComment(line))
+			if strings.HasPrefix(table, "[[") {
+				return nil, fail("arrays of tables are not supported")
+			}
+			if !strings.HasSuffix(table, "]") {
+				return nil, fail("invalid table %s", table)
+			}
+			parts := strings.Split(strings.TrimSpace(table[1:len(table)-1]), ".")
+			if len(parts) != 2 || strings.TrimSpace(parts[0]) != environmentsKey {
+				return nil, fail("unknown table %s, only [%s.NAME] tables are supported", table, environmentsKey)
+			}
+			name, err := tomlKey(strings.TrimSpace(parts[1]))
+			if err != nil {
+				return nil, fail("%v", err)
+			}
+			if _, ok := f.Environments[name]; ok {
+				return nil, fail("table %s defined twice", table)
+			}
+			f.Environments[name] = map[string]string{}
+			settings = f.Environments[name]
+			continue
+		}
+		key, rest, err := splitKeyValue(line)
+		if err != nil {
+			return nil, fail("%v", err)
+		}
+		if _, ok := settings[key]; ok {
+			return nil, fail("%s defined twice", key)
+		}
+		value, err := tomlValue(rest)
+		if err != nil {
+			return nil, fail("%s: %v", key, err)
+		}
+		settings[key] = value
+	}
+	return f, nil
+}
+
+// splitKeyValue splits a key = value line into its key and the remainder.
+func splitKeyValue(line string) (key, rest string, err error) {
+	eq := strings.Index(line, "=")
+	if strings.HasPrefix(line, `"`) {
+		end := closingQuote(line)
+		if end < 0 {
+			return "", "", errors.New("unterminated key")
+		}
+		eq = strings.Index(line[end:], "=")
+		if eq >= 0 {
+			eq += end
+		}
+	}
+	if eq < 0 {
+		return "", "", errors.New("expected key = value")
+	}
+	key, err = tomlKey(strings.TrimSpace(line[:eq]))
+	if err != nil {
+		return "", "", err
+	}
+	return key, strings.TrimSpace(line[eq+1:]), nil
+}
+
+func tomlKey(s string) (string, error) {
+	if strings.HasPrefix(s, `"`) {
+		if closingQuote(s) != len(s)-1 {
+			return "", fmt.Errorf("invalid key %s, dotted keys are not supported", s)
+		}
+		return strconv.Unquote(s)
+	}
+	if s == "" {
+		return "", errors.New("missing key")
+	}
+	for _, r := range s {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_':
+		case r == '.':
+			return "", fmt.Errorf("invalid key %s, dotted keys are not supported", s)
+		default:
+			return "", fmt.Errorf("invalid key %q", s)
+		}
+	}
+	return s, nil
+}
+
+func tomlValue(s string) (string, error) {
+	switch {
+	case strings.HasPrefix(s, `"""`), strings.HasPrefix(s, "'''"):
+		return "", errors.New("multi-line strings are not supported")
+	case strings.HasPrefix(s, "["):
+		return "", errors.New("arrays are not supported, must be a string, a number or a boolean")
+	case strings.HasPrefix(s, "{"):
+		return "", errors.New("inline tables are not supported, must be a string, a number or a boolean")
+	case strings.HasPrefix(s, `"`):
+		end := closingQuote(s)
+		if end < 0 {
+			return "", errors.New("unterminated string")
+		}
+		if rest := strings.TrimSpace(s[end+1:]); rest != "" && !strings.HasPrefix(rest, "#") {
+			return "", fmt.Errorf("unexpected %q after string", rest)
+		}
+		return strconv.Unquote(s[:end+1])
+	case strings.HasPrefix(s, "'"):
+		end := strings.Index(s[1:], "'")
+		if end < 0 {
+			return "", errors.New("unterminated string")
+		}
+		if rest := strings.TrimSpace(s[end+2:]); rest != "" && !strings.HasPrefix(rest, "#") {
+			return "", fmt.Errorf("unexpected %q after string", rest)
+		}
+		return s[1 : end+1], nil
+	}
+	s = strings.TrimSpace(stripComment(s))
+	switch {
+	case s == "true", s == "false":
+		return s, nil
+	case s == "":
+		return "", errors.New("missing value")
+	}
+	if _, err := strconv.ParseFloat(strings.ReplaceAll(s, "_", ""), 64); err != nil {
+		return "", fmt.Errorf("unsupported value %s, must be a string, a number or a boolean", s)
+	}
+	return strings.ReplaceAll(s, "_", ""), nil
+}
+
+// closingQuote returns the index of the quote closing the double quoted
+// string s starts with, or -1.
+func closingQuote(s string) int {
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			return i
+		}
+	}
+	return -1
+}
+
+// stripComment strips a trailing # comment from an unquoted value.
+func stripComment(s string) string {
+	if i := strings.Index(s, "#"); i >= 0 {
+		return s[:i]
+	}
+	return s
+}
+
+// ReadDotenv reads a .env file of NAME=value lines. Lines may start with
+// export, values may be single or double quoted, and # starts a comment.
+func ReadDotenv(r io.Reader) (map[string]string, error) {
+	vars := make(map[string]string)
+	scanner := bufio.NewScanner(r)
+	for i := 1; scanner.Scan(); i++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimSpace(strings.TrimPrefix(line, "export "))
+		eq := strings.Index(line, "=")
+		if eq <= 0 {
+			return nil, fmt.Errorf("line %d: expected NAME=value", i)
+		}
+		name, value := strings.TrimSpace(line[:eq]), strings.TrimSpace(line[eq+1:])
+		switch {
+		case strings.HasPrefix(value, `"`):
+			end := closingQuote(value)
+			if end < 0 {
+				return nil, fmt.Errorf("line %d: unterminated string", i)
+			}
+			unquoted, err := strconv.Unquote(value[:end+1])
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", i, err)
+			}
+			value = unquoted
+		case strings.HasPrefix(value, "'"):
+			end := strings.Index(value[1:], "'")
+			if end < 0 {
+				return nil, fmt.Errorf("line %d: unterminated string", i)
+			}
+			value = value[1 : end+1]
+		default:
+			if j := strings.Index(value, " #"); j >= 0 {
+				value = strings.TrimSpace(value[:j])
+			}
+		}
+		vars[name] = value
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return vars, nil
+}
+
+// Config is the configuration of the goose binary, besides its flags: the
+// environment variables, the .env files and the configuration file.
+type Config struct {
+	lookupEnv func(string) (string, bool)
+	// layers are the files, ordered by decreasing precedence.
+	layers []layer
+	file   *File
+}
+
+type layer struct {
+	source string
+	// byEnvName is true if values are keyed by environment variable name
+	// instead of setting key.
+	byEnvName bool
+	values    map[string]string
+}
+
+// Load reads the configuration of dir. The configuration file is path, or the
+// first of ConfigFiles found in dir if path is empty. env, if set, selects a
+// named environment: the environments.<env> section of the configuration file
+// and the .env.<env> file. lookupEnv looks up environment variables.
+//
+// The .env files are read from the directory of the configuration file, or
+// from dir if there is none.
+//
+// Settings are looked up, in order, in the environment variables, .env.<env>,
+// the environment section of the configuration file, .env and the root of the
+// configuration file.
+func Load(dir, path, env string, lookupEnv func(string) (string, bool)) (*Config, error) {
+	c := &Config{lookupEnv: lookupEnv}
+	if path == "" {
+		for _, name := range ConfigFiles {
+			candidate := filepath.Join(dir, name)
+			if _, err := os.Stat(candidate); err == nil {
+				path = candidate
+				break
+			}
+		}
+	}
+	dotenvDir := dir
+	if path != "" {
+		dotenvDir = filepath.Dir(path)
+	}
+
+	// Layers of the named environment come first.
+	var envLayers, baseLayers []layer
+	if env != "" {
+		l, err := readDotenvLayer(dotenvDir, ".env."+env)
+		if err != nil {
+			return nil, err
+		}
+		if l != nil {
+			envLayers = append(envLayers, *l)
+		}
+	}
+	l, err := readDotenvLayer(dotenvDir, ".env")
+	if err != nil {
+		return nil, err
+	}
+	if l != nil {
+		baseLayers = append(baseLayers, *l)
+	}
+
+	if path != "" {
+		f, err := ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		c.file = f
+		if settings, ok := f.Environments[env]; ok && env != "" {
+			envLayers = append(envLayers, layer{source: fmt.Sprintf("%s [%s]", filepath.Base(path), env), values: settings})
+		}
+		baseLayers = append(baseLayers, layer{source: filepath.Base(path), values: f.Settings})
+	}
+	found := env == "" || len(envLayers) > 0
+	c.layers = append(envLayers, baseLayers...)
+	if !found {
+		return nil, fmt.Errorf("unknown environment %q: not found in .env.%s nor in a configuration file", env, env)
+	}
+	return c, nil
+}
+
+// readDotenvLayer reads the .env file name of dir, nil if it does not exist.
+func readDotenvLayer(dir, name string) (*layer, error) {
+	file, err := os.Open(filepath.Join(dir, name))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	vars, err := ReadDotenv(file)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	return &layer{source: name, byEnvName: true, values: vars}, nil
+}
+
+// Lookup returns the value of the setting key and its source: the environment
+// variable or the file it was found in.
+func (c *Config) Lookup(key string) (value, source string, ok bool) {
+	name := EnvName(key)
+	if value, ok := c.lookupEnv(name); ok && value != "" {
+		return value, name, true
+	}
+	for _, l := range c.layers {
+		lookup := key
+		if l.byEnvName {
+			lookup = name
+		}
+		if value, ok := l.values[lookup]; ok {
+			return value, l.source, true
+		}
+	}
+	return "", "", false
+}
+
+// CheckKeys returns an error if the configuration file has settings whose key
+// is not known, such as misspelled flags.
+func (c *Config) CheckKeys(known map[string]bool) error {
+	if c.file == nil {
+		return nil
+	}
+	var unknown []string
+	check := func(settings map[string]string, prefix string) {
+		for key := range settings {
+			if !known[key] {
+				unknown = append(unknown, prefix+key)
+			}
+		}
+	}
+	check(c.file.Settings, "")
+	for name, settings := range c.file.Environments {
+		check(settings, environmentsKey+"."+name+".")
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return fmt.Errorf("%s: unknown settings: %s", c.file.Path, strings.Join(unknown, ", "))
+	}
+	return nil
+}
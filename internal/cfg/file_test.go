@@ -0,0 +1,196 @@
+package cfg
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/SergeiSkv/goose/v3/internal/check"
+)
+
+func TestParseTOML(t *testing.T) {
+	t.Parallel()
+
+	f, err := parseTOML(`# goose configuration
+driver = "postgres"
+dir = 'db/migrations' # comment
+allow-missing = true
+lock-timeout = "30s"
+tenant-concurrency = 4
+
+[environments.staging]
+dbstring = "postgres://staging/app?sslmode=\"disable\""
+"table" = "staging_versions"
+`)
+	check.NoError(t, err)
+	check.Number(t, len(f.Settings), 5)
+	check.Equal(t, f.Settings["driver"], "postgres")
+	check.Equal(t, f.Settings["dir"], "db/migrations")
+	check.Equal(t, f.Settings["allow-missing"], "true")
+	check.Equal(t, f.Settings["tenant-concurrency"], "4")
+	check.Equal(t, f.Environments["staging"]["dbstring"], `postgres://staging/app?sslmode="disable"`)
+	check.Equal(t, f.Environments["staging"]["table"], "staging_versions")
+
+	for _, s := range []string{
+		"[database]\n",
+		"dir\n",
+		"dir = migrations\n",
+		"dir = \"migrations\n",
+		"dirs = [\"a\", \"b\"]\n",
+		"lock = { timeout = \"30s\" }\n",
+		"dir = \"\"\"migrations\"\"\"\n",
+		"environments.staging.dir = \"db\"\n",
+		"\"dir\".sub = \"db\"\n",
+		"[[environments]]\n",
+		"dir = 1979-05-27\n",
+	} {
+		_, err := parseTOML(s)
+		check.HasError(t, err)
+		check.Contains(t, err.Error(), "line 1")
+	}
+
+	_, err = parseTOML("dir = \"a\"\ndir = \"b\"\n")
+	check.HasError(t, err)
+	check.Contains(t, err.Error(), "line 2: dir defined twice")
+	_, err = parseTOML("[environments.staging]\n[environments.staging]\n")
+	check.HasError(t, err)
+	check.Contains(t, err.Error(), "line 2")
+	f, err = parseTOML(`"dbstring" = "host=localhost"` + "\n")
+	check.NoError(t, err)
+	check.Equal(t, f.Settings["dbstring"], "host=localhost")
+}
+
+func TestParseYAML(t *testing.T) {
+	t.Parallel()
+
+	f, err := parseYAML([]byte(`
+driver: postgres
+allow-missing: true
+lock-timeout: 30s
+environments:
+  staging:
+    dbstring: postgres://staging/app
+    tenant-concurrency: 4
+`))
+	check.NoError(t, err)
+	check.Equal(t, f.Settings["allow-missing"], "true")
+	check.Equal(t, f.Settings["lock-timeout"], "30s")
+	check.Equal(t, f.Environments["staging"]["tenant-concurrency"], "4")
+
+	_, err = parseYAML([]byte("dir: [a, b]\n"))
+	check.HasError(t, err)
+	check.Contains(t, err.Error(), "dir must be a string, a number or a boolean")
+}
+
+func TestReadDotenv(t *testing.T) {
+	t.Parallel()
+
+	vars, err := ReadDotenv(strings.NewReader(`# local settings
+GOOSE_DRIVER=postgres
+export GOOSE_TABLE="versions" # comment
+GOOSE_DBSTRING='postgres://localhost/app?password=a#b'
+GOOSE_DIR=migrations # comment
+`))
+	check.NoError(t, err)
+	check.Number(t, len(vars), 4)
+	check.Equal(t, vars["GOOSE_DRIVER"], "postgres")
+	check.Equal(t, vars["GOOSE_TABLE"], "versions")
+	check.Equal(t, vars["GOOSE_DBSTRING"], "postgres://localhost/app?password=a#b")
+	check.Equal(t, vars["GOOSE_DIR"], "migrations")
+
+	_, err = ReadDotenv(strings.NewReader("GOOSE_DRIVER\n"))
+	check.HasError(t, err)
+}
+
+func TestLoad(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	write := func(name, content string) {
+		t.Helper()
+		check.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0644))
+	}
+	write("goose.yaml", `
+driver: postgres
+dir: migrations
+table: yaml_versions
+allow-missing: true
+environments:
+  staging:
+    table: staging_versions
+    dbstring: postgres://staging/app
+`)
+	write(".env", "GOOSE_TABLE=dotenv_versions\nGOOSE_MIGRATION_DIR=db\n")
+	write(".env.staging", "GOOSE_DBSTRING=postgres://dotenv-staging/app\n")
+	env := map[string]string{"GOOSE_ALLOW_MISSING": "false"}
+	lookupEnv := func(name string) (string, bool) {
+		v, ok := env[name]
+		return v, ok
+	}
+
+	c, err := Load(dir, "", "", lookupEnv)
+	check.NoError(t, err)
+	for _, tc := range []struct {
+		key, value, source string
+	}{
+		{"driver", "postgres", "goose.yaml"},
+		{"dir", "db", ".env"},
+		{"table", "dotenv_versions", ".env"},
+		{"allow-missing", "false", "GOOSE_ALLOW_MISSING"},
+	} {
+		value, source, ok := c.Lookup(tc.key)
+		check.Bool(t, ok, true)
+		check.Equal(t, value, tc.value)
+		check.Equal(t, source, tc.source)
+	}
+	_, _, ok := c.Lookup("dbstring")
+	check.Bool(t, ok, false)
+
+	c, err = Load(dir, "", "staging", lookupEnv)
+	check.NoError(t, err)
+	for _, tc := range []struct {
+		key, value, source string
+	}{
+		{"dbstring", "postgres://dotenv-staging/app", ".env.staging"},
+		{"table", "staging_versions", "goose.yaml [staging]"},
+		{"dir", "db", ".env"},
+	} {
+		value, source, ok := c.Lookup(tc.key)
+		check.Bool(t, ok, true)
+		check.Equal(t, value, tc.value)
+		check.Equal(t, source, tc.source)
+	}
+	check.NoError(t, c.CheckKeys(map[string]bool{"driver": true, "dbstring": true, "dir": true, "table": true, "allow-missing": true}))
+	err = c.CheckKeys(map[string]bool{"driver": true, "dir": true})
+	check.HasError(t, err)
+	check.Contains(t, err.Error(), "unknown settings: allow-missing, environments.staging.dbstring, environments.staging.table, table")
+
+	_, err = Load(dir, "", "production", lookupEnv)
+	check.HasError(t, err)
+	check.Contains(t, err.Error(), `unknown environment "production"`)
+
+	// The .env files next to the configuration file are read, not those of
+	// the working directory.
+	sub := filepath.Join(dir, "config")
+	check.NoError(t, os.Mkdir(sub, 0755))
+	check.NoError(t, os.WriteFile(filepath.Join(sub, "goose.toml"), []byte("driver = \"sqlite3\"\n"), 0644))
+	check.NoError(t, os.WriteFile(filepath.Join(sub, ".env"), []byte("GOOSE_TABLE=config_versions\n"), 0644))
+	c, err = Load(dir, filepath.Join(sub, "goose.toml"), "", lookupEnv)
+	check.NoError(t, err)
+	value, source, ok := c.Lookup("table")
+	check.Bool(t, ok, true)
+	check.Equal(t, value, "config_versions")
+	check.Equal(t, source, ".env")
+	_, _, ok = c.Lookup("dir")
+	check.Bool(t, ok, false)
+}
+
+func TestEnvName(t *testing.T) {
+	t.Parallel()
+
+	check.Equal(t, EnvName("allow-missing"), "GOOSE_ALLOW_MISSING")
+	check.Equal(t, EnvName("dir"), "GOOSE_MIGRATION_DIR")
+	check.Equal(t, EnvName(FlagKey("v")), "GOOSE_VERBOSE")
+	check.Equal(t, EnvName(FlagKey("s")), "GOOSE_SEQUENTIAL")
+}
@@ -13,7 +13,8 @@ func (c *Clickhouse) CreateTable() string {
 		version_id Int64,
 		is_applied UInt8,
 		date Date default now(),
-		tstamp DateTime default now()
+		tstamp DateTime default now(),
+		checksum Nullable(String)
 	  )
 	  ENGINE = MergeTree()
 		ORDER BY (date)`
@@ -21,7 +22,7 @@ func (c *Clickhouse) CreateTable() string {
 }
 
 func (c *Clickhouse) InsertVersion() string {
-	q := `INSERT INTO %s (version_id, is_applied) VALUES ($1, $2)`
+	q := `INSERT INTO %s (version_id, is_applied, checksum) VALUES ($1, $2, $3)`
 	return fmt.Sprintf(q, c.Table)
 }
 
@@ -30,12 +31,73 @@ func (c *Clickhouse) DeleteVersion() string {
 	return fmt.Sprintf(q, c.Table)
 }
 
+// DeleteVersionDuplicates returns an empty string: the version table has no
+// id column, the rows of a version are replaced instead.
+func (c *Clickhouse) DeleteVersionDuplicates() string {
+	return ""
+}
+
 func (c *Clickhouse) GetMigrationByVersion() string {
 	q := `SELECT tstamp, is_applied FROM %s WHERE version_id = $1 ORDER BY tstamp DESC LIMIT 1`
 	return fmt.Sprintf(q, c.Table)
 }
 
 func (c *Clickhouse) ListMigrations() string {
-	q := `SELECT version_id, is_applied FROM %s ORDER BY version_id DESC`
+	q := `SELECT toInt64(0) AS id, version_id, is_applied, checksum FROM %s ORDER BY version_id DESC`
 	return fmt.Sprintf(q, c.Table)
 }
+
+func (c *Clickhouse) UpgradeTable(from int) []string {
+	switch from {
+	case GenerationInitial:
+		return []string{
+			fmt.Sprintf(`ALTER TABLE %s ADD COLUMN IF NOT EXISTS checksum Nullable(String)`, c.Table),
+		}
+	}
+	return nil
+}
+
+// ClickHouse has no table-based lock: without transactions nor unique
+// constraints, it has no atomic primitive two deployers could not both take
+// the lock with. The lock queries are empty, the provider refuses the table
+// lock for ClickHouse.
+
+func (c *Clickhouse) CreateLockTable() string { return "" }
+
+func (c *Clickhouse) InsertLock() string { return "" }
+
+func (c *Clickhouse) AcquireLock() string { return "" }
+
+func (c *Clickhouse) RefreshLock() string { return "" }
+
+func (c *Clickhouse) ReleaseLock() string { return "" }
+
+func (c *Clickhouse) ForceReleaseLock() string { return "" }
+
+func (c *Clickhouse) GetLock() string { return "" }
+
+func (c *Clickhouse) CreateRepeatableTable() string {
+	q := `CREATE TABLE IF NOT EXISTS %s (
+		name String,
+		checksum String,
+		tstamp DateTime default now()
+	  )
+	  ENGINE = MergeTree()
+		ORDER BY (name)`
+	return fmt.Sprintf(q, RepeatableTable(c.Table))
+}
+
+func (c *Clickhouse) InsertRepeatable() string {
+	q := `INSERT INTO %s (name, checksum) VALUES ($1, $2)`
+	return fmt.Sprintf(q, RepeatableTable(c.Table))
+}
+
+func (c *Clickhouse) DeleteRepeatable() string {
+	q := `ALTER TABLE %s DELETE WHERE name = $1 SETTINGS mutations_sync = 2`
+	return fmt.Sprintf(q, RepeatableTable(c.Table))
+}
+
+func (c *Clickhouse) ListRepeatable() string {
+	q := `SELECT name, checksum FROM %s ORDER BY name`
+	return fmt.Sprintf(q, RepeatableTable(c.Table))
+}
@@ -1,5 +1,35 @@
 package dialectquery
 
+// Generations of the db version table schema. Each generation adds columns to
+// the previous one. CreateTable creates the latest generation, tables created
+// by older versions of goose are brought up to date with UpgradeTable.
+const (
+	// GenerationInitial has the id, version_id, is_applied and tstamp
+	// columns.
+	GenerationInitial = 1
+	// GenerationChecksum adds the checksum column.
+	GenerationChecksum = 2
+
+	LatestGeneration = GenerationChecksum
+)
+
+// GenerationColumns maps each generation to a column it introduced, they are
+// used to detect the generation of an existing table.
+var GenerationColumns = map[int]string{
+	GenerationInitial:  "version_id",
+	GenerationChecksum: "checksum",
+}
+
+// LockTable is the name of the table holding the table-based migration locks,
+// one row per version table.
+const LockTable = "goose_lock"
+
+// RepeatableTable returns the name of the table tracking the repeatable
+// migrations applied along with the given db version table.
+func RepeatableTable(table string) string {
+	return table + "_repeatable"
+}
+
 // Querier is the interface that wraps the basic methods to create a dialect
 // specific query.
 type Querier interface {
@@ -8,12 +38,21 @@ type Querier interface {
 
 	// InsertVersion returns the SQL query string to insert a new version into
 	// the db version table.
+	//
+	// The query takes the version_id, is_applied and checksum arguments.
 	InsertVersion() string
 
 	// DeleteVersion returns the SQL query string to delete a version from
 	// the db version table.
 	DeleteVersion() string
 
+	// DeleteVersionDuplicates returns the SQL query string to delete the rows
+	// of a version recorded before a given row, or an empty string if the db
+	// version table has no id column.
+	//
+	// The query takes the version_id and id arguments.
+	DeleteVersionDuplicates() string
+
 	// GetMigrationByVersion returns the SQL query string to get a single
 	// migration by version.
 	//
@@ -23,6 +62,76 @@ type Querier interface {
 	// ListMigrations returns the SQL query string to list all migrations in
 	// descending order by id.
 	//
-	// The query should return the version_id and is_applied columns.
+	// The query should return the id, version_id, is_applied and checksum
+	// columns. Tables without an id column return 0 and are ordered by
+	// version_id instead.
 	ListMigrations() string
+
+	// UpgradeTable returns the SQL query strings that upgrade the db version
+	// table from the given schema generation to the next one, and nil for
+	// LatestGeneration.
+	UpgradeTable(from int) []string
+
+	// CreateLockTable returns the SQL query string to create the lock table,
+	// if it does not exist.
+	CreateLockTable() string
+
+	// InsertLock returns the SQL query string to insert a released lock row,
+	// unless it already exists.
+	//
+	// The query takes the lock_id and expires_at arguments.
+	InsertLock() string
+
+	// AcquireLock returns the SQL query string to take the lock row, if it
+	// is released or its lease has expired.
+	//
+	// The query takes the owner, new expires_at, lock_id and current time
+	// arguments.
+	AcquireLock() string
+
+	// RefreshLock returns the SQL query string to extend the lease of a lock
+	// row held by owner.
+	//
+	// The query takes the new expires_at, lock_id and owner arguments.
+	RefreshLock() string
+
+	// ReleaseLock returns the SQL query string to release a lock row held by
+	// owner.
+	//
+	// The query takes the lock_id and owner arguments.
+	ReleaseLock() string
+
+	// ForceReleaseLock returns the SQL query string to release a lock row
+	// regardless of its owner.
+	//
+	// The query takes the lock_id argument.
+	ForceReleaseLock() string
+
+	// GetLock returns the SQL query string to get a lock row.
+	//
+	// The query takes the lock_id argument and should return the owner and
+	// expires_at columns. A released lock has an empty owner.
+	GetLock() string
+
+	// CreateRepeatableTable returns the SQL query string to create the
+	// repeatable migrations table, if it does not exist.
+	CreateRepeatableTable() string
+
+	// InsertRepeatable returns the SQL query string to record a repeatable
+	// migration.
+	//
+	// The query takes the name and checksum arguments.
+	InsertRepeatable() string
+
+	// DeleteRepeatable returns the SQL query string to delete the record of a
+	// repeatable migration.
+	//
+	// The query takes the name argument.
+	DeleteRepeatable() string
+
+	// ListRepeatable returns the SQL query string to list the repeatable
+	// migrations that have been applied.
+	//
+	// The query should return the name and checksum columns.
+	ListRepeatable() string
 }
@@ -0,0 +1,35 @@
+package dialectquery
+
+import (
+	"testing"
+
+	"github.com/SergeiSkv/goose/v3/internal/check"
+)
+
+func TestUpgradeTable(t *testing.T) {
+	t.Parallel()
+
+	queriers := map[string]Querier{
+		"postgres":   &Postgres{Table: "goose_db_version"},
+		"mysql":      &Mysql{Table: "goose_db_version"},
+		"sqlite3":    &Sqlite3{Table: "goose_db_version"},
+		"sqlserver":  &Sqlserver{Table: "goose_db_version"},
+		"redshift":   &Redshift{Table: "goose_db_version"},
+		"tidb":       &Tidb{Table: "goose_db_version"},
+		"clickhouse": &Clickhouse{Table: "goose_db_version"},
+		"vertica":    &Vertica{Table: "goose_db_version"},
+	}
+	for name, q := range queriers {
+		for g := GenerationInitial; g < LatestGeneration; g++ {
+			if len(q.UpgradeTable(g)) == 0 {
+				t.Errorf("%s: no upgrade from generation %d", name, g)
+			}
+		}
+		check.Number(t, len(q.UpgradeTable(LatestGeneration)), 0)
+	}
+	for g := GenerationInitial; g <= LatestGeneration; g++ {
+		if GenerationColumns[g] == "" {
+			t.Errorf("no column to detect generation %d", g)
+		}
+	}
+}
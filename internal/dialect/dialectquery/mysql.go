@@ -14,13 +14,14 @@ func (m *Mysql) CreateTable() string {
 		version_id bigint NOT NULL,
 		is_applied boolean NOT NULL,
 		tstamp timestamp NULL default now(),
+		checksum varchar(64) NULL,
 		PRIMARY KEY(id)
 	)`
 	return fmt.Sprintf(q, m.Table)
 }
 
 func (m *Mysql) InsertVersion() string {
-	q := `INSERT INTO %s (version_id, is_applied) VALUES (?, ?)`
+	q := `INSERT INTO %s (version_id, is_applied, checksum) VALUES (?, ?, ?)`
 	return fmt.Sprintf(q, m.Table)
 }
 
@@ -29,12 +30,92 @@ func (m *Mysql) DeleteVersion() string {
 	return fmt.Sprintf(q, m.Table)
 }
 
+func (m *Mysql) DeleteVersionDuplicates() string {
+	q := `DELETE FROM %s WHERE version_id=? AND id<?`
+	return fmt.Sprintf(q, m.Table)
+}
+
 func (m *Mysql) GetMigrationByVersion() string {
 	q := `SELECT tstamp, is_applied FROM %s WHERE version_id=? ORDER BY tstamp DESC LIMIT 1`
 	return fmt.Sprintf(q, m.Table)
 }
 
 func (m *Mysql) ListMigrations() string {
-	q := `SELECT version_id, is_applied from %s ORDER BY id DESC`
+	q := `SELECT id, version_id, is_applied, checksum from %s ORDER BY id DESC`
 	return fmt.Sprintf(q, m.Table)
 }
+
+func (m *Mysql) UpgradeTable(from int) []string {
+	switch from {
+	case GenerationInitial:
+		return []string{
+			fmt.Sprintf(`ALTER TABLE %s ADD COLUMN checksum varchar(64) NULL`, m.Table),
+		}
+	}
+	return nil
+}
+
+func (m *Mysql) CreateLockTable() string {
+	q := `CREATE TABLE IF NOT EXISTS %s (
+		lock_id varchar(255) NOT NULL,
+		owner varchar(255) NOT NULL default '',
+		expires_at timestamp(6) NOT NULL,
+		PRIMARY KEY(lock_id)
+	)`
+	return fmt.Sprintf(q, LockTable)
+}
+
+func (m *Mysql) InsertLock() string {
+	q := `INSERT IGNORE INTO %s (lock_id, owner, expires_at) VALUES (?, '', ?)`
+	return fmt.Sprintf(q, LockTable)
+}
+
+func (m *Mysql) AcquireLock() string {
+	q := `UPDATE %s SET owner=?, expires_at=? WHERE lock_id=? AND (owner='' OR expires_at<?)`
+	return fmt.Sprintf(q, LockTable)
+}
+
+func (m *Mysql) RefreshLock() string {
+	q := `UPDATE %s SET expires_at=? WHERE lock_id=? AND owner=?`
+	return fmt.Sprintf(q, LockTable)
+}
+
+func (m *Mysql) ReleaseLock() string {
+	q := `UPDATE %s SET owner='' WHERE lock_id=? AND owner=?`
+	return fmt.Sprintf(q, LockTable)
+}
+
+func (m *Mysql) ForceReleaseLock() string {
+	q := `UPDATE %s SET owner='' WHERE lock_id=?`
+	return fmt.Sprintf(q, LockTable)
+}
+
+func (m *Mysql) GetLock() string {
+	q := `SELECT owner, expires_at FROM %s WHERE lock_id=?`
+	return fmt.Sprintf(q, LockTable)
+}
+
+func (m *Mysql) CreateRepeatableTable() string {
+	q := `CREATE TABLE IF NOT EXISTS %s (
+		name varchar(255) NOT NULL,
+		checksum varchar(64) NOT NULL,
+		tstamp timestamp NULL default now(),
+		PRIMARY KEY(name)
+	)`
+	return fmt.Sprintf(q, RepeatableTable(m.Table))
+}
+
+func (m *Mysql) InsertRepeatable() string {
+	q := `INSERT INTO %s (name, checksum) VALUES (?, ?)`
+	return fmt.Sprintf(q, RepeatableTable(m.Table))
+}
+
+func (m *Mysql) DeleteRepeatable() string {
+	q := `DELETE FROM %s WHERE name=?`
+	return fmt.Sprintf(q, RepeatableTable(m.Table))
+}
+
+func (m *Mysql) ListRepeatable() string {
+	q := `SELECT name, checksum FROM %s ORDER BY name`
+	return fmt.Sprintf(q, RepeatableTable(m.Table))
+}
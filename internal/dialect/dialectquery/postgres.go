@@ -14,13 +14,14 @@ func (p *Postgres) CreateTable() string {
 		version_id bigint NOT NULL,
 		is_applied boolean NOT NULL,
 		tstamp timestamp NULL default now(),
+		checksum varchar(64) NULL,
 		PRIMARY KEY(id)
 	)`
 	return fmt.Sprintf(q, p.Table)
 }
 
 func (p *Postgres) InsertVersion() string {
-	q := `INSERT INTO %s (version_id, is_applied) VALUES ($1, $2)`
+	q := `INSERT INTO %s (version_id, is_applied, checksum) VALUES ($1, $2, $3)`
 	return fmt.Sprintf(q, p.Table)
 }
 
@@ -29,12 +30,92 @@ func (p *Postgres) DeleteVersion() string {
 	return fmt.Sprintf(q, p.Table)
 }
 
+func (p *Postgres) DeleteVersionDuplicates() string {
+	q := `DELETE FROM %s WHERE version_id=$1 AND id<$2`
+	return fmt.Sprintf(q, p.Table)
+}
+
 func (p *Postgres) GetMigrationByVersion() string {
 	q := `SELECT tstamp, is_applied FROM %s WHERE version_id=$1 ORDER BY tstamp DESC LIMIT 1`
 	return fmt.Sprintf(q, p.Table)
 }
 
 func (p *Postgres) ListMigrations() string {
-	q := `SELECT version_id, is_applied from %s ORDER BY id DESC`
+	q := `SELECT id, version_id, is_applied, checksum from %s ORDER BY id DESC`
 	return fmt.Sprintf(q, p.Table)
 }
+
+func (p *Postgres) UpgradeTable(from int) []string {
+	switch from {
+	case GenerationInitial:
+		return []string{
+			fmt.Sprintf(`ALTER TABLE %s ADD COLUMN IF NOT EXISTS checksum varchar(64) NULL`, p.Table),
+		}
+	}
+	return nil
+}
+
+func (p *Postgres) CreateLockTable() string {
+	q := `CREATE TABLE IF NOT EXISTS %s (
+		lock_id varchar(255) NOT NULL,
+		owner varchar(255) NOT NULL default '',
+		expires_at timestamptz NOT NULL,
+		PRIMARY KEY(lock_id)
+	)`
+	return fmt.Sprintf(q, LockTable)
+}
+
+func (p *Postgres) InsertLock() string {
+	q := `INSERT INTO %s (lock_id, owner, expires_at) VALUES ($1, '', $2) ON CONFLICT (lock_id) DO NOTHING`
+	return fmt.Sprintf(q, LockTable)
+}
+
+func (p *Postgres) AcquireLock() string {
+	q := `UPDATE %s SET owner=$1, expires_at=$2 WHERE lock_id=$3 AND (owner='' OR expires_at<$4)`
+	return fmt.Sprintf(q, LockTable)
+}
+
+func (p *Postgres) RefreshLock() string {
+	q := `UPDATE %s SET expires_at=$1 WHERE lock_id=$2 AND owner=$3`
+	return fmt.Sprintf(q, LockTable)
+}
+
+func (p *Postgres) ReleaseLock() string {
+	q := `UPDATE %s SET owner='' WHERE lock_id=$1 AND owner=$2`
+	return fmt.Sprintf(q, LockTable)
+}
+
+func (p *Postgres) ForceReleaseLock() string {
+	q := `UPDATE %s SET owner='' WHERE lock_id=$1`
+	return fmt.Sprintf(q, LockTable)
+}
+
+func (p *Postgres) GetLock() string {
+	q := `SELECT owner, expires_at FROM %s WHERE lock_id=$1`
+	return fmt.Sprintf(q, LockTable)
+}
+
+func (p *Postgres) CreateRepeatableTable() string {
+	q := `CREATE TABLE IF NOT EXISTS %s (
+		name varchar(255) NOT NULL,
+		checksum varchar(64) NOT NULL,
+		tstamp timestamp NULL default now(),
+		PRIMARY KEY(name)
+	)`
+	return fmt.Sprintf(q, RepeatableTable(p.Table))
+}
+
+func (p *Postgres) InsertRepeatable() string {
+	q := `INSERT INTO %s (name, checksum) VALUES ($1, $2)`
+	return fmt.Sprintf(q, RepeatableTable(p.Table))
+}
+
+func (p *Postgres) DeleteRepeatable() string {
+	q := `DELETE FROM %s WHERE name=$1`
+	return fmt.Sprintf(q, RepeatableTable(p.Table))
+}
+
+func (p *Postgres) ListRepeatable() string {
+	q := `SELECT name, checksum FROM %s ORDER BY name`
+	return fmt.Sprintf(q, RepeatableTable(p.Table))
+}
@@ -14,13 +14,14 @@ func (r *Redshift) CreateTable() string {
 		version_id bigint NOT NULL,
 		is_applied boolean NOT NULL,
 		tstamp timestamp NULL default sysdate,
+		checksum varchar(64) NULL,
 		PRIMARY KEY(id)
 	)`
 	return fmt.Sprintf(q, r.Table)
 }
 
 func (r *Redshift) InsertVersion() string {
-	q := `INSERT INTO %s (version_id, is_applied) VALUES ($1, $2)`
+	q := `INSERT INTO %s (version_id, is_applied, checksum) VALUES ($1, $2, $3)`
 	return fmt.Sprintf(q, r.Table)
 }
 
@@ -29,12 +30,92 @@ func (r *Redshift) DeleteVersion() string {
 	return fmt.Sprintf(q, r.Table)
 }
 
+func (r *Redshift) DeleteVersionDuplicates() string {
+	q := `DELETE FROM %s WHERE version_id=$1 AND id<$2`
+	return fmt.Sprintf(q, r.Table)
+}
+
 func (r *Redshift) GetMigrationByVersion() string {
 	q := `SELECT tstamp, is_applied FROM %s WHERE version_id=$1 ORDER BY tstamp DESC LIMIT 1`
 	return fmt.Sprintf(q, r.Table)
 }
 
 func (r *Redshift) ListMigrations() string {
-	q := `SELECT version_id, is_applied from %s ORDER BY id DESC`
+	q := `SELECT id, version_id, is_applied, checksum from %s ORDER BY id DESC`
 	return fmt.Sprintf(q, r.Table)
 }
+
+func (r *Redshift) UpgradeTable(from int) []string {
+	switch from {
+	case GenerationInitial:
+		return []string{
+			fmt.Sprintf(`ALTER TABLE %s ADD COLUMN checksum varchar(64) NULL`, r.Table),
+		}
+	}
+	return nil
+}
+
+func (r *Redshift) CreateLockTable() string {
+	q := `CREATE TABLE IF NOT EXISTS %s (
+		lock_id varchar(255) NOT NULL,
+		owner varchar(255) NOT NULL default '',
+		expires_at timestamptz NOT NULL,
+		PRIMARY KEY(lock_id)
+	)`
+	return fmt.Sprintf(q, LockTable)
+}
+
+func (r *Redshift) InsertLock() string {
+	q := `INSERT INTO %[1]s (lock_id, owner, expires_at) SELECT $1, '', $2 WHERE NOT EXISTS (SELECT 1 FROM %[1]s WHERE lock_id=$1)`
+	return fmt.Sprintf(q, LockTable)
+}
+
+func (r *Redshift) AcquireLock() string {
+	q := `UPDATE %s SET owner=$1, expires_at=$2 WHERE lock_id=$3 AND (owner='' OR expires_at<$4)`
+	return fmt.Sprintf(q, LockTable)
+}
+
+func (r *Redshift) RefreshLock() string {
+	q := `UPDATE %s SET expires_at=$1 WHERE lock_id=$2 AND owner=$3`
+	return fmt.Sprintf(q, LockTable)
+}
+
+func (r *Redshift) ReleaseLock() string {
+	q := `UPDATE %s SET owner='' WHERE lock_id=$1 AND owner=$2`
+	return fmt.Sprintf(q, LockTable)
+}
+
+func (r *Redshift) ForceReleaseLock() string {
+	q := `UPDATE %s SET owner='' WHERE lock_id=$1`
+	return fmt.Sprintf(q, LockTable)
+}
+
+func (r *Redshift) GetLock() string {
+	q := `SELECT owner, expires_at FROM %s WHERE lock_id=$1`
+	return fmt.Sprintf(q, LockTable)
+}
+
+func (r *Redshift) CreateRepeatableTable() string {
+	q := `CREATE TABLE IF NOT EXISTS %s (
+		name varchar(255) NOT NULL,
+		checksum varchar(64) NOT NULL,
+		tstamp timestamp NULL default sysdate,
+		PRIMARY KEY(name)
+	)`
+	return fmt.Sprintf(q, RepeatableTable(r.Table))
+}
+
+func (r *Redshift) InsertRepeatable() string {
+	q := `INSERT INTO %s (name, checksum) VALUES ($1, $2)`
+	return fmt.Sprintf(q, RepeatableTable(r.Table))
+}
+
+func (r *Redshift) DeleteRepeatable() string {
+	q := `DELETE FROM %s WHERE name=$1`
+	return fmt.Sprintf(q, RepeatableTable(r.Table))
+}
+
+func (r *Redshift) ListRepeatable() string {
+	q := `SELECT name, checksum FROM %s ORDER BY name`
+	return fmt.Sprintf(q, RepeatableTable(r.Table))
+}
@@ -13,13 +13,14 @@ func (s *Sqlite3) CreateTable() string {
 		id INTEGER PRIMARY KEY AUTOINCREMENT,
 		version_id INTEGER NOT NULL,
 		is_applied INTEGER NOT NULL,
-		tstamp TIMESTAMP DEFAULT (datetime('now'))
+		tstamp TIMESTAMP DEFAULT (datetime('now')),
+		checksum TEXT
 	)`
 	return fmt.Sprintf(q, s.Table)
 }
 
 func (s *Sqlite3) InsertVersion() string {
-	q := `INSERT INTO %s (version_id, is_applied) VALUES (?, ?)`
+	q := `INSERT INTO %s (version_id, is_applied, checksum) VALUES (?, ?, ?)`
 	return fmt.Sprintf(q, s.Table)
 }
 
@@ -28,12 +29,90 @@ func (s *Sqlite3) DeleteVersion() string {
 	return fmt.Sprintf(q, s.Table)
 }
 
+func (s *Sqlite3) DeleteVersionDuplicates() string {
+	q := `DELETE FROM %s WHERE version_id=? AND id<?`
+	return fmt.Sprintf(q, s.Table)
+}
+
 func (s *Sqlite3) GetMigrationByVersion() string {
 	q := `SELECT tstamp, is_applied FROM %s WHERE version_id=? ORDER BY tstamp DESC LIMIT 1`
 	return fmt.Sprintf(q, s.Table)
 }
 
 func (s *Sqlite3) ListMigrations() string {
-	q := `SELECT version_id, is_applied from %s ORDER BY id DESC`
+	q := `SELECT id, version_id, is_applied, checksum from %s ORDER BY id DESC`
 	return fmt.Sprintf(q, s.Table)
 }
+
+func (s *Sqlite3) UpgradeTable(from int) []string {
+	switch from {
+	case GenerationInitial:
+		return []string{
+			fmt.Sprintf(`ALTER TABLE %s ADD COLUMN checksum TEXT`, s.Table),
+		}
+	}
+	return nil
+}
+
+func (s *Sqlite3) CreateLockTable() string {
+	q := `CREATE TABLE IF NOT EXISTS %s (
+		lock_id TEXT NOT NULL PRIMARY KEY,
+		owner TEXT NOT NULL DEFAULT '',
+		expires_at TIMESTAMP NOT NULL
+	)`
+	return fmt.Sprintf(q, LockTable)
+}
+
+func (s *Sqlite3) InsertLock() string {
+	q := `INSERT OR IGNORE INTO %s (lock_id, owner, expires_at) VALUES (?, '', ?)`
+	return fmt.Sprintf(q, LockTable)
+}
+
+func (s *Sqlite3) AcquireLock() string {
+	q := `UPDATE %s SET owner=?, expires_at=? WHERE lock_id=? AND (owner='' OR expires_at<?)`
+	return fmt.Sprintf(q, LockTable)
+}
+
+func (s *Sqlite3) RefreshLock() string {
+	q := `UPDATE %s SET expires_at=? WHERE lock_id=? AND owner=?`
+	return fmt.Sprintf(q, LockTable)
+}
+
+func (s *Sqlite3) ReleaseLock() string {
+	q := `UPDATE %s SET owner='' WHERE lock_id=? AND owner=?`
+	return fmt.Sprintf(q, LockTable)
+}
+
+func (s *Sqlite3) ForceReleaseLock() string {
+	q := `UPDATE %s SET owner='' WHERE lock_id=?`
+	return fmt.Sprintf(q, LockTable)
+}
+
+func (s *Sqlite3) GetLock() string {
+	q := `SELECT owner, expires_at FROM %s WHERE lock_id=?`
+	return fmt.Sprintf(q, LockTable)
+}
+
+func (s *Sqlite3) CreateRepeatableTable() string {
+	q := `CREATE TABLE IF NOT EXISTS %s (
+		name TEXT NOT NULL PRIMARY KEY,
+		checksum TEXT NOT NULL,
+		tstamp TIMESTAMP DEFAULT (datetime('now'))
+	)`
+	return fmt.Sprintf(q, RepeatableTable(s.Table))
+}
+
+func (s *Sqlite3) InsertRepeatable() string {
+	q := `INSERT INTO %s (name, checksum) VALUES (?, ?)`
+	return fmt.Sprintf(q, RepeatableTable(s.Table))
+}
+
+func (s *Sqlite3) DeleteRepeatable() string {
+	q := `DELETE FROM %s WHERE name=?`
+	return fmt.Sprintf(q, RepeatableTable(s.Table))
+}
+
+func (s *Sqlite3) ListRepeatable() string {
+	q := `SELECT name, checksum FROM %s ORDER BY name`
+	return fmt.Sprintf(q, RepeatableTable(s.Table))
+}
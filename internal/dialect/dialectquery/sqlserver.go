@@ -13,13 +13,14 @@ func (s *Sqlserver) CreateTable() string {
 		id INT NOT NULL IDENTITY(1,1) PRIMARY KEY,
 		version_id BIGINT NOT NULL,
 		is_applied BIT NOT NULL,
-		tstamp DATETIME NULL DEFAULT CURRENT_TIMESTAMP
+		tstamp DATETIME NULL DEFAULT CURRENT_TIMESTAMP,
+		checksum NVARCHAR(64) NULL
 	)`
 	return fmt.Sprintf(q, s.Table)
 }
 
 func (s *Sqlserver) InsertVersion() string {
-	q := `INSERT INTO %s (version_id, is_applied) VALUES (@p1, @p2)`
+	q := `INSERT INTO %s (version_id, is_applied, checksum) VALUES (@p1, @p2, @p3)`
 	return fmt.Sprintf(q, s.Table)
 }
 
@@ -28,6 +29,11 @@ func (s *Sqlserver) DeleteVersion() string {
 	return fmt.Sprintf(q, s.Table)
 }
 
+func (s *Sqlserver) DeleteVersionDuplicates() string {
+	q := `DELETE FROM %s WHERE version_id=@p1 AND id<@p2`
+	return fmt.Sprintf(q, s.Table)
+}
+
 func (s *Sqlserver) GetMigrationByVersion() string {
 	q := `
 WITH Migrations AS
@@ -46,6 +52,84 @@ ORDER BY tstamp DESC
 }
 
 func (s *Sqlserver) ListMigrations() string {
-	q := `SELECT version_id, is_applied FROM %s ORDER BY id DESC`
+	q := `SELECT id, version_id, is_applied, checksum FROM %s ORDER BY id DESC`
 	return fmt.Sprintf(q, s.Table)
 }
+
+func (s *Sqlserver) UpgradeTable(from int) []string {
+	switch from {
+	case GenerationInitial:
+		return []string{
+			fmt.Sprintf(`ALTER TABLE %s ADD checksum NVARCHAR(64) NULL`, s.Table),
+		}
+	}
+	return nil
+}
+
+func (s *Sqlserver) CreateLockTable() string {
+	q := `IF OBJECT_ID(N'%[1]s', N'U') IS NULL
+CREATE TABLE %[1]s (
+	lock_id NVARCHAR(255) NOT NULL PRIMARY KEY,
+	owner NVARCHAR(255) NOT NULL DEFAULT '',
+	expires_at DATETIME2 NOT NULL
+)`
+	return fmt.Sprintf(q, LockTable)
+}
+
+func (s *Sqlserver) InsertLock() string {
+	q := `MERGE %s WITH (HOLDLOCK) AS t
+USING (SELECT @p1 AS lock_id, @p2 AS expires_at) AS s
+ON t.lock_id = s.lock_id
+WHEN NOT MATCHED THEN INSERT (lock_id, owner, expires_at) VALUES (s.lock_id, '', s.expires_at);`
+	return fmt.Sprintf(q, LockTable)
+}
+
+func (s *Sqlserver) AcquireLock() string {
+	q := `UPDATE %s SET owner=@p1, expires_at=@p2 WHERE lock_id=@p3 AND (owner='' OR expires_at<@p4)`
+	return fmt.Sprintf(q, LockTable)
+}
+
+func (s *Sqlserver) RefreshLock() string {
+	q := `UPDATE %s SET expires_at=@p1 WHERE lock_id=@p2 AND owner=@p3`
+	return fmt.Sprintf(q, LockTable)
+}
+
+func (s *Sqlserver) ReleaseLock() string {
+	q := `UPDATE %s SET owner='' WHERE lock_id=@p1 AND owner=@p2`
+	return fmt.Sprintf(q, LockTable)
+}
+
+func (s *Sqlserver) ForceReleaseLock() string {
+	q := `UPDATE %s SET owner='' WHERE lock_id=@p1`
+	return fmt.Sprintf(q, LockTable)
+}
+
+func (s *Sqlserver) GetLock() string {
+	q := `SELECT owner, expires_at FROM %s WHERE lock_id=@p1`
+	return fmt.Sprintf(q, LockTable)
+}
+
+func (s *Sqlserver) CreateRepeatableTable() string {
+	q := `IF OBJECT_ID(N'%[1]s', N'U') IS NULL
+CREATE TABLE %[1]s (
+	name NVARCHAR(255) NOT NULL PRIMARY KEY,
+	checksum NVARCHAR(64) NOT NULL,
+	tstamp DATETIME NULL DEFAULT CURRENT_TIMESTAMP
+)`
+	return fmt.Sprintf(q, RepeatableTable(s.Table))
+}
+
+func (s *Sqlserver) InsertRepeatable() string {
+	q := `INSERT INTO %s (name, checksum) VALUES (@p1, @p2)`
+	return fmt.Sprintf(q, RepeatableTable(s.Table))
+}
+
+func (s *Sqlserver) DeleteRepeatable() string {
+	q := `DELETE FROM %s WHERE name=@p1`
+	return fmt.Sprintf(q, RepeatableTable(s.Table))
+}
+
+func (s *Sqlserver) ListRepeatable() string {
+	q := `SELECT name, checksum FROM %s ORDER BY name`
+	return fmt.Sprintf(q, RepeatableTable(s.Table))
+}
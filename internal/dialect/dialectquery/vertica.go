@@ -14,13 +14,14 @@ func (v *Vertica) CreateTable() string {
 		version_id bigint NOT NULL,
 		is_applied boolean NOT NULL,
 		tstamp timestamp NULL default now(),
+		checksum varchar(64) NULL,
 		PRIMARY KEY(id)
 	)`
 	return fmt.Sprintf(q, v.Table)
 }
 
 func (v *Vertica) InsertVersion() string {
-	q := `INSERT INTO %s (version_id, is_applied) VALUES (?, ?)`
+	q := `INSERT INTO %s (version_id, is_applied, checksum) VALUES (?, ?, ?)`
 	return fmt.Sprintf(q, v.Table)
 }
 
@@ -29,12 +30,95 @@ func (v *Vertica) DeleteVersion() string {
 	return fmt.Sprintf(q, v.Table)
 }
 
+func (v *Vertica) DeleteVersionDuplicates() string {
+	q := `DELETE FROM %s WHERE version_id=? AND id<?`
+	return fmt.Sprintf(q, v.Table)
+}
+
 func (v *Vertica) GetMigrationByVersion() string {
 	q := `SELECT tstamp, is_applied FROM %s WHERE version_id=? ORDER BY tstamp DESC LIMIT 1`
 	return fmt.Sprintf(q, v.Table)
 }
 
 func (v *Vertica) ListMigrations() string {
-	q := `SELECT version_id, is_applied from %s ORDER BY id DESC`
+	q := `SELECT id, version_id, is_applied, checksum from %s ORDER BY id DESC`
 	return fmt.Sprintf(q, v.Table)
 }
+
+func (v *Vertica) UpgradeTable(from int) []string {
+	switch from {
+	case GenerationInitial:
+		return []string{
+			fmt.Sprintf(`ALTER TABLE %s ADD COLUMN IF NOT EXISTS checksum varchar(64) NULL`, v.Table),
+		}
+	}
+	return nil
+}
+
+func (v *Vertica) CreateLockTable() string {
+	q := `CREATE TABLE IF NOT EXISTS %s (
+		lock_id varchar(255) NOT NULL,
+		owner varchar(255) NOT NULL default '',
+		expires_at timestamptz NOT NULL,
+		PRIMARY KEY(lock_id) ENABLED
+	)`
+	return fmt.Sprintf(q, LockTable)
+}
+
+func (v *Vertica) InsertLock() string {
+	q := `MERGE INTO %s t
+USING (SELECT ? AS lock_id, ? AS expires_at) s
+ON t.lock_id = s.lock_id
+WHEN NOT MATCHED THEN INSERT (lock_id, owner, expires_at) VALUES (s.lock_id, '', s.expires_at)`
+	return fmt.Sprintf(q, LockTable)
+}
+
+func (v *Vertica) AcquireLock() string {
+	q := `UPDATE %s SET owner=?, expires_at=? WHERE lock_id=? AND (owner='' OR expires_at<?)`
+	return fmt.Sprintf(q, LockTable)
+}
+
+func (v *Vertica) RefreshLock() string {
+	q := `UPDATE %s SET expires_at=? WHERE lock_id=? AND owner=?`
+	return fmt.Sprintf(q, LockTable)
+}
+
+func (v *Vertica) ReleaseLock() string {
+	q := `UPDATE %s SET owner='' WHERE lock_id=? AND owner=?`
+	return fmt.Sprintf(q, LockTable)
+}
+
+func (v *Vertica) ForceReleaseLock() string {
+	q := `UPDATE %s SET owner='' WHERE lock_id=?`
+	return fmt.Sprintf(q, LockTable)
+}
+
+func (v *Vertica) GetLock() string {
+	q := `SELECT owner, expires_at FROM %s WHERE lock_id=?`
+	return fmt.Sprintf(q, LockTable)
+}
+
+func (v *Vertica) CreateRepeatableTable() string {
+	q := `CREATE TABLE IF NOT EXISTS %s (
+		name varchar(255) NOT NULL,
+		checksum varchar(64) NOT NULL,
+		tstamp timestamp NULL default now(),
+		PRIMARY KEY(name) ENABLED
+	)`
+	return fmt.Sprintf(q, RepeatableTable(v.Table))
+}
+
+func (v *Vertica) InsertRepeatable() string {
+	q := `INSERT INTO %s (name, checksum) VALUES (?, ?)`
+	return fmt.Sprintf(q, RepeatableTable(v.Table))
+}
+
+func (v *Vertica) DeleteRepeatable() string {
+	q := `DELETE FROM %s WHERE name=?`
+	return fmt.Sprintf(q, RepeatableTable(v.Table))
+}
+
+func (v *Vertica) ListRepeatable() string {
+	q := `SELECT name, checksum FROM %s ORDER BY name`
+	return fmt.Sprintf(q, RepeatableTable(v.Table))
+}
@@ -4,12 +4,23 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/SergeiSkv/goose/v3/internal/dialect/dialectquery"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 )
 
+// Queryer runs statements on a database connection. *pgx.Conn implements it,
+// as do the database/sql adapters of the goose package.
+type Queryer interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
 // Store is the interface that wraps the basic methods for a database dialect.
 //
 // A dialect is a set of SQL statements that are specific to a database.
@@ -25,25 +36,91 @@ type Store interface {
 	CreateVersionTable(ctx context.Context, tx pgx.Tx) error
 
 	// InsertVersion inserts a version id into the version table within a transaction.
-	InsertVersion(ctx context.Context, tx pgx.Tx, version int64) error
+	// The checksum of the migration may be empty, it is then stored as NULL.
+	InsertVersion(ctx context.Context, tx pgx.Tx, version int64, checksum string) error
 	// InsertVersionNoTx inserts a version id into the version table without a transaction.
-	InsertVersionNoTx(ctx context.Context, db *pgx.Conn, version int64) error
+	InsertVersionNoTx(ctx context.Context, db Queryer, version int64, checksum string) error
 
 	// DeleteVersion deletes a version id from the version table within a transaction.
 	DeleteVersion(ctx context.Context, tx pgx.Tx, version int64) error
 	// DeleteVersionNoTx deletes a version id from the version table without a transaction.
-	DeleteVersionNoTx(ctx context.Context, db *pgx.Conn, version int64) error
+	DeleteVersionNoTx(ctx context.Context, db Queryer, version int64) error
+	// DeleteVersionDuplicates deletes the rows of the version of row recorded
+	// before it within a transaction, so that row keeps its place in the
+	// version table. Without an id column, the rows of the version are
+	// replaced by one holding the state of row.
+	DeleteVersionDuplicates(ctx context.Context, tx pgx.Tx, row *ListMigrationsResult) error
 
 	// GetMigrationRow retrieves a single migration by version id.
 	//
 	// Returns the raw sql error if the query fails. It is the callers responsibility
 	// to assert for the correct error, such as sql.ErrNoRows.
-	GetMigration(ctx context.Context, db *pgx.Conn, version int64) (*GetMigrationResult, error)
+	GetMigration(ctx context.Context, db Queryer, version int64) (*GetMigrationResult, error)
 
 	// ListMigrations retrieves all migrations sorted in descending order by id.
 	//
 	// If there are no migrations, an empty slice is returned with no error.
-	ListMigrations(ctx context.Context, db *pgx.Conn) ([]*ListMigrationsResult, error)
+	ListMigrations(ctx context.Context, db Queryer) ([]*ListMigrationsResult, error)
+
+	// VersionTableGeneration returns the schema generation of the version
+	// table, see dialectquery.LatestGeneration, or 0 if it does not exist.
+	VersionTableGeneration(ctx context.Context, db Queryer) (int, error)
+	// UpgradeVersionTable upgrades the version table from the given schema
+	// generation to the latest one.
+	UpgradeVersionTable(ctx context.Context, db Queryer, from int) error
+
+	// CreateLockTable creates the lock table, if it does not exist, and a
+	// released lock row for the version table.
+	CreateLockTable(ctx context.Context, db Queryer) error
+
+	// TryLock takes the lock row for owner until expiresAt, if it is released
+	// or its lease expired before now. It reports whether owner holds the lock.
+	TryLock(ctx context.Context, db Queryer, owner string, now, expiresAt time.Time) (bool, error)
+
+	// RefreshLock extends the lease of the lock row held by owner until
+	// expiresAt. It reports whether owner still holds the lock.
+	RefreshLock(ctx context.Context, db Queryer, owner string, expiresAt time.Time) (bool, error)
+
+	// ReleaseLock releases the lock row held by owner.
+	ReleaseLock(ctx context.Context, db Queryer, owner string) error
+
+	// ForceReleaseLock releases the lock row regardless of its owner.
+	ForceReleaseLock(ctx context.Context, db Queryer) error
+
+	// GetLock retrieves the lock row.
+	GetLock(ctx context.Context, db Queryer) (*GetLockResult, error)
+
+	// CreateRepeatableTable creates the repeatable migrations table, if it
+	// does not exist.
+	CreateRepeatableTable(ctx context.Context, db Queryer) error
+	// ListRepeatable retrieves the applied repeatable migrations sorted by
+	// name.
+	ListRepeatable(ctx context.Context, db Queryer) ([]*ListRepeatableResult, error)
+	// SetRepeatable records the checksum of an applied repeatable migration
+	// within a transaction.
+	SetRepeatable(ctx context.Context, tx pgx.Tx, name, checksum string) error
+	// SetRepeatableNoTx records the checksum of an applied repeatable
+	// migration without a transaction.
+	SetRepeatableNoTx(ctx context.Context, db Queryer, name, checksum string) error
+
+	// CreateVersionTableSQL returns the statement creating the version table,
+	// for use in SQL scripts.
+	CreateVersionTableSQL() string
+	// InsertVersionSQL returns the statement inserting version into the
+	// version table, with its arguments inlined as literals.
+	InsertVersionSQL(version int64, checksum string) string
+	// DeleteVersionSQL returns the statement deleting version from the version
+	// table, with its arguments inlined as literals.
+	DeleteVersionSQL(version int64) string
+	// DeleteVersionDuplicatesSQL returns the statements of
+	// DeleteVersionDuplicates, with their arguments inlined as literals.
+	DeleteVersionDuplicatesSQL(row *ListMigrationsResult) string
+	// CreateRepeatableTableSQL returns the statement creating the repeatable
+	// migrations table, for use in SQL scripts.
+	CreateRepeatableTableSQL() string
+	// SetRepeatableSQL returns the statements recording the checksum of a
+	// repeatable migration, with their arguments inlined as literals.
+	SetRepeatableSQL(name, checksum string) string
 }
 
 // NewStore returns a new Store for the given dialect.
@@ -74,7 +151,7 @@ func NewStore(d Dialect, table string) (Store, error) {
 	default:
 		return nil, fmt.Errorf("unknown querier dialect: %v", d)
 	}
-	return &store{querier: querier}, nil
+	return &store{querier: querier, table: table, dialect: d}, nil
 }
 
 type GetMigrationResult struct {
@@ -83,12 +160,32 @@ type GetMigrationResult struct {
 }
 
 type ListMigrationsResult struct {
+	// ID is the id of the row, 0 for version tables without an id column.
+	ID        int64
 	VersionID int64
 	IsApplied bool
+	// Checksum is empty for versions recorded without one.
+	Checksum string
+}
+
+// ListRepeatableResult is an applied repeatable migration.
+type ListRepeatableResult struct {
+	Name     string
+	Checksum string
+}
+
+// GetLockResult is a row of the lock table. A released lock has an empty
+// Owner.
+type GetLockResult struct {
+	Owner     string
+	ExpiresAt time.Time
 }
 
 type store struct {
 	querier dialectquery.Querier
+	// table is the version table, it identifies the lock row.
+	table   string
+	dialect Dialect
 }
 
 var _ Store = (*store)(nil)
@@ -99,31 +196,53 @@ func (s *store) CreateVersionTable(ctx context.Context, tx pgx.Tx) error {
 	return err
 }
 
-func (s *store) InsertVersion(ctx context.Context, tx pgx.Tx, version int64) error {
+func (s *store) InsertVersion(ctx context.Context, tx pgx.Tx, version int64, checksum string) error {
 	q := s.querier.InsertVersion()
-	_, err := tx.Exec(ctx, q, version, true)
+	_, err := tx.Exec(ctx, q, version, true, nullString(checksum))
 	return err
 }
 
-func (s *store) InsertVersionNoTx(ctx context.Context, db *pgx.Conn, version int64) error {
+func (s *store) InsertVersionNoTx(ctx context.Context, db Queryer, version int64, checksum string) error {
 	q := s.querier.InsertVersion()
-	_, err := db.Exec(ctx, q, version, true)
+	_, err := db.Exec(ctx, q, version, true, nullString(checksum))
 	return err
 }
 
+// nullString returns nil for an empty s, so it is stored as NULL.
+func nullString(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
 func (s *store) DeleteVersion(ctx context.Context, tx pgx.Tx, version int64) error {
 	q := s.querier.DeleteVersion()
 	_, err := tx.Exec(ctx, q, version)
 	return err
 }
 
-func (s *store) DeleteVersionNoTx(ctx context.Context, db *pgx.Conn, version int64) error {
+func (s *store) DeleteVersionNoTx(ctx context.Context, db Queryer, version int64) error {
 	q := s.querier.DeleteVersion()
 	_, err := db.Exec(ctx, q, version)
 	return err
 }
 
-func (s *store) GetMigration(ctx context.Context, db *pgx.Conn, version int64) (*GetMigrationResult, error) {
+func (s *store) DeleteVersionDuplicates(ctx context.Context, tx pgx.Tx, row *ListMigrationsResult) error {
+	q := s.querier.DeleteVersionDuplicates()
+	if q == "" {
+		// Such tables are ordered by version, replacing the rows does not move
+		// the version.
+		if err := s.DeleteVersion(ctx, tx, row.VersionID); err != nil || !row.IsApplied {
+			return err
+		}
+		return s.InsertVersion(ctx, tx, row.VersionID, row.Checksum)
+	}
+	_, err := tx.Exec(ctx, q, row.VersionID, row.ID)
+	return err
+}
+
+func (s *store) GetMigration(ctx context.Context, db Queryer, version int64) (*GetMigrationResult, error) {
 	q := s.querier.GetMigrationByVersion()
 	var timestamp time.Time
 	var isApplied bool
@@ -137,7 +256,7 @@ func (s *store) GetMigration(ctx context.Context, db *pgx.Conn, version int64) (
 	}, nil
 }
 
-func (s *store) ListMigrations(ctx context.Context, db *pgx.Conn) ([]*ListMigrationsResult, error) {
+func (s *store) ListMigrations(ctx context.Context, db Queryer) ([]*ListMigrationsResult, error) {
 	q := s.querier.ListMigrations()
 	rows, err := db.Query(ctx, q)
 	if err != nil {
@@ -147,18 +266,260 @@ func (s *store) ListMigrations(ctx context.Context, db *pgx.Conn) ([]*ListMigrat
 
 	var migrations []*ListMigrationsResult
 	for rows.Next() {
-		var version int64
+		var id, version int64
 		var isApplied bool
-		if err := rows.Scan(&version, &isApplied); err != nil {
+		var checksum *string
+		if err := rows.Scan(&id, &version, &isApplied, &checksum); err != nil {
 			return nil, err
 		}
-		migrations = append(migrations, &ListMigrationsResult{
+		result := &ListMigrationsResult{
+			ID:        id,
 			VersionID: version,
 			IsApplied: isApplied,
-		})
+		}
+		if checksum != nil {
+			result.Checksum = *checksum
+		}
+		migrations = append(migrations, result)
 	}
 	if err = rows.Err(); err != nil {
 		return nil, err
 	}
 	return migrations, nil
 }
+
+func (s *store) VersionTableGeneration(ctx context.Context, db Queryer) (int, error) {
+	// Probe for the column introduced by each generation, newest first. The
+	// probe fails if the column, or the table, does not exist. Other errors,
+	// such as a lost connection or a missing privilege, are returned: they
+	// say nothing about the schema.
+	for g := dialectquery.LatestGeneration; g >= dialectquery.GenerationInitial; g-- {
+		q := fmt.Sprintf("SELECT %s FROM %s WHERE 1=0", dialectquery.GenerationColumns[g], s.table)
+		_, err := db.Exec(ctx, q)
+		if err == nil {
+			return g, nil
+		}
+		if !isUndefinedObject(err) {
+			return 0, err
+		}
+	}
+	return 0, nil
+}
+
+// undefinedObjectMessages are fragments of the messages of the errors drivers
+// report for a table or a column that does not exist, for those without
+// SQLSTATE codes.
+var undefinedObjectMessages = []string{
+	"no such table", "no such column", // SQLite
+	"doesn't exist", "Unknown column", // MySQL, TiDB
+	"Invalid object name", "Invalid column name", // SQL Server
+	"Missing columns", "Unknown expression identifier", "Unknown table expression identifier", // ClickHouse
+	"does not exist", // Vertica, ClickHouse and lib/pq
+}
+
+// isUndefinedObject reports whether err is the error of a query on a table or
+// a column that does not exist.
+func isUndefinedObject(err error) bool {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return pgErr.Code == "42P01" || pgErr.Code == "42703"
+	}
+	msg := err.Error()
+	for _, fragment := range undefinedObjectMessages {
+		if strings.Contains(msg, fragment) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *store) UpgradeVersionTable(ctx context.Context, db Queryer, from int) error {
+	for g := from; g < dialectquery.LatestGeneration; g++ {
+		for _, q := range s.querier.UpgradeTable(g) {
+			if _, err := db.Exec(ctx, q); err != nil {
+				return fmt.Errorf("generation %d: %w", g, err)
+			}
+		}
+	}
+	return nil
+}
+
+func (s *store) CreateLockTable(ctx context.Context, db Queryer) error {
+	if _, err := db.Exec(ctx, s.querier.CreateLockTable()); err != nil {
+		return err
+	}
+	_, err := db.Exec(ctx, s.querier.InsertLock(), s.table, time.Now().UTC())
+	return err
+}
+
+func (s *store) TryLock(ctx context.Context, db Queryer, owner string, now, expiresAt time.Time) (bool, error) {
+	q := s.querier.AcquireLock()
+	if _, err := db.Exec(ctx, q, owner, expiresAt.UTC(), s.table, now.UTC()); err != nil {
+		return false, err
+	}
+	// Not every dialect reports affected rows for updates, read the row back
+	// instead.
+	return s.isLockOwner(ctx, db, owner)
+}
+
+func (s *store) RefreshLock(ctx context.Context, db Queryer, owner string, expiresAt time.Time) (bool, error) {
+	q := s.querier.RefreshLock()
+	if _, err := db.Exec(ctx, q, expiresAt.UTC(), s.table, owner); err != nil {
+		return false, err
+	}
+	return s.isLockOwner(ctx, db, owner)
+}
+
+func (s *store) ReleaseLock(ctx context.Context, db Queryer, owner string) error {
+	q := s.querier.ReleaseLock()
+	_, err := db.Exec(ctx, q, s.table, owner)
+	return err
+}
+
+func (s *store) ForceReleaseLock(ctx context.Context, db Queryer) error {
+	q := s.querier.ForceReleaseLock()
+	_, err := db.Exec(ctx, q, s.table)
+	return err
+}
+
+func (s *store) GetLock(ctx context.Context, db Queryer) (*GetLockResult, error) {
+	q := s.querier.GetLock()
+	var owner string
+	var expiresAt time.Time
+	if err := db.QueryRow(ctx, q, s.table).Scan(&owner, &expiresAt); err != nil {
+		return nil, err
+	}
+	return &GetLockResult{
+		Owner:     owner,
+		ExpiresAt: expiresAt,
+	}, nil
+}
+
+func (s *store) isLockOwner(ctx context.Context, db Queryer, owner string) (bool, error) {
+	lock, err := s.GetLock(ctx, db)
+	if err != nil {
+		return false, err
+	}
+	return lock.Owner == owner, nil
+}
+
+func (s *store) CreateRepeatableTable(ctx context.Context, db Queryer) error {
+	_, err := db.Exec(ctx, s.querier.CreateRepeatableTable())
+	return err
+}
+
+func (s *store) ListRepeatable(ctx context.Context, db Queryer) ([]*ListRepeatableResult, error) {
+	rows, err := db.Query(ctx, s.querier.ListRepeatable())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var repeatable []*ListRepeatableResult
+	for rows.Next() {
+		var result ListRepeatableResult
+		if err := rows.Scan(&result.Name, &result.Checksum); err != nil {
+			return nil, err
+		}
+		repeatable = append(repeatable, &result)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return repeatable, nil
+}
+
+func (s *store) SetRepeatable(ctx context.Context, tx pgx.Tx, name, checksum string) error {
+	if _, err := tx.Exec(ctx, s.querier.DeleteRepeatable(), name); err != nil {
+		return err
+	}
+	_, err := tx.Exec(ctx, s.querier.InsertRepeatable(), name, checksum)
+	return err
+}
+
+func (s *store) SetRepeatableNoTx(ctx context.Context, db Queryer, name, checksum string) error {
+	if _, err := db.Exec(ctx, s.querier.DeleteRepeatable(), name); err != nil {
+		return err
+	}
+	_, err := db.Exec(ctx, s.querier.InsertRepeatable(), name, checksum)
+	return err
+}
+
+func (s *store) CreateVersionTableSQL() string {
+	return s.querier.CreateTable() + ";"
+}
+
+func (s *store) InsertVersionSQL(version int64, checksum string) string {
+	isApplied := "true"
+	switch s.dialect {
+	case Sqlserver, Sqlite3, Clickhouse:
+		isApplied = "1"
+	}
+	checksumLiteral := "NULL"
+	if checksum != "" {
+		checksumLiteral = StringLiteral(checksum)
+	}
+	return inlineArgs(s.querier.InsertVersion(), strconv.FormatInt(version, 10), isApplied, checksumLiteral) + ";"
+}
+
+func (s *store) DeleteVersionSQL(version int64) string {
+	return inlineArgs(s.querier.DeleteVersion(), strconv.FormatInt(version, 10)) + ";"
+}
+
+func (s *store) DeleteVersionDuplicatesSQL(row *ListMigrationsResult) string {
+	q := s.querier.DeleteVersionDuplicates()
+	if q == "" {
+		if !row.IsApplied {
+			return s.DeleteVersionSQL(row.VersionID)
+		}
+		return s.DeleteVersionSQL(row.VersionID) + "\n" + s.InsertVersionSQL(row.VersionID, row.Checksum)
+	}
+	return inlineArgs(q, strconv.FormatInt(row.VersionID, 10), strconv.FormatInt(row.ID, 10)) + ";"
+}
+
+func (s *store) CreateRepeatableTableSQL() string {
+	return s.querier.CreateRepeatableTable() + ";"
+}
+
+func (s *store) SetRepeatableSQL(name, checksum string) string {
+	return inlineArgs(s.querier.DeleteRepeatable(), StringLiteral(name)) + ";\n" +
+		inlineArgs(s.querier.InsertRepeatable(), StringLiteral(name), StringLiteral(checksum)) + ";"
+}
+
+// StringLiteral quotes v as an SQL string literal.
+func StringLiteral(v string) string {
+	return "'" + strings.ReplaceAll(v, "'", "''") + "'"
+}
+
+// inlineArgs replaces the placeholders of q, in the $1, @p1 or ? style, with
+// the given literals.
+func inlineArgs(q string, literals ...string) string {
+	var b strings.Builder
+	next := 0
+	for i := 0; i < len(q); i++ {
+		switch {
+		case q[i] == '?':
+			if next < len(literals) {
+				b.WriteString(literals[next])
+				next++
+				continue
+			}
+		case q[i] == '$' || (q[i] == '@' && i+1 < len(q) && q[i+1] == 'p'):
+			start := i + 1
+			if q[i] == '@' {
+				start++
+			}
+			end := start
+			for end < len(q) && q[end] >= '0' && q[end] <= '9' {
+				end++
+			}
+			if n, err := strconv.Atoi(q[start:end]); err == nil && n >= 1 && n <= len(literals) {
+				b.WriteString(literals[n-1])
+				i = end - 1
+				continue
+			}
+		}
+		b.WriteByte(q[i])
+	}
+	return b.String()
+}
@@ -0,0 +1,127 @@
+package dialect
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/SergeiSkv/goose/v3/internal/check"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+func TestVersionSQL(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		dialect    Dialect
+		insert     string
+		delete     string
+		duplicates string
+	}{
+		{
+			dialect:    Postgres,
+			insert:     "INSERT INTO goose_db_version (version_id, is_applied, checksum) VALUES (42, true, 'abc');",
+			delete:     "DELETE FROM goose_db_version WHERE version_id=42;",
+			duplicates: "DELETE FROM goose_db_version WHERE version_id=42 AND id<7;",
+		},
+		{
+			dialect:    Mysql,
+			insert:     "INSERT INTO goose_db_version (version_id, is_applied, checksum) VALUES (42, true, 'abc');",
+			delete:     "DELETE FROM goose_db_version WHERE version_id=42;",
+			duplicates: "DELETE FROM goose_db_version WHERE version_id=42 AND id<7;",
+		},
+		{
+			dialect:    Sqlite3,
+			insert:     "INSERT INTO goose_db_version (version_id, is_applied, checksum) VALUES (42, 1, 'abc');",
+			delete:     "DELETE FROM goose_db_version WHERE version_id=42;",
+			duplicates: "DELETE FROM goose_db_version WHERE version_id=42 AND id<7;",
+		},
+		{
+			dialect:    Sqlserver,
+			insert:     "INSERT INTO goose_db_version (version_id, is_applied, checksum) VALUES (42, 1, 'abc');",
+			delete:     "DELETE FROM goose_db_version WHERE version_id=42;",
+			duplicates: "DELETE FROM goose_db_version WHERE version_id=42 AND id<7;",
+		},
+		{
+			dialect: Clickhouse,
+			insert:  "INSERT INTO goose_db_version (version_id, is_applied, checksum) VALUES (42, 1, 'abc');",
+			delete:  "ALTER TABLE goose_db_version DELETE WHERE version_id = 42 SETTINGS mutations_sync = 2;",
+			duplicates: "ALTER TABLE goose_db_version DELETE WHERE version_id = 42 SETTINGS mutations_sync = 2;\n" +
+				"INSERT INTO goose_db_version (version_id, is_applied, checksum) VALUES (42, 1, 'abc');",
+		},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(string(tc.dialect), func(t *testing.T) {
+			s, err := NewStore(tc.dialect, "goose_db_version")
+			check.NoError(t, err)
+			check.Equal(t, s.InsertVersionSQL(42, "abc"), tc.insert)
+			check.Equal(t, s.DeleteVersionSQL(42), tc.delete)
+			row := &ListMigrationsResult{ID: 7, VersionID: 42, IsApplied: true, Checksum: "abc"}
+			check.Equal(t, s.DeleteVersionDuplicatesSQL(row), tc.duplicates)
+		})
+	}
+}
+
+func TestInlineArgs(t *testing.T) {
+	t.Parallel()
+
+	check.Equal(t, inlineArgs("SELECT $2, $1, $10", "a", "b"), "SELECT b, a, $10")
+	check.Equal(t, inlineArgs("SELECT ?, ?, ?", "a", "b"), "SELECT a, b, ?")
+	check.Equal(t, inlineArgs("SELECT @p1, @p2, @x", "a", "b"), "SELECT a, b, @x")
+}
+
+func TestSetRepeatableSQL(t *testing.T) {
+	t.Parallel()
+
+	s, err := NewStore(Postgres, "goose_db_version")
+	check.NoError(t, err)
+	check.Equal(t, s.SetRepeatableSQL("R_views.sql", "abc"),
+		"DELETE FROM goose_db_version_repeatable WHERE name='R_views.sql';\n"+
+			"INSERT INTO goose_db_version_repeatable (name, checksum) VALUES ('R_views.sql', 'abc');")
+}
+
+// failingQueryer fails every statement with err.
+type failingQueryer struct {
+	err error
+}
+
+func (q failingQueryer) Exec(context.Context, string, ...any) (pgconn.CommandTag, error) {
+	return pgconn.CommandTag{}, q.err
+}
+
+func (q failingQueryer) Query(context.Context, string, ...any) (pgx.Rows, error) {
+	return nil, q.err
+}
+
+func (q failingQueryer) QueryRow(context.Context, string, ...any) pgx.Row {
+	return nil
+}
+
+func TestVersionTableGeneration(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	s, err := NewStore(Postgres, "goose_db_version")
+	check.NoError(t, err)
+
+	for _, err := range []error{
+		&pgconn.PgError{Code: "42P01", Message: `relation "goose_db_version" does not exist`},
+		errors.New("SQL logic error: no such table: goose_db_version (1)"),
+		errors.New("Error 1146 (42S02): Table 'app.goose_db_version' doesn't exist"),
+		errors.New("mssql: Invalid object name 'goose_db_version'."),
+	} {
+		generation, err := s.VersionTableGeneration(ctx, failingQueryer{err: err})
+		check.NoError(t, err)
+		check.Number(t, generation, 0)
+	}
+
+	// Errors unrelated to the schema are not mistaken for a missing table.
+	errDenied := &pgconn.PgError{Code: "42501", Message: "permission denied for table goose_db_version"}
+	_, err = s.VersionTableGeneration(ctx, failingQueryer{err: errDenied})
+	check.IsError(t, err, errDenied)
+	errConn := errors.New("read tcp 127.0.0.1:5432: connection reset by peer")
+	_, err = s.VersionTableGeneration(ctx, failingQueryer{err: errConn})
+	check.IsError(t, err, errConn)
+}
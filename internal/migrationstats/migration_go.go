@@ -10,8 +10,10 @@ import (
 )
 
 const (
-	registerGoFuncName     = "AddMigration"
-	registerGoFuncNameNoTx = "AddMigrationNoTx"
+	registerGoFuncName            = "AddMigration"
+	registerGoFuncNameNoTx        = "AddMigrationNoTx"
+	registerGoFuncNameContext     = "AddMigrationContext"
+	registerGoFuncNameNoTxContext = "AddMigrationNoTxContext"
 )
 
 type goMigration struct {
@@ -72,10 +74,10 @@ func parseInitFunc(fd *ast.FuncDecl) (*goMigration, error) {
 		funcName := sel.Sel.Name
 		b := false
 		switch funcName {
-		case registerGoFuncName:
+		case registerGoFuncName, registerGoFuncNameContext:
 			b = true
 			gf.useTx = &b
-		case registerGoFuncNameNoTx:
+		case registerGoFuncNameNoTx, registerGoFuncNameNoTxContext:
 			gf.useTx = &b
 		default:
 			continue
@@ -85,7 +87,9 @@ func parseInitFunc(fd *ast.FuncDecl) (*goMigration, error) {
 		}
 		gf.name = funcName
 
-		if len(call.Args) != 2 {
+		// The context variants take migration options after the functions.
+		contextFunc := funcName == registerGoFuncNameContext || funcName == registerGoFuncNameNoTxContext
+		if len(call.Args) != 2 && !(contextFunc && len(call.Args) > 2) {
 			return nil, fmt.Errorf("registered goose functions have 2 arguments: got %d", len(call.Args))
 		}
 		getNameFromExpr := func(expr ast.Expr) (string, error) {
@@ -107,11 +111,13 @@ func parseInitFunc(fd *ast.FuncDecl) (*goMigration, error) {
 	}
 	// validation
 	switch gf.name {
-	case registerGoFuncName, registerGoFuncNameNoTx:
+	case registerGoFuncName, registerGoFuncNameNoTx, registerGoFuncNameContext, registerGoFuncNameNoTxContext:
 	default:
-		return nil, fmt.Errorf("goose register function must be one of: %s or %s",
+		return nil, fmt.Errorf("goose register function must be one of: %s, %s, %s or %s",
 			registerGoFuncName,
 			registerGoFuncNameNoTx,
+			registerGoFuncNameContext,
+			registerGoFuncNameNoTxContext,
 		)
 	}
 	if gf.useTx == nil {
@@ -10,6 +10,7 @@ import (
 
 type sqlMigration struct {
 	useTx              bool
+	repeatable         bool
 	upCount, downCount int
 }
 
@@ -38,9 +39,14 @@ func parseSQLFile(r io.Reader, debug bool) (*sqlMigration, error) {
 	if txUp != txDown {
 		return nil, fmt.Errorf("up and down statements must have the same transaction mode")
 	}
+	repeatable, err := sqlparser.IsRepeatable(bytes.NewReader(by))
+	if err != nil {
+		return nil, err
+	}
 	return &sqlMigration{
-		useTx:     txUp,
-		upCount:   len(upStatements),
-		downCount: len(downStatements),
+		useTx:      txUp,
+		repeatable: repeatable,
+		upCount:    len(upStatements),
+		downCount:  len(downStatements),
 	}, nil
 }
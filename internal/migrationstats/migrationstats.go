@@ -1,11 +1,15 @@
 package migrationstats
 
 import (
+	"bytes"
 	"fmt"
 	"io"
 	"path/filepath"
+	"strings"
+	"text/template"
 
 	"github.com/SergeiSkv/goose/v3"
+	"github.com/SergeiSkv/goose/v3/internal/sqlparser"
 )
 
 // FileWalker walks all files for GatherStats.
@@ -20,6 +24,9 @@ type Stats struct {
 	FileName string
 	// Version is the version of the migration.
 	Version int64
+	// Type is the type of the migration, .sql.tmpl templates being SQL
+	// migrations.
+	Type goose.MigrationType
 	// Tx is true if the .sql migration file has a +goose NO TRANSACTION annotation
 	// or the .go migration file calls AddMigrationNoTx.
 	Tx bool
@@ -27,26 +34,62 @@ type Stats struct {
 	UpCount int
 	// DownCount is the number of statements in the Down migration.
 	DownCount int
+	// Repeatable is true for repeatable .sql migration files, which have no
+	// version.
+	Repeatable bool
+}
+
+// Option configures GatherStats.
+type Option func(o *options)
+
+type options struct {
+	templateData  map[string]interface{}
+	templateFuncs template.FuncMap
+}
+
+// WithTemplate sets the data and functions .sql.tmpl migration files are
+// rendered with before they are parsed.
+func WithTemplate(data map[string]interface{}, funcs template.FuncMap) Option {
+	return func(o *options) {
+		o.templateData = data
+		o.templateFuncs = funcs
+	}
 }
 
 // GatherStats returns the migration file stats.
-func GatherStats(fw FileWalker, debug bool) ([]*Stats, error) {
+func GatherStats(fw FileWalker, debug bool, opts ...Option) ([]*Stats, error) {
+	option := &options{}
+	for _, f := range opts {
+		f(option)
+	}
 	var stats []*Stats
 	err := fw.Walk(func(filename string, r io.Reader) error {
-		version, err := goose.NumericComponent(filename)
-		if err != nil {
-			return fmt.Errorf("failed to get version from file %q: %w", filename, err)
-		}
+		version, versionErr := goose.NumericComponent(filename)
 		var up, down int
-		var tx bool
+		var tx, repeatable bool
+		var typ goose.MigrationType
 		switch filepath.Ext(filename) {
-		case ".sql":
+		case ".sql", ".tmpl":
+			if sqlparser.IsTemplate(filename) {
+				src, err := io.ReadAll(r)
+				if err != nil {
+					return fmt.Errorf("failed to read file %q: %w", filename, err)
+				}
+				rendered, err := sqlparser.RenderTemplate(filename, src, option.templateData, option.templateFuncs)
+				if err != nil {
+					return fmt.Errorf("failed to render file %q: %w", filename, err)
+				}
+				r = bytes.NewReader(rendered)
+			}
 			m, err := parseSQLFile(r, debug)
 			if err != nil {
 				return fmt.Errorf("failed to parse file %q: %w", filename, err)
 			}
 			up, down = m.upCount, m.downCount
 			tx = m.useTx
+			typ = goose.TypeSQL
+			// Repeatable migrations have no version.
+			repeatable = versionErr != nil && (m.repeatable || strings.HasPrefix(filepath.Base(filename), "R_"))
 		case ".go":
 			m, err := parseGoFile(r)
 			if err != nil {
@@ -54,13 +97,19 @@ func GatherStats(fw FileWalker, debug bool) ([]*Stats, error) {
 			}
 			up, down = nilAsNumber(m.upFuncName), nilAsNumber(m.downFuncName)
 			tx = *m.useTx
+			typ = goose.TypeGo
+		}
+		if versionErr != nil && !repeatable {
+			return fmt.Errorf("failed to get version from file %q: %w", filename, versionErr)
 		}
 		stats = append(stats, &Stats{
-			FileName:  filename,
-			Version:   version,
-			Tx:        tx,
-			UpCount:   up,
-			DownCount: down,
+			FileName:   filename,
+			Version:    version,
+			Type:       typ,
+			Tx:         tx,
+			UpCount:    up,
+			DownCount:  down,
+			Repeatable: repeatable,
 		})
 		return nil
 	})
@@ -1,9 +1,11 @@
 package migrationstats
 
 import (
+	"io"
 	"strings"
 	"testing"
 
+	"github.com/SergeiSkv/goose/v3"
 	"github.com/SergeiSkv/goose/v3/internal/check"
 )
 
@@ -24,6 +26,10 @@ func TestParsingGoMigrations(t *testing.T) {
 		{"downOnlyNoTx", downOnlyNoTx, "nil", "down002", false},
 		{"upOnlyNoTx", upOnlyNoTx, "up003", "nil", false},
 		{"upAndDownNilNoTx", upAndDownNilNoTx, "nil", "nil", false},
+		// AddMigrationContext and AddMigrationNoTxContext
+		{"upAndDownContext", upAndDownContext, "up001", "down001", true},
+		{"upAndDownNoTxContext", upAndDownNoTxContext, "up001", "nil", false},
+		{"upAndDownContextOptions", upAndDownContextOptions, "up001", "down001", true},
 	}
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
@@ -44,7 +50,35 @@ func TestParsingGoMigrationsError(t *testing.T) {
 
 	_, err = parseGoFile(strings.NewReader(wrongName))
 	check.HasError(t, err)
-	check.Contains(t, err.Error(), "AddMigration or AddMigrationNoTx")
+	check.Contains(t, err.Error(), "AddMigration, AddMigrationNoTx, AddMigrationContext or AddMigrationNoTxContext")
+}
+
+type mapWalker map[string]string
+
+func (w mapWalker) Walk(fn func(filename string, r io.Reader) error) error {
+	for name, content := range w {
+		if err := fn(name, strings.NewReader(content)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestGatherStatsTemplate(t *testing.T) {
+	fw := mapWalker{
+		"00001_partitions.sql.tmpl": "-- +goose Up\n{{ range .Months }}CREATE TABLE events_{{ . }} ();\n{{ end }}-- +goose Down\nSELECT 1;\n",
+	}
+	stats, err := GatherStats(fw, false, WithTemplate(map[string]interface{}{"Months": []int{1, 2, 3}}, nil))
+	check.NoError(t, err)
+	check.Number(t, len(stats), 1)
+	check.Number(t, stats[0].Version, 1)
+	check.Equal(t, stats[0].Type, goose.TypeSQL)
+	check.Number(t, stats[0].UpCount, 3)
+	check.Number(t, stats[0].DownCount, 1)
+
+	_, err = GatherStats(fw, false)
+	check.HasError(t, err)
+	check.Contains(t, err.Error(), "failed to render file")
 }
 
 var (
@@ -162,6 +196,57 @@ func init() {
 }`
 )
 
+var (
+	upAndDownContext = `package testgo
+
+import (
+	"context"
+
+	"github.com/SergeiSkv/goose/v3"
+	"github.com/jackc/pgx/v5"
+)
+
+func init() {
+	goose.AddMigrationContext(up001, down001)
+}
+
+func up001(ctx context.Context, tx pgx.Tx) error { return nil }
+
+func down001(ctx context.Context, tx pgx.Tx) error { return nil }`
+
+	upAndDownContextOptions = `package testgo
+
+import (
+	"context"
+
+	"github.com/SergeiSkv/goose/v3"
+	"github.com/jackc/pgx/v5"
+)
+
+func init() {
+	goose.AddMigrationContext(up001, down001, goose.WithSessionSetting("lock_timeout", "5s"))
+}
+
+func up001(ctx context.Context, tx pgx.Tx) error { return nil }
+
+func down001(ctx context.Context, tx pgx.Tx) error { return nil }`
+
+	upAndDownNoTxContext = `package testgo
+
+import (
+	"context"
+
+	"github.com/SergeiSkv/goose/v3"
+	"github.com/jackc/pgx/v5"
+)
+
+func init() {
+	goose.AddMigrationNoTxContext(up001, nil)
+}
+
+func up001(ctx context.Context, db *pgx.Conn) error { return nil }`
+)
+
 var (
 	emptyInit = `package testgo
 
@@ -6,11 +6,12 @@ import (
 	"path/filepath"
 
 	"github.com/SergeiSkv/goose/v3/internal/migrationstats"
+	"github.com/SergeiSkv/goose/v3/internal/sqlparser"
 )
 
 // NewFileWalker returns a new FileWalker for the given filenames.
 //
-// Filenames without a .sql or .go extension are ignored.
+// Filenames without a .sql, .sql.tmpl or .go extension are ignored.
 func NewFileWalker(filenames ...string) migrationstats.FileWalker {
 	return &fileWalker{
 		filenames: filenames,
@@ -26,7 +27,7 @@ var _ migrationstats.FileWalker = (*fileWalker)(nil)
 func (f *fileWalker) Walk(fn func(filename string, r io.Reader) error) error {
 	for _, filename := range f.filenames {
 		ext := filepath.Ext(filename)
-		if ext != ".sql" && ext != ".go" {
+		if ext != ".sql" && ext != ".go" && !sqlparser.IsTemplate(filename) {
 			continue
 		}
 		if err := walk(filename, fn); err != nil {
@@ -0,0 +1,72 @@
+package sqlparser
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Env looks up the value of an environment variable, such as os.LookupEnv.
+type Env func(name string) (string, bool)
+
+// EnvMap returns an Env looking up variables in m only.
+func EnvMap(m map[string]string) Env {
+	return func(name string) (string, bool) {
+		v, ok := m[name]
+		return v, ok
+	}
+}
+
+var (
+	matchEnvName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+	// matchSecretName matches the names of the variables whose values are
+	// masked in verbose output and errors.
+	matchSecretName = regexp.MustCompile(`(?i)(password|passwd|secret|token|credential|private|key)`)
+)
+
+// expandEnv expands ${VAR} and ${VAR:-default} in line. A variable without a
+// default must be set, a default applies when the variable is unset or empty.
+// The values of secret variables are added to secrets.
+func expandEnv(line string, env Env, secrets *[]string) (string, error) {
+	var b strings.Builder
+	for {
+		i := strings.Index(line, "${")
+		if i < 0 {
+			b.WriteString(line)
+			return b.String(), nil
+		}
+		b.WriteString(line[:i])
+		line = line[i+2:]
+		j := strings.IndexByte(line, '}')
+		if j < 0 {
+			return "", fmt.Errorf("unterminated variable reference %q", "${"+line)
+		}
+		expr := line[:j]
+		line = line[j+1:]
+
+		name, def, hasDefault := strings.Cut(expr, ":-")
+		if !matchEnvName.MatchString(name) {
+			return "", fmt.Errorf("invalid variable reference %q", "${"+expr+"}")
+		}
+		value, ok := env(name)
+		switch {
+		case hasDefault && value == "":
+			value = def
+		case !ok:
+			return "", fmt.Errorf("environment variable %q is not set, set it or use ${%s:-default}", name, name)
+		}
+		if value != "" && matchSecretName.MatchString(name) {
+			*secrets = append(*secrets, value)
+		}
+		b.WriteString(value)
+	}
+}
+
+// Mask replaces the values of secret variables in s, such as a statement
+// about to be logged, with asterisks.
+func (p *Parsed) Mask(s string) string {
+	for _, secret := range p.secrets {
+		s = strings.ReplaceAll(s, secret, "******")
+	}
+	return s
+}
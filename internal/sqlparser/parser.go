@@ -89,7 +89,40 @@ var bufferPool = sync.Pool{
 // within a statement. For these cases, we provide the explicit annotations
 // 'StatementBegin' and 'StatementEnd' to allow the script to
 // tell us to ignore semicolons.
+//
+// Variable references in '-- +goose ENVSUB ON' sections are left unexpanded,
+// see Parse.
 func ParseSQLMigration(r io.Reader, direction Direction, debug bool) (stmts []string, useTx bool, err error) {
+	parsed, err := parse(r, direction, debug, nil)
+	if err != nil {
+		return nil, false, err
+	}
+	return parsed.Statements, parsed.UseTx, nil
+}
+
+// Parsed is a SQL migration parsed for one direction.
+type Parsed struct {
+	Statements []string
+	UseTx      bool
+	// Settings are the session settings of the migration, they apply to
+	// both directions.
+	Settings Settings
+
+	// secrets are the values of secret variables expanded in the statements,
+	// see Mask.
+	secrets []string
+}
+
+// Parse parses a SQL migration for the given direction, see
+// ParseSQLMigration. Unlike ParseSQLMigration, it also returns the session
+// settings of the migration, and expands ${VAR} and ${VAR:-default} in the
+// sections between '-- +goose ENVSUB ON' and '-- +goose ENVSUB OFF' using env.
+// A nil env leaves them unexpanded.
+func Parse(r io.Reader, direction Direction, debug bool, env Env) (*Parsed, error) {
+	return parse(r, direction, debug, env)
+}
+
+func parse(r io.Reader, direction Direction, debug bool, env Env) (*Parsed, error) {
 	scanBufPtr := bufferPool.Get().(*[]byte)
 	scanBuf := *scanBufPtr
 	defer bufferPool.Put(scanBufPtr)
@@ -98,7 +131,9 @@ func ParseSQLMigration(r io.Reader, direction Direction, debug bool) (stmts []st
 	scanner.Buffer(scanBuf, scanBufSize)
 
 	stateMachine := newStateMachine(start, debug)
-	useTx = true
+	parsed := &Parsed{UseTx: true}
+	var stmts []string
+	var envsub bool
 
 	var buf bytes.Buffer
 	for scanner.Scan() {
@@ -114,13 +149,19 @@ func ParseSQLMigration(r io.Reader, direction Direction, debug bool) (stmts []st
 		if strings.HasPrefix(line, "--") {
 			cmd := strings.TrimSpace(strings.TrimPrefix(line, "--"))
 
+			if ok, err := parsed.Settings.parseAnnotation(cmd); err != nil {
+				return nil, err
+			} else if ok {
+				continue
+			}
+
 			switch cmd {
 			case "+goose Up":
 				switch stateMachine.get() {
 				case start:
 					stateMachine.set(gooseUp)
 				default:
-					return nil, false, fmt.Errorf("duplicate '-- +goose Up' annotations; stateMachine=%d, see https://github.com/SergeiSkv/goose/v3#sql-migrations", stateMachine.state)
+					return nil, fmt.Errorf("duplicate '-- +goose Up' annotations; stateMachine=%d, see https://github.com/SergeiSkv/goose/v3#sql-migrations", stateMachine.state)
 				}
 				continue
 
@@ -129,7 +170,7 @@ func ParseSQLMigration(r io.Reader, direction Direction, debug bool) (stmts []st
 				case gooseUp, gooseStatementEndUp:
 					stateMachine.set(gooseDown)
 				default:
-					return nil, false, fmt.Errorf("must start with '-- +goose Up' annotation, stateMachine=%d, see https://github.com/SergeiSkv/goose/v3#sql-migrations", stateMachine.state)
+					return nil, fmt.Errorf("must start with '-- +goose Up' annotation, stateMachine=%d, see https://github.com/SergeiSkv/goose/v3#sql-migrations", stateMachine.state)
 				}
 				continue
 
@@ -140,7 +181,7 @@ func ParseSQLMigration(r io.Reader, direction Direction, debug bool) (stmts []st
 				case gooseDown, gooseStatementEndDown:
 					stateMachine.set(gooseStatementBeginDown)
 				default:
-					return nil, false, fmt.Errorf("'-- +goose StatementBegin' must be defined after '-- +goose Up' or '-- +goose Down' annotation, stateMachine=%d, see https://github.com/SergeiSkv/goose/v3#sql-migrations", stateMachine.state)
+					return nil, fmt.Errorf("'-- +goose StatementBegin' must be defined after '-- +goose Up' or '-- +goose Down' annotation, stateMachine=%d, see https://github.com/SergeiSkv/goose/v3#sql-migrations", stateMachine.state)
 				}
 				continue
 
@@ -151,11 +192,23 @@ func ParseSQLMigration(r io.Reader, direction Direction, debug bool) (stmts []st
 				case gooseStatementBeginDown:
 					stateMachine.set(gooseStatementEndDown)
 				default:
-					return nil, false, errors.New("'-- +goose StatementEnd' must be defined after '-- +goose StatementBegin', see https://github.com/SergeiSkv/goose/v3#sql-migrations")
+					return nil, errors.New("'-- +goose StatementEnd' must be defined after '-- +goose StatementBegin', see https://github.com/SergeiSkv/goose/v3#sql-migrations")
 				}
 
 			case "+goose NO TRANSACTION":
-				useTx = false
+				parsed.UseTx = false
+				continue
+
+			case "+goose ENVSUB ON":
+				envsub = true
+				continue
+
+			case "+goose ENVSUB OFF":
+				envsub = false
+				continue
+
+			case "+goose Repeatable":
+				// Marks the file as a repeatable migration, see IsRepeatable.
 				continue
 			}
 		}
@@ -173,9 +226,16 @@ func ParseSQLMigration(r io.Reader, direction Direction, debug bool) (stmts []st
 		case gooseStatementEndDown, gooseStatementEndUp:
 			// Do not include the "+goose StatementEnd" annotation in the final statement.
 		default:
+			if envsub && env != nil && inDirection(stateMachine.get(), direction) {
+				expanded, err := expandEnv(line, env, &parsed.secrets)
+				if err != nil {
+					return nil, fmt.Errorf("failed to expand environment variables: %w", err)
+				}
+				line = expanded
+			}
 			// Write SQL line to a buffer.
 			if _, err := buf.WriteString(line + "\n"); err != nil {
-				return nil, false, fmt.Errorf("failed to write to buf: %w", err)
+				return nil, fmt.Errorf("failed to write to buf: %w", err)
 			}
 		}
 		// Read SQL body one by line, if we're in the right direction.
@@ -197,7 +257,7 @@ func ParseSQLMigration(r io.Reader, direction Direction, debug bool) (stmts []st
 				continue
 			}
 		default:
-			return nil, false, fmt.Errorf("failed to parse migration: unexpected state %d on line %q, see https://github.com/SergeiSkv/goose/v3#sql-migrations", stateMachine.state, line)
+			return nil, fmt.Errorf("failed to parse migration: unexpected state %d on line %q, see https://github.com/SergeiSkv/goose/v3#sql-migrations", stateMachine.state, line)
 		}
 
 		switch stateMachine.get() {
@@ -226,22 +286,55 @@ func ParseSQLMigration(r io.Reader, direction Direction, debug bool) (stmts []st
 		}
 	}
 	if err := scanner.Err(); err != nil {
-		return nil, false, fmt.Errorf("failed to scan migration: %w", err)
+		return nil, fmt.Errorf("failed to scan migration: %w", err)
 	}
 	// EOF
 
 	switch stateMachine.get() {
 	case start:
-		return nil, false, errors.New("failed to parse migration: must start with '-- +goose Up' annotation, see https://github.com/SergeiSkv/goose/v3#sql-migrations")
+		return nil, errors.New("failed to parse migration: must start with '-- +goose Up' annotation, see https://github.com/SergeiSkv/goose/v3#sql-migrations")
 	case gooseStatementBeginUp, gooseStatementBeginDown:
-		return nil, false, errors.New("failed to parse migration: missing '-- +goose StatementEnd' annotation")
+		return nil, errors.New("failed to parse migration: missing '-- +goose StatementEnd' annotation")
+	}
+
+	if parsed.Settings.Isolation != "" && !parsed.UseTx {
+		return nil, errors.New("failed to parse migration: '-- +goose Isolation' requires a transaction, it cannot be combined with '-- +goose NO TRANSACTION'")
 	}
 
 	if bufferRemaining := strings.TrimSpace(buf.String()); len(bufferRemaining) > 0 {
-		return nil, false, fmt.Errorf("failed to parse migration: state %d, direction: %v: unexpected unfinished SQL query: %q: missing semicolon?", stateMachine.state, direction, bufferRemaining)
+		return nil, fmt.Errorf("failed to parse migration: state %d, direction: %v: unexpected unfinished SQL query: %q: missing semicolon?", stateMachine.state, direction, bufferRemaining)
+	}
+
+	parsed.Statements = stmts
+	return parsed, nil
+}
+
+// inDirection reports whether lines in state belong to direction.
+func inDirection(state parserState, direction Direction) bool {
+	switch state {
+	case gooseUp, gooseStatementBeginUp, gooseStatementEndUp:
+		return direction == DirectionUp
+	case gooseDown, gooseStatementBeginDown, gooseStatementEndDown:
+		return direction == DirectionDown
 	}
+	return false
+}
 
-	return stmts, useTx, nil
+// IsRepeatable reports whether the SQL migration is annotated with
+// '-- +goose Repeatable'.
+func IsRepeatable(r io.Reader) (bool, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), scanBufSize)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "--") && strings.TrimSpace(strings.TrimPrefix(line, "--")) == "+goose Repeatable" {
+			return true, nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return false, fmt.Errorf("failed to scan migration: %w", err)
+	}
+	return false, nil
 }
 
 // cleanupStatement attempts to find the last semicolon and trims
@@ -116,6 +116,111 @@ func TestUseTransactions(t *testing.T) {
 	}
 }
 
+func TestRepeatable(t *testing.T) {
+	t.Parallel()
+
+	sql := `-- +goose Repeatable
+-- +goose Up
+CREATE OR REPLACE VIEW active_users AS SELECT * FROM users WHERE active;
+`
+	ok, err := IsRepeatable(strings.NewReader(sql))
+	check.NoError(t, err)
+	check.Bool(t, ok, true)
+	stmts, _, err := ParseSQLMigration(strings.NewReader(sql), DirectionUp, debug)
+	check.NoError(t, err)
+	check.Number(t, len(stmts), 1)
+
+	ok, err = IsRepeatable(strings.NewReader("-- +goose Up\nSELECT 1;\n"))
+	check.NoError(t, err)
+	check.Bool(t, ok, false)
+}
+
+func TestSettings(t *testing.T) {
+	t.Parallel()
+
+	sql := `-- +goose Set lock_timeout=5s
+-- +goose Set search_path = tenant, public
+-- +goose Role migrator
+-- +goose Isolation  Repeatable Read
+-- +goose Up
+ALTER TABLE users ADD email text;
+-- +goose Down
+ALTER TABLE users DROP email;
+`
+	for _, direction := range []Direction{DirectionUp, DirectionDown} {
+		parsed, err := Parse(strings.NewReader(sql), direction, debug, nil)
+		check.NoError(t, err)
+		check.Number(t, len(parsed.Statements), 1)
+		check.Bool(t, parsed.UseTx, true)
+		check.Equal(t, parsed.Settings, Settings{
+			Set: []Setting{
+				{Name: "lock_timeout", Value: "5s"},
+				{Name: "search_path", Value: "tenant, public"},
+			},
+			Role:      "migrator",
+			Isolation: "repeatable read",
+		})
+	}
+
+	parsed, err := Parse(strings.NewReader("-- +goose Up\nSELECT 1;\n"), DirectionUp, debug, nil)
+	check.NoError(t, err)
+	check.Bool(t, parsed.Settings.IsZero(), true)
+
+	for _, invalid := range []string{
+		"-- +goose Set lock_timeout\n-- +goose Up\nSELECT 1;\n",
+		"-- +goose Set lock timeout=5s\n-- +goose Up\nSELECT 1;\n",
+		"-- +goose Set a=1\n-- +goose Set a=2\n-- +goose Up\nSELECT 1;\n",
+		"-- +goose Role a\n-- +goose Role b\n-- +goose Up\nSELECT 1;\n",
+		"-- +goose Isolation snapshot\n-- +goose Up\nSELECT 1;\n",
+		"-- +goose NO TRANSACTION\n-- +goose Isolation serializable\n-- +goose Up\nSELECT 1;\n",
+	} {
+		_, err := Parse(strings.NewReader(invalid), DirectionUp, debug, nil)
+		check.HasError(t, err)
+	}
+}
+
+func TestEnvsub(t *testing.T) {
+	t.Parallel()
+
+	sql := `-- +goose Up
+-- +goose ENVSUB ON
+CREATE ROLE ${ROLE} PASSWORD '${DB_PASSWORD}';
+CREATE TABLE t (id int) TABLESPACE ${TABLESPACE:-pg_default};
+-- +goose ENVSUB OFF
+SELECT '${ROLE}';
+-- +goose Down
+-- +goose ENVSUB ON
+DROP ROLE ${DOWN_ROLE};
+`
+	env := EnvMap(map[string]string{"ROLE": "app", "DB_PASSWORD": "hunter2", "TABLESPACE": ""})
+	parsed, err := Parse(strings.NewReader(sql), DirectionUp, debug, env)
+	check.NoError(t, err)
+	check.Equal(t, parsed.Statements, []string{
+		"CREATE ROLE app PASSWORD 'hunter2';",
+		"CREATE TABLE t (id int) TABLESPACE pg_default;",
+		"SELECT '${ROLE}';",
+	})
+	check.Equal(t, parsed.Mask(parsed.Statements[0]), "CREATE ROLE app PASSWORD '******';")
+	check.Equal(t, parsed.Mask(parsed.Statements[1]), parsed.Statements[1])
+
+	// Without an env, references are left as is.
+	stmts, _, err := ParseSQLMigration(strings.NewReader(sql), DirectionUp, debug)
+	check.NoError(t, err)
+	check.Equal(t, stmts[0], "CREATE ROLE ${ROLE} PASSWORD '${DB_PASSWORD}';")
+
+	_, err = Parse(strings.NewReader(sql), DirectionDown, debug, env)
+	check.HasError(t, err)
+	check.Contains(t, err.Error(), `environment variable "DOWN_ROLE" is not set`)
+
+	for _, invalid := range []string{
+		"-- +goose Up\n-- +goose ENVSUB ON\nSELECT ${ROLE;\n",
+		"-- +goose Up\n-- +goose ENVSUB ON\nSELECT ${1};\n",
+	} {
+		_, err := Parse(strings.NewReader(invalid), DirectionUp, debug, env)
+		check.HasError(t, err)
+	}
+}
+
 func TestParsingErrors(t *testing.T) {
 	tt := []string{
 		statementBeginNoStatementEnd,
@@ -453,3 +558,35 @@ func isCIEnvironment() bool {
 	ok, _ := strconv.ParseBool(os.Getenv("CI"))
 	return ok
 }
+
+func TestRenderTemplate(t *testing.T) {
+	t.Parallel()
+
+	src := `-- +goose Up
+{{ range .Months }}
+CREATE TABLE events_{{ . }} PARTITION OF events FOR VALUES IN ('{{ upper . }}');
+{{- end }}
+-- +goose Down
+{{ range .Months }}
+DROP TABLE events_{{ . }};
+{{- end }}
+`
+	funcs := map[string]interface{}{"upper": strings.ToUpper}
+	data := map[string]interface{}{"Months": []string{"jan", "feb"}}
+	rendered, err := RenderTemplate("00001_events.sql.tmpl", []byte(src), data, funcs)
+	check.NoError(t, err)
+	stmts, _, err := ParseSQLMigration(strings.NewReader(string(rendered)), DirectionUp, debug)
+	check.NoError(t, err)
+	check.Equal(t, stmts, []string{
+		"CREATE TABLE events_jan PARTITION OF events FOR VALUES IN ('JAN');",
+		"CREATE TABLE events_feb PARTITION OF events FOR VALUES IN ('FEB');",
+	})
+
+	check.Bool(t, IsTemplate("00001_events.sql.tmpl"), true)
+	check.Bool(t, IsTemplate("00001_events.sql"), false)
+
+	_, err = RenderTemplate("a.sql.tmpl", []byte("{{ .Missing }}"), data, funcs)
+	check.HasError(t, err)
+	_, err = RenderTemplate("a.sql.tmpl", []byte("{{ lower .Months }}"), data, funcs)
+	check.HasError(t, err)
+}
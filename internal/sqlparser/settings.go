@@ -0,0 +1,104 @@
+package sqlparser
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Settings are the session settings a migration runs with, from its
+// '-- +goose Set', '-- +goose Role' and '-- +goose Isolation' annotations.
+type Settings struct {
+	// Set are the configuration parameters to set, in annotation order.
+	Set []Setting
+	// Role is the role to run the migration as, empty to keep the current
+	// one.
+	Role string
+	// Isolation is the isolation level of the migration transaction, such as
+	// "serializable", empty for the default.
+	Isolation string
+}
+
+// Setting is a configuration parameter, such as lock_timeout, and its value.
+type Setting struct {
+	Name  string
+	Value string
+}
+
+// IsZero reports whether the settings leave the session unchanged.
+func (s Settings) IsZero() bool {
+	return len(s.Set) == 0 && s.Role == "" && s.Isolation == ""
+}
+
+// Validate checks the setting names and the isolation level, as the
+// annotations do.
+func (s Settings) Validate() error {
+	for _, set := range s.Set {
+		if !matchSettingName.MatchString(set.Name) {
+			return fmt.Errorf("invalid setting name %q", set.Name)
+		}
+	}
+	if s.Isolation != "" && !isIsolationLevel(s.Isolation) {
+		return fmt.Errorf("isolation level must be one of %s, got %q", strings.Join(IsolationLevels, ", "), s.Isolation)
+	}
+	return nil
+}
+
+// IsolationLevels are the isolation levels accepted by the Isolation
+// annotation.
+var IsolationLevels = []string{"serializable", "repeatable read", "read committed", "read uncommitted"}
+
+var matchSettingName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.]*$`)
+
+// parseAnnotation records the setting of a '-- +goose' annotation, cmd being
+// the annotation without the leading '--'. It reports whether cmd is a
+// settings annotation.
+func (s *Settings) parseAnnotation(cmd string) (bool, error) {
+	switch {
+	case strings.HasPrefix(cmd, "+goose Set "):
+		name, value, ok := strings.Cut(strings.TrimPrefix(cmd, "+goose Set "), "=")
+		name, value = strings.TrimSpace(name), strings.TrimSpace(value)
+		if !ok || value == "" || !matchSettingName.MatchString(name) {
+			return true, fmt.Errorf("'-- +goose Set' must be of form '-- +goose Set NAME=VALUE', got %q", cmd)
+		}
+		for _, set := range s.Set {
+			if set.Name == name {
+				return true, fmt.Errorf("duplicate '-- +goose Set %s' annotations", name)
+			}
+		}
+		s.Set = append(s.Set, Setting{Name: name, Value: value})
+		return true, nil
+
+	case strings.HasPrefix(cmd, "+goose Role "):
+		role := strings.TrimSpace(strings.TrimPrefix(cmd, "+goose Role "))
+		if role == "" {
+			return true, fmt.Errorf("'-- +goose Role' must be of form '-- +goose Role NAME'")
+		}
+		if s.Role != "" {
+			return true, fmt.Errorf("duplicate '-- +goose Role' annotations")
+		}
+		s.Role = role
+		return true, nil
+
+	case strings.HasPrefix(cmd, "+goose Isolation "):
+		level := strings.ToLower(strings.Join(strings.Fields(strings.TrimPrefix(cmd, "+goose Isolation ")), " "))
+		if !isIsolationLevel(level) {
+			return true, fmt.Errorf("'-- +goose Isolation' must be one of %s, got %q", strings.Join(IsolationLevels, ", "), level)
+		}
+		if s.Isolation != "" {
+			return true, fmt.Errorf("duplicate '-- +goose Isolation' annotations")
+		}
+		s.Isolation = level
+		return true, nil
+	}
+	return false, nil
+}
+
+func isIsolationLevel(level string) bool {
+	for _, l := range IsolationLevels {
+		if l == level {
+			return true
+		}
+	}
+	return false
+}
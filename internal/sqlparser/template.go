@@ -0,0 +1,38 @@
+package sqlparser
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+// TemplateExt is the extension of SQL migrations rendered with text/template
+// before they are parsed.
+const TemplateExt = ".sql.tmpl"
+
+// IsTemplate reports whether filename is a SQL migration template.
+func IsTemplate(filename string) bool {
+	return strings.HasSuffix(filename, TemplateExt)
+}
+
+// RenderTemplate renders the SQL migration template named filename with data
+// and funcs. Referencing a key missing from data is an error.
+func RenderTemplate(filename string, src []byte, data map[string]interface{}, funcs template.FuncMap) ([]byte, error) {
+	tmpl, err := template.New(filepath.Base(filename)).
+		Funcs(funcs).
+		Option("missingkey=error").
+		Parse(string(src))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse template: %w", err)
+	}
+	if data == nil {
+		data = map[string]interface{}{}
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return nil, fmt.Errorf("failed to render template: %w", err)
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,388 @@
+package goose
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"time"
+
+	"github.com/SergeiSkv/goose/v3/internal/dialect"
+	"github.com/jackc/pgx/v5"
+)
+
+// ErrLockTimeout is returned when the migration lock could not be acquired
+// within the lock timeout.
+var ErrLockTimeout = errors.New("timed out waiting for migration lock")
+
+// errNoTableLock is returned for the table-based lock on ClickHouse, which has
+// no atomic primitive to take it with.
+var errNoTableLock = errors.New("the table lock is not supported on ClickHouse: it cannot be taken atomically, run one deployer at a time instead")
+
+// ErrLockLost is returned when the migration lock was lost while a command
+// held it, such as a table-based lock whose lease could not be renewed. The
+// command is cancelled as soon as the lock is lost.
+var ErrLockLost = errors.New("migration lock lost")
+
+// lockOptions configures how a Provider locks the database.
+type lockOptions struct {
+	locker Locker
+	// set reports whether locker was chosen with WithLocker or
+	// WithTableLocker, a nil locker then disabling locking.
+	set   bool
+	table bool
+	lease time.Duration
+	// timeout is how long to wait for the lock, 0 waits until the context is
+	// done.
+	timeout time.Duration
+}
+
+// SetLocker sets the Locker used by the package-level functions. Passing nil
+// disables locking. See WithLocker for the default.
+func SetLocker(l Locker) {
+	WithLocker(l)(&defaultOptions)
+}
+
+// SetTableLocker makes the package-level functions use a table-based lock,
+// see WithTableLocker.
+func SetTableLocker(lease time.Duration) {
+	WithTableLocker(lease)(&defaultOptions)
+}
+
+// SetLockTimeout sets how long the package-level functions wait for the lock,
+// 0 waits indefinitely.
+func SetLockTimeout(d time.Duration) {
+	WithLockTimeout(d)(&defaultOptions)
+}
+
+// Locker serializes goose commands that modify the database across processes,
+// so that concurrent deployers cannot apply the same migration twice.
+//
+// Lock must block until the lock is acquired or ctx is done. Unlock is called
+// on the same connection once the command has finished, even if it failed.
+type Locker interface {
+	Lock(ctx context.Context, db DB) error
+	Unlock(ctx context.Context, db DB) error
+}
+
+// leaseLocker is implemented by lockers that can lose the lock while it is
+// held. Lost returns a channel closed once the lock acquired by the last Lock
+// call is lost, the cause being returned by Unlock.
+type leaseLocker interface {
+	Locker
+	Lost() <-chan struct{}
+}
+
+// DefaultLockLease is the lease of a table-based lock, unless configured
+// otherwise.
+const DefaultLockLease = time.Minute
+
+// defaultLockRetryInterval is how long lockers wait between attempts to
+// acquire a lock held by another process.
+const defaultLockRetryInterval = time.Second
+
+// NewPostgresAdvisoryLocker returns a Locker backed by a Postgres session-level
+// advisory lock. The lock key is derived from the version table name, so
+// providers working on different version tables do not block each other.
+//
+// This is the default locker for the postgres dialect.
+func NewPostgresAdvisoryLocker(tableName string) Locker {
+	return &postgresAdvisoryLocker{
+		key:           advisoryLockKey(tableName),
+		retryInterval: defaultLockRetryInterval,
+	}
+}
+
+type postgresAdvisoryLocker struct {
+	key           int64
+	retryInterval time.Duration
+}
+
+var _ Locker = (*postgresAdvisoryLocker)(nil)
+
+func (l *postgresAdvisoryLocker) Lock(ctx context.Context, db DB) error {
+	for {
+		var locked bool
+		if err := db.QueryRow(ctx, `SELECT pg_try_advisory_lock($1)`, l.key).Scan(&locked); err != nil {
+			return fmt.Errorf("failed to acquire advisory lock %d: %w", l.key, err)
+		}
+		if locked {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(l.retryInterval):
+		}
+	}
+}
+
+func (l *postgresAdvisoryLocker) Unlock(ctx context.Context, db DB) error {
+	var unlocked bool
+	if err := db.QueryRow(ctx, `SELECT pg_advisory_unlock($1)`, l.key).Scan(&unlocked); err != nil {
+		return fmt.Errorf("failed to release advisory lock %d: %w", l.key, err)
+	}
+	if !unlocked {
+		return fmt.Errorf("failed to release advisory lock %d: lock was not held", l.key)
+	}
+	return nil
+}
+
+// advisoryLockKey returns a stable lock key for the version table name.
+func advisoryLockKey(tableName string) int64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte("goose:" + tableName))
+	return int64(h.Sum64())
+}
+
+// newTableLocker returns a Locker backed by a row of the goose_lock table,
+// keyed by the version table of store.
+//
+// The holder of the lock is identified by an owner id and holds a lease that is
+// renewed by a heartbeat while the lock is held. A lock whose lease has expired,
+// for example because its holder crashed, is taken over by the next process
+// trying to acquire it. If a renewal fails, the lock is considered lost and the
+// command holding it is cancelled, see ErrLockLost. The lock row and the heartbeat use a dedicated
+// connection, opened with the configuration of a *pgx.Conn or taken from the
+// pool of a *sql.DB, see openLockConn.
+func newTableLocker(store dialect.Store, lease time.Duration) *tableLocker {
+	if lease <= 0 {
+		lease = DefaultLockLease
+	}
+	return &tableLocker{
+		store:         store,
+		owner:         newLockOwner(),
+		lease:         lease,
+		retryInterval: defaultLockRetryInterval,
+	}
+}
+
+type tableLocker struct {
+	store         dialect.Store
+	owner         string
+	lease         time.Duration
+	retryInterval time.Duration
+
+	// Set while the lock is held.
+	conn Conn
+	stop chan struct{}
+	done chan struct{}
+	// lost is closed by the heartbeat once the lease could not be renewed,
+	// lostErr being the cause.
+	lost    chan struct{}
+	lostErr error
+}
+
+var _ leaseLocker = (*tableLocker)(nil)
+
+func (l *tableLocker) Lock(ctx context.Context, db DB) error {
+	if l.conn != nil {
+		return errors.New("table lock is already held")
+	}
+	// The heartbeat runs concurrently with the migrations, so it cannot
+	// share their connection.
+	conn, err := openLockConn(ctx, db)
+	if err != nil {
+		return fmt.Errorf("failed to open lock connection: %w", err)
+	}
+	if err := l.acquire(ctx, conn); err != nil {
+		_ = conn.Close(context.Background())
+		return err
+	}
+	l.conn = conn
+	l.stop = make(chan struct{})
+	l.done = make(chan struct{})
+	l.lost = make(chan struct{})
+	l.lostErr = nil
+	go l.heartbeat()
+	return nil
+}
+
+// openLockConn opens a connection to the database of db, for the lock row and
+// the heartbeat.
+func openLockConn(ctx context.Context, db DB) (Conn, error) {
+	switch db := db.(type) {
+	case *pgx.Conn:
+		conn, err := pgx.ConnectConfig(ctx, db.Config())
+		if err != nil {
+			return nil, err
+		}
+		return conn, nil
+	case *sqlDB:
+		if pool, ok := db.db.(*sql.DB); ok {
+			conn, err := pool.Conn(ctx)
+			if err != nil {
+				return nil, err
+			}
+			return NewSQLDB(conn), nil
+		}
+	}
+	return nil, fmt.Errorf("cannot open a connection from %T, use a *pgx.Conn or a *sql.DB", db)
+}
+
+func (l *tableLocker) acquire(ctx context.Context, conn Conn) error {
+	if err := l.store.CreateLockTable(ctx, conn); err != nil {
+		return fmt.Errorf("failed to create lock table: %w", err)
+	}
+	for {
+		now := time.Now()
+		locked, err := l.store.TryLock(ctx, conn, l.owner, now, now.Add(l.lease))
+		if err != nil {
+			return fmt.Errorf("failed to acquire table lock: %w", err)
+		}
+		if locked {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(l.retryInterval):
+		}
+	}
+}
+
+// heartbeat renews the lease until stopped. The lock is lost as soon as a
+// renewal fails: another process may take it over once the lease expires, so
+// the command must not keep running on the assumption it still holds it.
+func (l *tableLocker) heartbeat() {
+	defer close(l.done)
+	ticker := time.NewTicker(l.lease / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-l.stop:
+			return
+		case <-ticker.C:
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), l.lease/3)
+		held, err := l.store.RefreshLock(ctx, l.conn, l.owner, time.Now().Add(l.lease))
+		cancel()
+		switch {
+		case err != nil:
+			l.lostErr = fmt.Errorf("%w: failed to renew table lock lease: %v", ErrLockLost, err)
+		case !held:
+			l.lostErr = fmt.Errorf("%w: table lock was taken over by another owner", ErrLockLost)
+		default:
+			continue
+		}
+		close(l.lost)
+		return
+	}
+}
+
+// Lost returns a channel closed once the lease of the lock could not be
+// renewed.
+func (l *tableLocker) Lost() <-chan struct{} {
+	return l.lost
+}
+
+func (l *tableLocker) Unlock(ctx context.Context, _ DB) error {
+	if l.conn == nil {
+		return errors.New("table lock is not held")
+	}
+	close(l.stop)
+	<-l.done
+	conn := l.conn
+	l.conn = nil
+	defer conn.Close(context.Background())
+	if l.lostErr != nil {
+		// The row is still ours if only the renewal failed, release it
+		// rather than waiting for the lease to expire.
+		_ = l.store.ReleaseLock(ctx, conn, l.owner)
+		return l.lostErr
+	}
+	return l.store.ReleaseLock(ctx, conn, l.owner)
+}
+
+// newLockOwner returns an owner id that identifies this process in the lock
+// table.
+func newLockOwner() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+	b := make([]byte, 4)
+	_, _ = rand.Read(b)
+	return fmt.Sprintf("%s-%d-%s", host, os.Getpid(), hex.EncodeToString(b))
+}
+
+// ForceUnlock releases the table-based lock regardless of its owner. See
+// WithTableLocker.
+func ForceUnlock(db DB) error {
+	return ForceUnlockContext(context.Background(), db)
+}
+
+// ForceUnlockContext releases the table-based lock regardless of its owner.
+func ForceUnlockContext(ctx context.Context, db DB) error {
+	p, err := newGlobalProvider(db, "")
+	if err != nil {
+		return err
+	}
+	return p.ForceUnlock(ctx)
+}
+
+// ForceUnlock releases the table-based lock of the provider version table
+// regardless of its owner. It is meant to recover from a holder that is known
+// to be gone, without waiting for its lease to expire.
+func (p *Provider) ForceUnlock(ctx context.Context) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.dialect == DialectClickHouse {
+		return errNoTableLock
+	}
+	if err := p.store.ForceReleaseLock(ctx, p.db); err != nil {
+		return fmt.Errorf("failed to release table lock: %w", err)
+	}
+	p.log(ctx, LevelInfo, "table lock released", "goose: released table lock for %s\n", Field{Key: "table", Value: p.tableName})
+	return nil
+}
+
+// withLock runs fn while holding the provider lock. Without a locker, or for
+// dry runs, fn is run directly. The context passed to fn is cancelled if the
+// lock is lost while fn runs, fn then fails with ErrLockLost.
+func (p *Provider) withLock(ctx context.Context, option *options, fn func(ctx context.Context) error) error {
+	if p.lock.locker == nil || option.dryRun {
+		return fn(ctx)
+	}
+	lockCtx := ctx
+	if p.lock.timeout > 0 {
+		var cancel context.CancelFunc
+		lockCtx, cancel = context.WithTimeout(ctx, p.lock.timeout)
+		defer cancel()
+	}
+	if err := p.lock.locker.Lock(lockCtx, p.db); err != nil {
+		if ctx.Err() == nil && errors.Is(err, context.DeadlineExceeded) {
+			return withClass(ErrLock, fmt.Errorf("%w after %s", ErrLockTimeout, p.lock.timeout))
+		}
+		return withClass(ErrLock, fmt.Errorf("failed to acquire lock: %w", err))
+	}
+	fnCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	if l, ok := p.lock.locker.(leaseLocker); ok {
+		done := make(chan struct{})
+		defer close(done)
+		go func() {
+			select {
+			case <-l.Lost():
+				cancel()
+			case <-done:
+			}
+		}()
+	}
+	err := fn(fnCtx)
+	// Release the lock even if the command context has been cancelled.
+	unlockErr := p.lock.locker.Unlock(context.Background(), p.db)
+	switch {
+	case errors.Is(unlockErr, ErrLockLost):
+		// Whatever fn returned, most likely a cancellation, the cause is
+		// the lost lock.
+		return withClass(ErrLock, unlockErr)
+	case unlockErr != nil && err == nil:
+		err = fmt.Errorf("failed to release lock: %w", unlockErr)
+	}
+	return err
+}
@@ -0,0 +1,142 @@
+package goose
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/SergeiSkv/goose/v3/internal/check"
+	"github.com/jackc/pgx/v5"
+)
+
+type recordingLocker struct {
+	calls   []string
+	lockErr error
+}
+
+func (l *recordingLocker) Lock(context.Context, DB) error {
+	l.calls = append(l.calls, "lock")
+	return l.lockErr
+}
+
+func (l *recordingLocker) Unlock(context.Context, DB) error {
+	l.calls = append(l.calls, "unlock")
+	return nil
+}
+
+// blockingLocker never acquires the lock.
+type blockingLocker struct{}
+
+func (blockingLocker) Lock(ctx context.Context, _ DB) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func (blockingLocker) Unlock(context.Context, DB) error { return nil }
+
+func TestProviderLock(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	newProvider := func(t *testing.T, ran *[]string, opts ...ProviderOptionsFunc) *Provider {
+		t.Helper()
+		opts = append(opts,
+			WithGoMigrations(NewGoMigrationNoTx("00001_a.go",
+				func(context.Context, DB) error {
+					*ran = append(*ran, "up")
+					return nil
+				},
+				func(context.Context, DB) error {
+					*ran = append(*ran, "down")
+					return nil
+				},
+			)),
+		)
+		p, _ := newSQLiteProvider(t, fstest.MapFS{}, opts...)
+		return p
+	}
+
+	t.Run("default", func(t *testing.T) {
+		p, err := NewProvider(DialectPostgres, &pgx.Conn{}, nil)
+		check.NoError(t, err)
+		check.Bool(t, p.lock.locker != nil, true)
+		p, err = NewProvider(DialectSQLite3, &pgx.Conn{}, nil)
+		check.NoError(t, err)
+		check.Bool(t, p.lock.locker == nil, true)
+	})
+	t.Run("no table lock on ClickHouse", func(t *testing.T) {
+		_, err := NewProvider(DialectClickHouse, &pgx.Conn{}, nil, WithTableLocker(0))
+		check.IsError(t, err, errNoTableLock)
+	})
+	t.Run("held around commands", func(t *testing.T) {
+		locker := &recordingLocker{}
+		var ran []string
+		p := newProvider(t, &ran, WithLocker(locker))
+		_, err := p.Up(ctx)
+		check.NoError(t, err)
+		_, err = p.Down(ctx)
+		check.NoError(t, err)
+		check.Equal(t, ran, []string{"up", "down"})
+		check.Equal(t, locker.calls, []string{"lock", "unlock", "lock", "unlock"})
+	})
+	t.Run("lock error", func(t *testing.T) {
+		errBoom := errors.New("boom")
+		locker := &recordingLocker{lockErr: errBoom}
+		var ran []string
+		p := newProvider(t, &ran, WithLocker(locker))
+		_, err := p.Up(ctx)
+		check.IsError(t, err, errBoom)
+		check.Number(t, len(ran), 0)
+		check.Equal(t, locker.calls, []string{"lock"})
+	})
+	t.Run("timeout", func(t *testing.T) {
+		var ran []string
+		p := newProvider(t, &ran,
+			WithLocker(blockingLocker{}),
+			WithLockTimeout(10*time.Millisecond),
+		)
+		_, err := p.Up(ctx)
+		check.IsError(t, err, ErrLockTimeout)
+		check.Number(t, len(ran), 0)
+	})
+	t.Run("lost lease cancels the command", func(t *testing.T) {
+		var db *sql.DB
+		migration := NewGoMigrationNoTx("00001_a.go",
+			func(ctx context.Context, _ DB) error {
+				// Another process takes the lock over, the next renewal
+				// fails.
+				if _, err := db.ExecContext(ctx, "UPDATE goose_lock SET owner = 'other'"); err != nil {
+					return err
+				}
+				select {
+				case <-ctx.Done():
+					return ctx.Err()
+				case <-time.After(10 * time.Second):
+					return errors.New("migration was not cancelled")
+				}
+			},
+			nil,
+		)
+		var p *Provider
+		p, db = newSQLiteProvider(t, fstest.MapFS{},
+			WithTableLocker(300*time.Millisecond),
+			WithGoMigrations(migration),
+		)
+		start := time.Now()
+		_, err := p.Up(ctx)
+		check.IsError(t, err, ErrLockLost)
+		check.IsError(t, err, ErrLock)
+		check.Bool(t, time.Since(start) < 5*time.Second, true)
+		check.Number(t, count(t, db, "SELECT COUNT(*) FROM goose_db_version WHERE version_id = 1"), 0)
+	})
+}
+
+func TestAdvisoryLockKey(t *testing.T) {
+	t.Parallel()
+
+	check.Number(t, advisoryLockKey("goose_db_version"), advisoryLockKey("goose_db_version"))
+	check.Bool(t, advisoryLockKey("goose_db_version") != advisoryLockKey("other_version"), true)
+}
@@ -1,7 +1,10 @@
 package goose
 
 import (
+	"context"
+	"fmt"
 	std "log"
+	"time"
 )
 
 var log Logger = &stdLogger{}
@@ -43,3 +46,83 @@ func (*nopLogger) Fatalf(format string, v ...interface{}) {}
 func (*nopLogger) Print(v ...interface{})                 {}
 func (*nopLogger) Println(v ...interface{})               {}
 func (*nopLogger) Printf(format string, v ...interface{}) {}
+
+// Level is the severity of a log event. Its values match those of log/slog.
+type Level int
+
+const (
+	LevelDebug Level = -4
+	LevelInfo  Level = 0
+	LevelWarn  Level = 4
+	LevelError Level = 8
+)
+
+func (l Level) String() string {
+	switch l {
+	case LevelDebug:
+		return "DEBUG"
+	case LevelInfo:
+		return "INFO"
+	case LevelWarn:
+		return "WARN"
+	case LevelError:
+		return "ERROR"
+	}
+	return fmt.Sprintf("LEVEL(%d)", int(l))
+}
+
+// Field is a key/value pair of a log event.
+//
+// The most common keys are version, file, direction, duration (a
+// time.Duration), statement and current_version.
+type Field struct {
+	Key   string
+	Value interface{}
+}
+
+// StructuredLogger receives the events of a Provider as a level, a message
+// that does not vary between occurrences of the event, such as "migration
+// applied", and fields. Debug events, such as the statements being executed,
+// are only reported in verbose mode. See also NewSlogLogger.
+type StructuredLogger interface {
+	Log(ctx context.Context, level Level, msg string, fields ...Field)
+}
+
+// loggingOptions configures the output of a Provider.
+type loggingOptions struct {
+	logger Logger
+	// structured, if set, receives events instead of logger.
+	structured StructuredLogger
+	verbose    bool
+}
+
+// SetStructuredLogger sets the StructuredLogger used by the package-level
+// functions, see WithStructuredLogger.
+func SetStructuredLogger(l StructuredLogger) {
+	WithStructuredLogger(l)(&defaultOptions)
+}
+
+// WithStructuredLogger reports the events of the provider to l instead of
+// printing them as text to the Logger set with WithLogger.
+func WithStructuredLogger(l StructuredLogger) ProviderOptionsFunc {
+	return func(o *providerOptions) { o.logging.structured = l }
+}
+
+// log reports an event. A structured logger receives msg and fields, the text
+// logger prints format with the values of fields as arguments, durations
+// being rounded.
+func (p *Provider) log(ctx context.Context, level Level, msg, format string, fields ...Field) {
+	if p.logging.structured != nil {
+		p.logging.structured.Log(ctx, level, msg, fields...)
+		return
+	}
+	args := make([]interface{}, 0, len(fields))
+	for _, f := range fields {
+		if d, ok := f.Value.(time.Duration); ok {
+			args = append(args, truncateDuration(d))
+		} else {
+			args = append(args, f.Value)
+		}
+	}
+	p.logging.logger.Printf(format, args...)
+}
@@ -0,0 +1,26 @@
+//go:build go1.21
+
+package goose
+
+import (
+	"context"
+	"log/slog"
+)
+
+// NewSlogLogger returns a StructuredLogger reporting events to l, for use
+// with WithStructuredLogger. Fields become attributes of the records.
+func NewSlogLogger(l *slog.Logger) StructuredLogger {
+	return &slogLogger{l: l}
+}
+
+type slogLogger struct {
+	l *slog.Logger
+}
+
+func (s *slogLogger) Log(ctx context.Context, level Level, msg string, fields ...Field) {
+	attrs := make([]slog.Attr, 0, len(fields))
+	for _, f := range fields {
+		attrs = append(attrs, slog.Any(f.Key, f.Value))
+	}
+	s.l.LogAttrs(ctx, slog.Level(level), msg, attrs...)
+}
@@ -0,0 +1,43 @@
+//go:build go1.21
+
+package goose
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/SergeiSkv/goose/v3/internal/check"
+)
+
+func TestSlogLogger(t *testing.T) {
+	t.Parallel()
+
+	fsys := fstest.MapFS{
+		"00001_a.sql": {Data: []byte("-- +goose Up\nSELECT 1;\nSELECT 2;\n")},
+	}
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	p := newTestProvider(t, DialectPostgres, fsys, nil, WithStructuredLogger(NewSlogLogger(logger)))
+	_, err := p.Up(context.Background(), WithDryRun())
+	check.NoError(t, err)
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	check.Number(t, len(lines), 2)
+	var record map[string]interface{}
+	check.NoError(t, json.Unmarshal([]byte(lines[0]), &record))
+	check.Equal(t, record["level"], "INFO")
+	check.Equal(t, record["msg"], "migration planned")
+	check.Equal(t, record["version"], float64(1))
+	check.Equal(t, record["file"], "00001_a.sql")
+	check.Equal(t, record["direction"], "up")
+	check.Equal(t, record["tx"], "tx")
+	check.Equal(t, record["statements"], float64(2))
+	check.NoError(t, json.Unmarshal([]byte(lines[1]), &record))
+	check.Equal(t, record["msg"], "no migrations to run")
+	check.Equal(t, record["current_version"], float64(1))
+}
@@ -0,0 +1,44 @@
+package goose
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/SergeiSkv/goose/v3/internal/check"
+)
+
+// bufferLogger is a Logger printing to a buffer.
+type bufferLogger struct {
+	nopLogger
+	strings.Builder
+}
+
+func (l *bufferLogger) Printf(format string, v ...interface{}) {
+	fmt.Fprintf(&l.Builder, format, v...)
+}
+
+func TestTextLogger(t *testing.T) {
+	t.Parallel()
+
+	fsys := fstest.MapFS{
+		"00001_a.sql": {Data: []byte("-- +goose NO TRANSACTION\n-- +goose Up\nSELECT 1;\nSELECT 2;\n")},
+	}
+	logger := &bufferLogger{}
+	p := newTestProvider(t, DialectPostgres, fsys, nil, WithLogger(logger))
+	_, err := p.Up(context.Background(), WithDryRun())
+	check.NoError(t, err)
+	check.Equal(t, logger.String(), "PLAN up   00001_a.sql (no tx, 2 statements)\n"+
+		"goose: no migrations to run. current version: 1\n")
+
+	logger.Reset()
+	p.log(context.Background(), LevelInfo, "migration applied", "OK   %[2]s (%[3]s)\n",
+		Field{Key: "version", Value: int64(1)},
+		Field{Key: "file", Value: "00001_a.sql"},
+		Field{Key: "duration", Value: 1234567 * time.Nanosecond},
+	)
+	check.Equal(t, logger.String(), "OK   00001_a.sql (1.23ms)\n")
+}
@@ -12,6 +12,8 @@ import (
 	"strings"
 	"time"
 
+	"github.com/SergeiSkv/goose/v3/internal/dialect"
+	"github.com/SergeiSkv/goose/v3/internal/dialect/dialectquery"
 	"github.com/jackc/pgx/v5"
 )
 
@@ -130,7 +132,15 @@ func (ms Migrations) String() string {
 type GoMigration func(tx pgx.Tx) error
 
 // GoMigrationNoTx is a Go migration func that is run outside a transaction.
-type GoMigrationNoTx func(db *pgx.Conn) error
+type GoMigrationNoTx func(db DB) error
+
+// GoMigrationContext is a Go migration func that is run within a transaction
+// and receives the context of the running command.
+type GoMigrationContext func(ctx context.Context, tx pgx.Tx) error
+
+// GoMigrationNoTxContext is a Go migration func that is run outside a
+// transaction and receives the context of the running command.
+type GoMigrationNoTxContext func(ctx context.Context, db DB) error
 
 // AddMigration adds Go migrations.
 func AddMigration(up, down GoMigration) {
@@ -140,7 +150,9 @@ func AddMigration(up, down GoMigration) {
 
 // AddNamedMigration adds named Go migrations.
 func AddNamedMigration(filename string, up, down GoMigration) {
-	if err := register(filename, true, up, down, nil, nil); err != nil {
+	m := newGoMigration(filename, true, withContext(up), withContext(down), nil, nil)
+	m.UpFn, m.DownFn = up, down
+	if err := register(m); err != nil {
 		panic(err)
 	}
 }
@@ -153,46 +165,113 @@ func AddMigrationNoTx(up, down GoMigrationNoTx) {
 
 // AddNamedMigrationNoTx adds named Go migrations that will be run outside transaction.
 func AddNamedMigrationNoTx(filename string, up, down GoMigrationNoTx) {
-	if err := register(filename, false, nil, nil, up, down); err != nil {
+	m := newGoMigration(filename, false, nil, nil, withContextNoTx(up), withContextNoTx(down))
+	m.UpFnNoTx, m.DownFnNoTx = up, down
+	if err := register(m); err != nil {
 		panic(err)
 	}
 }
 
-func register(
-	filename string,
-	useTx bool,
-	up, down GoMigration,
-	upNoTx, downNoTx GoMigrationNoTx,
-) error {
-	// Sanity check caller did not mix tx and non-tx based functions.
-	if (up != nil || down != nil) && (upNoTx != nil || downNoTx != nil) {
-		return fmt.Errorf("cannot mix tx and non-tx based go migrations functions")
+// AddMigrationContext adds Go migrations that receive the command context.
+func AddMigrationContext(up, down GoMigrationContext, opts ...GoMigrationOption) {
+	_, filename, _, _ := runtime.Caller(1)
+	AddNamedMigrationContext(filename, up, down, opts...)
+}
+
+// AddNamedMigrationContext adds named Go migrations that receive the command context.
+func AddNamedMigrationContext(filename string, up, down GoMigrationContext, opts ...GoMigrationOption) {
+	if err := register(newGoMigration(filename, true, up, down, nil, nil, opts...)); err != nil {
+		panic(err)
 	}
-	v, _ := NumericComponent(filename)
-	if existing, ok := registeredGoMigrations[v]; ok {
+}
+
+// AddMigrationNoTxContext adds Go migrations that will be run outside
+// transaction and receive the command context.
+func AddMigrationNoTxContext(up, down GoMigrationNoTxContext, opts ...GoMigrationOption) {
+	_, filename, _, _ := runtime.Caller(1)
+	AddNamedMigrationNoTxContext(filename, up, down, opts...)
+}
+
+// AddNamedMigrationNoTxContext adds named Go migrations that will be run
+// outside transaction and receive the command context.
+func AddNamedMigrationNoTxContext(filename string, up, down GoMigrationNoTxContext, opts ...GoMigrationOption) {
+	if err := register(newGoMigration(filename, false, nil, nil, up, down, opts...)); err != nil {
+		panic(err)
+	}
+}
+
+func register(m *Migration) error {
+	if existing, ok := registeredGoMigrations[m.Version]; ok {
 		return fmt.Errorf("failed to add migration %q: version %d conflicts with %q",
-			filename,
-			v,
+			m.Source,
+			m.Version,
 			existing.Source,
 		)
 	}
 	// Add to global as a registered migration.
-	registeredGoMigrations[v] = &Migration{
-		Version:    v,
-		Next:       -1,
-		Previous:   -1,
-		Registered: true,
-		Source:     filename,
-		UseTx:      useTx,
-		UpFn:       up,
-		DownFn:     down,
-		UpFnNoTx:   upNoTx,
-		DownFnNoTx: downNoTx,
-	}
+	registeredGoMigrations[m.Version] = m
 	return nil
 }
 
-func collectMigrationsFS(fsys fs.FS, dirpath string, current, target int64) (Migrations, error) {
+// NewGoMigration returns a Go migration, run within a transaction, for use
+// with a single Provider, see WithGoMigrations. The filename must be in the
+// same form as a migration file, e.g. 00002_add_users.go.
+func NewGoMigration(filename string, up, down GoMigrationContext, opts ...GoMigrationOption) *Migration {
+	return newGoMigration(filename, true, up, down, nil, nil, opts...)
+}
+
+// NewGoMigrationNoTx returns a Go migration, run outside a transaction, for
+// use with a single Provider, see WithGoMigrations.
+func NewGoMigrationNoTx(filename string, up, down GoMigrationNoTxContext, opts ...GoMigrationOption) *Migration {
+	return newGoMigration(filename, false, nil, nil, up, down, opts...)
+}
+
+func newGoMigration(
+	filename string,
+	useTx bool,
+	up, down GoMigrationContext,
+	upNoTx, downNoTx GoMigrationNoTxContext,
+	opts ...GoMigrationOption,
+) *Migration {
+	v, _ := NumericComponent(filename)
+	m := &Migration{
+		Version:           v,
+		Next:              -1,
+		Previous:          -1,
+		Registered:        true,
+		Source:            filename,
+		UseTx:             useTx,
+		UpFnContext:       up,
+		DownFnContext:     down,
+		UpFnNoTxContext:   upNoTx,
+		DownFnNoTxContext: downNoTx,
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
+}
+
+func withContext(fn GoMigration) GoMigrationContext {
+	if fn == nil {
+		return nil
+	}
+	return func(_ context.Context, tx pgx.Tx) error { return fn(tx) }
+}
+
+func withContextNoTx(fn GoMigrationNoTx) GoMigrationNoTxContext {
+	if fn == nil {
+		return nil
+	}
+	return func(_ context.Context, db DB) error { return fn(db) }
+}
+
+func collectMigrationsFS(
+	fsys fs.FS,
+	dirpath string,
+	current, target int64,
+	registered map[int64]*Migration,
+) (Migrations, error) {
 	if _, err := fs.Stat(fsys, dirpath); errors.Is(err, fs.ErrNotExist) {
 		return nil, fmt.Errorf("%s directory does not exist", dirpath)
 	}
@@ -200,14 +279,17 @@ func collectMigrationsFS(fsys fs.FS, dirpath string, current, target int64) (Mig
 	var migrations Migrations
 
 	// SQL migration files.
-	sqlMigrationFiles, err := fs.Glob(fsys, path.Join(dirpath, "*.sql"))
+	sqlMigrationFiles, err := globSQLMigrations(fsys, dirpath)
 	if err != nil {
 		return nil, err
 	}
 	for _, file := range sqlMigrationFiles {
 		v, err := NumericComponent(file)
 		if err != nil {
-			return nil, fmt.Errorf("could not parse SQL migration file %q: %w", file, err)
+			if repeatable, rerr := isRepeatable(fsys, file); rerr == nil && repeatable {
+				continue // Repeatable migrations are collected separately.
+			}
+			return nil, withClass(ErrParse, fmt.Errorf("could not parse SQL migration file %q: %w", file, err))
 		}
 		if versionFilter(v, current, target) {
 			migration := &Migration{Version: v, Next: -1, Previous: -1, Source: file}
@@ -216,10 +298,10 @@ func collectMigrationsFS(fsys fs.FS, dirpath string, current, target int64) (Mig
 	}
 
 	// Go migrations registered via goose.AddMigration().
-	for _, migration := range registeredGoMigrations {
+	for _, migration := range registered {
 		v, err := NumericComponent(migration.Source)
 		if err != nil {
-			return nil, fmt.Errorf("could not parse go migration file %q: %w", migration.Source, err)
+			return nil, withClass(ErrParse, fmt.Errorf("could not parse go migration file %q: %w", migration.Source, err))
 		}
 		if versionFilter(v, current, target) {
 			migrations = append(migrations, migration)
@@ -242,7 +324,7 @@ func collectMigrationsFS(fsys fs.FS, dirpath string, current, target int64) (Mig
 		}
 
 		// Skip migrations already existing migrations registered via goose.AddMigration().
-		if _, ok := registeredGoMigrations[v]; ok {
+		if _, ok := registered[v]; ok {
 			continue
 		}
 
@@ -260,7 +342,7 @@ func collectMigrationsFS(fsys fs.FS, dirpath string, current, target int64) (Mig
 // CollectMigrations returns all the valid looking migration scripts in the
 // migrations folder and go func registry, and key them by version.
 func CollectMigrations(dirpath string, current, target int64) (Migrations, error) {
-	return collectMigrationsFS(baseFS, dirpath, current, target)
+	return collectMigrationsFS(baseFS, dirpath, current, target, registeredGoMigrations)
 }
 
 func sortAndConnectMigrations(migrations Migrations) Migrations {
@@ -295,12 +377,76 @@ func versionFilter(v, current, target int64) bool {
 
 // EnsureDBVersion retrieves the current version for this DB.
 // Create and initialize the DB version table if it doesn't exist.
-func EnsureDBVersion(db *pgx.Conn) (int64, error) {
-	ctx := context.Background()
-	dbMigrations, err := store.ListMigrations(ctx, db)
+func EnsureDBVersion(db DB) (int64, error) {
+	return EnsureDBVersionContext(context.Background(), db)
+}
+
+// EnsureDBVersionContext retrieves the current version for this DB.
+// Create and initialize the DB version table if it doesn't exist.
+func EnsureDBVersionContext(ctx context.Context, db DB) (int64, error) {
+	p, err := newGlobalProvider(db, "")
+	if err != nil {
+		return 0, err
+	}
+	return p.EnsureDBVersion(ctx)
+}
+
+// GetDBVersion retrieves the current version for this DB, see
+// Provider.GetDBVersion.
+func GetDBVersion(db DB) (int64, error) {
+	return GetDBVersionContext(context.Background(), db)
+}
+
+// GetDBVersionContext retrieves the current version for this DB, see
+// Provider.GetDBVersion.
+func GetDBVersionContext(ctx context.Context, db DB) (int64, error) {
+	p, err := newGlobalProvider(db, "")
+	if err != nil {
+		return -1, err
+	}
+	return p.GetDBVersion(ctx)
+}
+
+// EnsureDBVersion retrieves the current version for this DB.
+// Create and initialize the DB version table if it doesn't exist, and upgrade
+// one created by an older version of goose, holding the provider lock.
+func (p *Provider) EnsureDBVersion(ctx context.Context) (int64, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	option := p.applyOptions(nil)
+	var current int64
+	err := p.withLock(ctx, option, func(ctx context.Context) (err error) {
+		current, err = p.ensureDBVersion(ctx, option)
+		return err
+	})
+	return current, err
+}
+
+// GetDBVersion retrieves the current version for this DB, or -1 in error.
+// Unlike EnsureDBVersion, it does not modify the database: the version is 0 if
+// the version table does not exist, and a version table created by an older
+// version of goose is reported as such rather than upgraded.
+func (p *Provider) GetDBVersion(ctx context.Context) (int64, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.readDBVersion(ctx)
+}
+
+func (p *Provider) ensureDBVersion(ctx context.Context, option *options) (int64, error) {
+	// Upgrade failures must not be mistaken for a missing table below.
+	if err := p.upgradeVersionTable(ctx, option); err != nil {
+		return 0, err
+	}
+	dbMigrations, err := p.listMigrations(ctx, option)
 	if err != nil {
-		return 0, createVersionTable(ctx, db)
+		return 0, p.createVersionTable(ctx)
 	}
+	return currentVersion(dbMigrations)
+}
+
+// currentVersion returns the current version of the rows of the version
+// table, ordered by descending id.
+func currentVersion(dbMigrations []*dialect.ListMigrationsResult) (int64, error) {
 	// The most recent record for each migration specifies
 	// whether it has been applied or rolled back.
 	// The first version we find that has been applied is the current version.
@@ -330,27 +476,135 @@ func EnsureDBVersion(db *pgx.Conn) (int64, error) {
 	return 0, ErrNoNextVersion
 }
 
+// readDBVersion retrieves the current version without modifying the
+// database, see GetDBVersion.
+func (p *Provider) readDBVersion(ctx context.Context) (int64, error) {
+	exists, err := p.checkVersionTable(ctx)
+	if err != nil {
+		return -1, err
+	}
+	if !exists {
+		return 0, nil
+	}
+	dbMigrations, err := p.store.ListMigrations(ctx, p.db)
+	if err != nil {
+		return -1, err
+	}
+	current, err := currentVersion(dbMigrations)
+	if err != nil {
+		return -1, err
+	}
+	return current, nil
+}
+
+// checkVersionTable reports whether the version table exists, without
+// creating or upgrading it, for the commands that only read it and do not hold
+// the lock. A version table created by an older version of goose must first
+// be upgraded by a command modifying the database.
+func (p *Provider) checkVersionTable(ctx context.Context) (bool, error) {
+	if p.versionTableChecked {
+		return true, nil
+	}
+	generation, err := p.store.VersionTableGeneration(ctx, p.db)
+	if err != nil {
+		return false, fmt.Errorf("failed to detect version table schema: %w", err)
+	}
+	switch {
+	case generation == 0:
+		return false, nil
+	case generation < dialectquery.LatestGeneration:
+		return false, fmt.Errorf("version table %s has the schema generation %d of an older version of goose, run a command modifying the database, such as up, to upgrade it to %d",
+			p.tableName, generation, dialectquery.LatestGeneration)
+	}
+	p.versionTableChecked = true
+	return true, nil
+}
+
+// listMigrations lists the version table, ordered by descending id. Version
+// tables created by older versions of goose are upgraded first.
+//
+// Dry runs read the table once and then work on a copy that records the
+// planned migrations, a missing version table is treated as a new one.
+func (p *Provider) listMigrations(ctx context.Context, option *options) ([]*dialect.ListMigrationsResult, error) {
+	if option.dryRun && option.dryRunVersions != nil {
+		return option.dryRunVersions, nil
+	}
+	if err := p.upgradeVersionTable(ctx, option); err != nil {
+		return nil, err
+	}
+	dbMigrations, err := p.store.ListMigrations(ctx, p.db)
+	if !option.dryRun {
+		return dbMigrations, err
+	}
+	if err != nil {
+		// createVersionTable would insert version 0.
+		dbMigrations = []*dialect.ListMigrationsResult{{VersionID: 0, IsApplied: true}}
+		if option.script != nil {
+			if err := p.writeScriptVersionTable(option); err != nil {
+				return nil, err
+			}
+		}
+	}
+	option.dryRunVersions = dbMigrations
+	return dbMigrations, nil
+}
+
+// upgradeVersionTable brings a version table created by an older version of
+// goose to the latest schema generation. The table is checked once per
+// provider, a missing table is created with the latest schema later on.
+func (p *Provider) upgradeVersionTable(ctx context.Context, option *options) error {
+	if p.versionTableChecked {
+		return nil
+	}
+	generation, err := p.store.VersionTableGeneration(ctx, p.db)
+	if err != nil {
+		return fmt.Errorf("failed to detect version table schema: %w", err)
+	}
+	if generation == 0 {
+		return nil
+	}
+	if generation < dialectquery.LatestGeneration {
+		if option.dryRun {
+			return fmt.Errorf("version table %s must be upgraded from schema generation %d, run the command without dry run first",
+				p.tableName, generation)
+		}
+		p.log(ctx, LevelInfo, "upgrading version table", "goose: upgrading version table %s from schema generation %d to %d\n",
+			Field{Key: "table", Value: p.tableName},
+			Field{Key: "generation", Value: generation},
+			Field{Key: "target_generation", Value: dialectquery.LatestGeneration},
+		)
+		if err := p.store.UpgradeVersionTable(ctx, p.db, generation); err != nil {
+			return fmt.Errorf("failed to upgrade version table %s: %w", p.tableName, err)
+		}
+	}
+	p.versionTableChecked = true
+	return nil
+}
+
 // createVersionTable creates the db version table and inserts the
 // initial 0 value into it.
-func createVersionTable(ctx context.Context, db *pgx.Conn) error {
-	txn, err := db.Begin(ctx)
+func (p *Provider) createVersionTable(ctx context.Context) error {
+	txn, err := p.db.Begin(ctx)
 	if err != nil {
 		return err
 	}
-	if err = store.CreateVersionTable(ctx, txn); err != nil {
-		_ = txn.Rollback(ctx)
+	if err = p.store.CreateVersionTable(ctx, txn); err != nil {
+		_ = rollback(txn)
 		return err
 	}
-	if err = store.InsertVersion(ctx, txn, 0); err != nil {
-		_ = txn.Rollback(ctx)
+	if err = p.store.InsertVersion(ctx, txn, 0, ""); err != nil {
+		_ = rollback(txn)
 		return err
 	}
-	return txn.Commit(ctx)
+	if err := txn.Commit(ctx); err != nil {
+		return err
+	}
+	p.versionTableChecked = true
+	return nil
 }
 
-// GetDBVersion is an alias for EnsureDBVersion, but returns -1 in error.
-func GetDBVersion(db *pgx.Conn) (int64, error) {
-	version, err := EnsureDBVersion(db)
+func (p *Provider) getDBVersion(ctx context.Context, option *options) (int64, error) {
+	version, err := p.ensureDBVersion(ctx, option)
 	if err != nil {
 		return -1, err
 	}
@@ -1,7 +1,13 @@
 package goose
 
 import (
+	"context"
 	"testing"
+	"testing/fstest"
+
+	"github.com/SergeiSkv/goose/v3/internal/check"
+	"github.com/SergeiSkv/goose/v3/internal/dialect/dialectquery"
+	"github.com/jackc/pgx/v5"
 )
 
 func TestMigrationSort(t *testing.T) {
@@ -56,3 +62,101 @@ func validateMigrationSort(t *testing.T, ms Migrations, sorted []int64) {
 
 	t.Log(ms)
 }
+
+func TestGoMigrationContext(t *testing.T) {
+	t.Parallel()
+
+	type ctxKey struct{}
+	ctx := context.WithValue(context.Background(), ctxKey{}, "value")
+
+	var got interface{}
+	m := NewGoMigration("00001_a.go", func(ctx context.Context, _ pgx.Tx) error {
+		got = ctx.Value(ctxKey{})
+		return nil
+	}, nil)
+	check.NoError(t, m.goFunc(true)(ctx, nil))
+	check.Equal(t, got, "value")
+	check.Bool(t, m.goFunc(false) == nil, true)
+
+	// Context-less functions are wrapped.
+	var called bool
+	legacy := &Migration{UpFn: func(pgx.Tx) error { called = true; return nil }}
+	check.NoError(t, legacy.goFunc(true)(ctx, nil))
+	check.Bool(t, called, true)
+	check.Bool(t, legacy.goFuncNoTx(true) == nil, true)
+}
+
+func TestUpgradeVersionTable(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	p, db := newSQLiteProvider(t, fstest.MapFS{
+		"00001_a.sql": {Data: []byte("-- +goose Up\nCREATE TABLE a (id INTEGER);\n")},
+	})
+	// The version table as created before checksums were recorded.
+	_, err := db.Exec(`CREATE TABLE goose_db_version (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		version_id INTEGER NOT NULL,
+		is_applied INTEGER NOT NULL,
+		tstamp TIMESTAMP DEFAULT (datetime('now'))
+	)`)
+	check.NoError(t, err)
+	_, err = db.Exec("INSERT INTO goose_db_version (version_id, is_applied) VALUES (0, 1)")
+	check.NoError(t, err)
+	generation, err := p.store.VersionTableGeneration(ctx, p.db)
+	check.NoError(t, err)
+	check.Number(t, generation, dialectquery.GenerationInitial)
+
+	// Neither must the commands reading it, which do not hold the lock.
+	_, err = p.GetDBVersion(ctx)
+	check.HasError(t, err)
+	check.Contains(t, err.Error(), "schema generation 1 of an older version of goose")
+	_, err = p.ListStatus(ctx)
+	check.HasError(t, err)
+	generation, err = p.store.VersionTableGeneration(ctx, p.db)
+	check.NoError(t, err)
+	check.Number(t, generation, dialectquery.GenerationInitial)
+
+	// Dry runs must not alter the table.
+	_, err = p.Up(ctx, WithDryRun())
+	check.HasError(t, err)
+	check.Contains(t, err.Error(), "must be upgraded")
+	generation, err = p.store.VersionTableGeneration(ctx, p.db)
+	check.NoError(t, err)
+	check.Number(t, generation, dialectquery.GenerationInitial)
+
+	_, err = p.Up(ctx)
+	check.NoError(t, err)
+	generation, err = p.store.VersionTableGeneration(ctx, p.db)
+	check.NoError(t, err)
+	check.Number(t, generation, dialectquery.LatestGeneration)
+	check.Number(t, count(t, db, "SELECT COUNT(*) FROM goose_db_version WHERE version_id = 1 AND checksum IS NOT NULL"), 1)
+}
+
+func TestReadVersionTable(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	p, db := newSQLiteProvider(t, fstest.MapFS{
+		"00001_a.sql": {Data: []byte("-- +goose Up\nCREATE TABLE a (id INTEGER);\n")},
+	})
+	// Reading a pristine database does not create the version table.
+	version, err := p.GetDBVersion(ctx)
+	check.NoError(t, err)
+	check.Number(t, version, 0)
+	statuses, err := p.ListStatus(ctx)
+	check.NoError(t, err)
+	check.Number(t, len(statuses), 1)
+	check.Bool(t, statuses[0].Applied, false)
+	check.NoError(t, p.Version(ctx))
+	check.Number(t, count(t, db, "SELECT COUNT(*) FROM sqlite_master WHERE name = 'goose_db_version'"), 0)
+
+	_, err = p.Up(ctx)
+	check.NoError(t, err)
+	version, err = p.GetDBVersion(ctx)
+	check.NoError(t, err)
+	check.Number(t, version, 1)
+	statuses, err = p.ListStatus(ctx)
+	check.NoError(t, err)
+	check.Bool(t, statuses[0].Applied, true)
+}
@@ -1,6 +1,7 @@
 package goose
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
@@ -20,6 +21,34 @@ type MigrationRecord struct {
 	IsApplied bool // was this a result of up() or down()
 }
 
+// Direction is the direction a migration is run in.
+type Direction = sqlparser.Direction
+
+const (
+	DirectionUp   Direction = sqlparser.DirectionUp
+	DirectionDown Direction = sqlparser.DirectionDown
+)
+
+// MigrationResult is the outcome of running a single migration.
+type MigrationResult struct {
+	Version   int64
+	Source    string
+	Direction Direction
+	Duration  time.Duration
+	// Empty is true if the migration had no statements, or no Go function,
+	// to run in this direction. The version table is updated regardless.
+	Empty bool
+	// StatementCount is the number of SQL statements run. Go migrations
+	// count as a single statement.
+	StatementCount int
+	// UseTx is true if the migration runs within a transaction.
+	UseTx bool
+	// DryRun is true if the migration was only planned, see WithDryRun.
+	DryRun bool
+	// Error is the error the migration failed with, if any.
+	Error error
+}
+
 // Migration struct.
 type Migration struct {
 	Version              int64
@@ -28,9 +57,18 @@ type Migration struct {
 	Source               string // path to .sql script or go file
 	Registered           bool
 	UseTx                bool
+	Repeatable           bool // repeatable SQL migration without a version, see CollectRepeatableMigrations
 	UpFn, DownFn         GoMigration
 	UpFnNoTx, DownFnNoTx GoMigrationNoTx
-	noVersioning         bool
+
+	// UpFnContext and friends take precedence over their context-less
+	// counterparts above when set.
+	UpFnContext, DownFnContext         GoMigrationContext
+	UpFnNoTxContext, DownFnNoTxContext GoMigrationNoTxContext
+
+	// Settings are the session settings of Go migrations, see
+	// SessionSettings.
+	Settings SessionSettings
 }
 
 func (m *Migration) String() string {
@@ -38,166 +76,285 @@ func (m *Migration) String() string {
 }
 
 // Up runs an up migration.
-func (m *Migration) Up(db *pgx.Conn) error {
-	ctx := context.Background()
-	if err := m.run(ctx, db, true); err != nil {
+func (m *Migration) Up(db DB) error {
+	return m.UpContext(context.Background(), db)
+}
+
+// UpContext runs an up migration.
+func (m *Migration) UpContext(ctx context.Context, db DB) error {
+	p, err := newGlobalProvider(db, "")
+	if err != nil {
 		return err
 	}
-	return nil
+	_, err = p.ApplyMigration(ctx, m, true)
+	return err
 }
 
 // Down runs a down migration.
-func (m *Migration) Down(db *pgx.Conn) error {
-	ctx := context.Background()
-	if err := m.run(ctx, db, false); err != nil {
+func (m *Migration) Down(db DB) error {
+	return m.DownContext(context.Background(), db)
+}
+
+// DownContext runs a down migration.
+func (m *Migration) DownContext(ctx context.Context, db DB) error {
+	p, err := newGlobalProvider(db, "")
+	if err != nil {
 		return err
 	}
-	return nil
+	_, err = p.ApplyMigration(ctx, m, false)
+	return err
 }
 
-func (m *Migration) run(ctx context.Context, db *pgx.Conn, direction bool) error {
+// goFunc returns the Go migration func, run within a transaction, for the
+// given direction.
+func (m *Migration) goFunc(direction bool) GoMigrationContext {
+	if direction {
+		if m.UpFnContext != nil {
+			return m.UpFnContext
+		}
+		return withContext(m.UpFn)
+	}
+	if m.DownFnContext != nil {
+		return m.DownFnContext
+	}
+	return withContext(m.DownFn)
+}
+
+// goFuncNoTx returns the Go migration func, run outside a transaction, for
+// the given direction.
+func (m *Migration) goFuncNoTx(direction bool) GoMigrationNoTxContext {
+	if direction {
+		if m.UpFnNoTxContext != nil {
+			return m.UpFnNoTxContext
+		}
+		return withContextNoTx(m.UpFnNoTx)
+	}
+	if m.DownFnNoTxContext != nil {
+		return m.DownFnNoTxContext
+	}
+	return withContextNoTx(m.DownFnNoTx)
+}
+
+// ApplyMigration runs a single migration in the given direction (up=true,
+// down=false) and records the change in the version table.
+func (p *Provider) ApplyMigration(ctx context.Context, m *Migration, direction bool, opts ...OptionsFunc) (*MigrationResult, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	option := p.applyOptions(opts)
+	var result *MigrationResult
+	_, err := p.runCommand(ctx, "apply", option, func(ctx context.Context) ([]*MigrationResult, error) {
+		var err error
+		result, err = p.runMigration(ctx, m, direction, option)
+		return []*MigrationResult{result}, err
+	})
+	return result, err
+}
+
+// runMigration runs a single migration. The returned result is never nil, on
+// failure its Error field holds the returned error.
+func (p *Provider) runMigration(ctx context.Context, m *Migration, direction bool, option *options) (*MigrationResult, error) {
+	result := &MigrationResult{
+		Version:   m.Version,
+		Source:    m.Source,
+		Direction: sqlparser.FromBool(direction),
+	}
+	ctx, span := p.startMigrationSpan(ctx, m, direction)
+	defer func() {
+		span.SetAttributes(Attribute{Key: "goose.tx", Value: result.UseTx})
+		endSpan(span, result.Error)
+	}()
+	fail := func(err error) (*MigrationResult, error) {
+		result.Error = err
+		option.failed = m
+		option.failedDirection = result.Direction
+		return result, err
+	}
+	if option.dryRun {
+		return p.planMigration(ctx, m, direction, option, result)
+	}
 	switch filepath.Ext(m.Source) {
-	case ".sql":
-		f, err := baseFS.Open(m.Source)
+	case ".sql", ".tmpl":
+		data, rendered, err := p.readSQLMigration(m)
 		if err != nil {
-			return fmt.Errorf("ERROR %v: failed to open SQL migration file: %w", filepath.Base(m.Source), err)
+			return fail(err)
 		}
-		defer f.Close()
 
-		statements, useTx, err := sqlparser.ParseSQLMigration(f, sqlparser.FromBool(direction), verbose)
+		parsed, err := sqlparser.Parse(bytes.NewReader(rendered), sqlparser.FromBool(direction), p.logging.verbose, p.env())
 		if err != nil {
-			return fmt.Errorf("ERROR %v: failed to parse SQL migration file: %w", filepath.Base(m.Source), err)
+			return fail(withClass(ErrParse, fmt.Errorf("ERROR %v: failed to parse SQL migration file: %w", filepath.Base(m.Source), err)))
 		}
-
 		start := time.Now()
-		if err := runSQLMigration(ctx, db, statements, useTx, m.Version, direction, m.noVersioning); err != nil {
-			return fmt.Errorf("ERROR %v: failed to run SQL migration: %w", filepath.Base(m.Source), err)
+		run := func() error {
+			return p.runSQLMigration(ctx, m, parsed, checksum(data), direction, option)
 		}
-		finish := truncateDuration(time.Since(start))
-
-		if len(statements) > 0 {
-			log.Printf("OK   %s (%s)\n", filepath.Base(m.Source), finish)
+		if parsed.UseTx {
+			err = p.withRetry(ctx, m, run)
 		} else {
-			log.Printf("EMPTY %s (%s)\n", filepath.Base(m.Source), finish)
+			err = run()
+		}
+		result.Duration = time.Since(start)
+		if err != nil {
+			return fail(withClass(ErrExecution, fmt.Errorf("ERROR %v: failed to run SQL migration: %w", filepath.Base(m.Source), err)))
 		}
+		result.StatementCount = len(parsed.Statements)
+		result.Empty = len(parsed.Statements) == 0
+		result.UseTx = parsed.UseTx
 
 	case ".go":
 		if !m.Registered {
-			return fmt.Errorf("ERROR %v: failed to run Go migration: Go functions must be registered and built into a custom binary (see https://github.com/SergeiSkv/goose/v3/tree/master/examples/go-migrations)", m.Source)
+			return fail(withClass(ErrExecution, fmt.Errorf("ERROR %v: failed to run Go migration: Go functions must be registered and built into a custom binary (see https://github.com/SergeiSkv/goose/v3/tree/master/examples/go-migrations)", m.Source)))
 		}
 		start := time.Now()
 		var empty bool
+		result.UseTx = m.UseTx
 		if m.UseTx {
 			// Run go-based migration inside a tx.
-			fn := m.DownFn
-			if direction {
-				fn = m.UpFn
-			}
+			fn := m.goFunc(direction)
 			empty = (fn == nil)
-			if err := runGoMigration(
-				ctx,
-				db,
-				fn,
-				m.Version,
-				direction,
-				!m.noVersioning,
-			); err != nil {
-				return fmt.Errorf("ERROR go migration: %q: %w", filepath.Base(m.Source), err)
+			err := p.withRetry(ctx, m, func() error {
+				return p.runGoMigration(
+					ctx,
+					m,
+					fn,
+					direction,
+					!option.noVersioning,
+				)
+			})
+			result.Duration = time.Since(start)
+			if err != nil {
+				return fail(withClass(ErrExecution, fmt.Errorf("ERROR go migration: %q: %w", filepath.Base(m.Source), err)))
 			}
 		} else {
 			// Run go-based migration outside a tx.
-			fn := m.DownFnNoTx
-			if direction {
-				fn = m.UpFnNoTx
-			}
+			fn := m.goFuncNoTx(direction)
 			empty = (fn == nil)
-			if err := runGoMigrationNoTx(
+			err := p.runGoMigrationNoTx(
 				ctx,
-				db,
+				m,
 				fn,
-				m.Version,
 				direction,
-				!m.noVersioning,
-			); err != nil {
-				return fmt.Errorf("ERROR go migration no tx: %q: %w", filepath.Base(m.Source), err)
+				!option.noVersioning,
+			)
+			result.Duration = time.Since(start)
+			if err != nil {
+				return fail(withClass(ErrExecution, fmt.Errorf("ERROR go migration no tx: %q: %w", filepath.Base(m.Source), err)))
 			}
 		}
-		finish := truncateDuration(time.Since(start))
+		result.Empty = empty
 		if !empty {
-			log.Printf("OK   %s (%s)\n", filepath.Base(m.Source), finish)
-		} else {
-			log.Printf("EMPTY %s (%s)\n", filepath.Base(m.Source), finish)
+			result.StatementCount = 1
 		}
+	default:
+		return result, nil
 	}
-	return nil
+
+	fields := []Field{
+		{Key: "version", Value: m.Version},
+		{Key: "file", Value: filepath.Base(m.Source)},
+		{Key: "duration", Value: result.Duration},
+		{Key: "direction", Value: string(result.Direction)},
+	}
+	if !result.Empty {
+		p.log(ctx, LevelInfo, "migration applied", "OK   %[2]s (%[3]s)\n", fields...)
+	} else {
+		p.log(ctx, LevelInfo, "empty migration applied", "EMPTY %[2]s (%[3]s)\n", fields...)
+	}
+	return result, nil
 }
 
-func runGoMigrationNoTx(
+func (p *Provider) runGoMigrationNoTx(
 	ctx context.Context,
-	db *pgx.Conn,
-	fn GoMigrationNoTx,
-	version int64,
+	m *Migration,
+	fn GoMigrationNoTxContext,
 	direction bool,
 	recordVersion bool,
-) error {
+) (err error) {
+	reset, err := p.setSession(ctx, m.Settings)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if resetErr := reset(); resetErr != nil && err == nil {
+			err = resetErr
+		}
+	}()
+	if err := p.beforeEach(ctx, m, direction, p.db); err != nil {
+		return err
+	}
 	if fn != nil {
 		// Run go migration function.
-		if err := fn(db); err != nil {
+		if err := fn(ctx, p.db); err != nil {
 			return fmt.Errorf("failed to run go migration: %w", err)
 		}
 	}
 	if recordVersion {
-		return insertOrDeleteVersionNoTx(ctx, db, version, direction)
+		if err := p.insertOrDeleteVersionNoTx(ctx, m.Version, direction); err != nil {
+			return err
+		}
 	}
-	return nil
+	return p.afterEach(ctx, m, direction, p.db)
 }
 
-func runGoMigration(
+func (p *Provider) runGoMigration(
 	ctx context.Context,
-	db *pgx.Conn,
-	fn GoMigration,
-	version int64,
+	m *Migration,
+	fn GoMigrationContext,
 	direction bool,
 	recordVersion bool,
 ) error {
-	if fn == nil && !recordVersion {
+	if fn == nil && !recordVersion && len(p.hooks) == 0 {
 		return nil
 	}
-	tx, err := db.Begin(ctx)
+	tx, err := p.beginTx(ctx, m.Settings)
 	if err != nil {
-		return fmt.Errorf("failed to begin transaction: %w", err)
+		return err
+	}
+	if err := p.beforeEach(ctx, m, direction, tx); err != nil {
+		_ = rollback(tx)
+		return err
 	}
 	if fn != nil {
 		// Run go migration function.
-		if err := fn(tx); err != nil {
-			_ = tx.Rollback(ctx)
+		if err := fn(ctx, tx); err != nil {
+			_ = rollback(tx)
 			return fmt.Errorf("failed to run go migration: %w", err)
 		}
 	}
 	if recordVersion {
-		if err := insertOrDeleteVersion(ctx, tx, version, direction); err != nil {
-			_ = tx.Rollback(ctx)
+		if err := p.insertOrDeleteVersion(ctx, tx, m.Version, direction); err != nil {
+			_ = rollback(tx)
 			return fmt.Errorf("failed to update version: %w", err)
 		}
 	}
+	if err := p.afterEach(ctx, m, direction, tx); err != nil {
+		_ = rollback(tx)
+		return err
+	}
 	if err := tx.Commit(ctx); err != nil {
 		return fmt.Errorf("failed to commit transaction: %w", err)
 	}
 	return nil
 }
 
-func insertOrDeleteVersion(ctx context.Context, tx pgx.Tx, version int64, direction bool) error {
+// rollback rolls back tx without the command context. Once the context is
+// cancelled pgx would refuse to send the ROLLBACK and drop the connection
+// instead, leaving the server to clean up the transaction.
+func rollback(tx pgx.Tx) error {
+	return tx.Rollback(context.Background())
+}
+
+func (p *Provider) insertOrDeleteVersion(ctx context.Context, tx pgx.Tx, version int64, direction bool) error {
 	if direction {
-		return store.InsertVersion(ctx, tx, version)
+		return p.store.InsertVersion(ctx, tx, version, "")
 	}
-	return store.DeleteVersion(ctx, tx, version)
+	return p.store.DeleteVersion(ctx, tx, version)
 }
 
-func insertOrDeleteVersionNoTx(ctx context.Context, db *pgx.Conn, version int64, direction bool) error {
+func (p *Provider) insertOrDeleteVersionNoTx(ctx context.Context, version int64, direction bool) error {
 	if direction {
-		return store.InsertVersionNoTx(ctx, db, version)
+		return p.store.InsertVersionNoTx(ctx, p.db, version, "")
 	}
-	return store.DeleteVersionNoTx(ctx, db, version)
+	return p.store.DeleteVersionNoTx(ctx, p.db, version)
 }
 
 // NumericComponent looks for migration scripts with names in the form:
@@ -206,7 +363,7 @@ func insertOrDeleteVersionNoTx(ctx context.Context, db *pgx.Conn, version int64,
 func NumericComponent(name string) (int64, error) {
 	base := filepath.Base(name)
 
-	if ext := filepath.Ext(base); ext != ".go" && ext != ".sql" {
+	if ext := filepath.Ext(base); ext != ".go" && ext != ".sql" && !sqlparser.IsTemplate(base) {
 		return 0, errors.New("not a recognized migration file type")
 	}
 
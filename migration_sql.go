@@ -3,9 +3,10 @@ package goose
 import (
 	"context"
 	"fmt"
+	"path/filepath"
 	"regexp"
 
-	"github.com/jackc/pgx/v5"
+	"github.com/SergeiSkv/goose/v3/internal/sqlparser"
 )
 
 // Run a migration specified in raw SQL.
@@ -16,51 +17,72 @@ import (
 //
 // All statements following an Up or Down annotation are grouped together
 // until another direction annotation is found.
-func runSQLMigration(
+func (p *Provider) runSQLMigration(
 	ctx context.Context,
-	db *pgx.Conn,
-	statements []string,
-	useTx bool,
-	v int64,
+	m *Migration,
+	parsed *sqlparser.Parsed,
+	checksum string,
 	direction bool,
-	noVersioning bool,
-) error {
-	if useTx {
+	option *options,
+) (err error) {
+	if parsed.UseTx {
 		// TRANSACTION.
 
-		verboseInfo("Begin transaction")
+		p.verboseInfo(ctx, option, "begin transaction", "Begin transaction")
 
-		tx, err := db.Begin(ctx)
+		tx, err := p.beginTx(ctx, parsed.Settings)
 		if err != nil {
-			return fmt.Errorf("failed to begin transaction: %w", err)
+			return err
 		}
 
-		for _, query := range statements {
-			verboseInfo("Executing statement: %s\n", clearStatement(query))
-			if _, err = tx.Exec(ctx, query); err != nil {
-				verboseInfo("Rollback transaction")
-				_ = tx.Rollback(ctx)
-				return fmt.Errorf("failed to execute SQL query %q: %w", clearStatement(query), err)
+		if err := p.beforeEach(ctx, m, direction, tx); err != nil {
+			p.verboseInfo(ctx, option, "rollback transaction", "Rollback transaction")
+			_ = rollback(tx)
+			return err
+		}
+
+		for _, query := range parsed.Statements {
+			p.verboseInfo(ctx, option, "executing statement", "Executing statement: %s\n",
+				Field{Key: "statement", Value: clearStatement(parsed.Mask(query))})
+			if err = p.traceStatement(ctx, parsed, query, func(ctx context.Context) error {
+				_, err := tx.Exec(ctx, query)
+				return err
+			}); err != nil {
+				p.verboseInfo(ctx, option, "rollback transaction", "Rollback transaction")
+				_ = rollback(tx)
+				return fmt.Errorf("failed to execute SQL query %q: %w", clearStatement(parsed.Mask(query)), err)
 			}
 		}
 
-		if !noVersioning {
-			if direction {
-				if err := store.InsertVersion(ctx, tx, v); err != nil {
-					verboseInfo("Rollback transaction")
-					_ = tx.Rollback(ctx)
+		if !option.noVersioning {
+			if m.Repeatable {
+				if err := p.store.SetRepeatable(ctx, tx, filepath.Base(m.Source), checksum); err != nil {
+					p.verboseInfo(ctx, option, "rollback transaction", "Rollback transaction")
+					_ = rollback(tx)
+					return fmt.Errorf("failed to record repeatable migration: %w", err)
+				}
+			} else if direction {
+				if err := p.store.InsertVersion(ctx, tx, m.Version, checksum); err != nil {
+					p.verboseInfo(ctx, option, "rollback transaction", "Rollback transaction")
+					_ = rollback(tx)
 					return fmt.Errorf("failed to insert new goose version: %w", err)
 				}
 			} else {
-				if err := store.DeleteVersion(ctx, tx, v); err != nil {
-					verboseInfo("Rollback transaction")
-					_ = tx.Rollback(ctx)
+				if err := p.store.DeleteVersion(ctx, tx, m.Version); err != nil {
+					p.verboseInfo(ctx, option, "rollback transaction", "Rollback transaction")
+					_ = rollback(tx)
 					return fmt.Errorf("failed to delete goose version: %w", err)
 				}
 			}
 		}
 
-		verboseInfo("Commit transaction")
+		if err := p.afterEach(ctx, m, direction, tx); err != nil {
+			p.verboseInfo(ctx, option, "rollback transaction", "Rollback transaction")
+			_ = rollback(tx)
+			return err
+		}
+
+		p.verboseInfo(ctx, option, "commit transaction", "Commit transaction")
 		if err = tx.Commit(ctx); err != nil {
 			return fmt.Errorf("failed to commit transaction: %w", err)
 		}
@@ -69,25 +91,45 @@ func runSQLMigration(
 	}
 
 	// NO TRANSACTION.
-	for _, query := range statements {
-		verboseInfo("Executing statement: %s", clearStatement(query))
-		if _, err := db.Exec(ctx, query); err != nil {
-			return fmt.Errorf("failed to execute SQL query %q: %w", clearStatement(query), err)
+	reset, err := p.setSession(ctx, parsed.Settings)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if resetErr := reset(); resetErr != nil && err == nil {
+			err = resetErr
 		}
+	}()
+	if err := p.beforeEach(ctx, m, direction, p.db); err != nil {
+		return err
 	}
-	if !noVersioning {
-		if direction {
-			if err := store.InsertVersionNoTx(ctx, db, v); err != nil {
+	for _, query := range parsed.Statements {
+		p.verboseInfo(ctx, option, "executing statement", "Executing statement: %s",
+			Field{Key: "statement", Value: clearStatement(parsed.Mask(query))})
+		if err := p.traceStatement(ctx, parsed, query, func(ctx context.Context) error {
+			_, err := p.db.Exec(ctx, query)
+			return err
+		}); err != nil {
+			return fmt.Errorf("failed to execute SQL query %q: %w", clearStatement(parsed.Mask(query)), err)
+		}
+	}
+	if !option.noVersioning {
+		if m.Repeatable {
+			if err := p.store.SetRepeatableNoTx(ctx, p.db, filepath.Base(m.Source), checksum); err != nil {
+				return fmt.Errorf("failed to record repeatable migration: %w", err)
+			}
+		} else if direction {
+			if err := p.store.InsertVersionNoTx(ctx, p.db, m.Version, checksum); err != nil {
 				return fmt.Errorf("failed to insert new goose version: %w", err)
 			}
 		} else {
-			if err := store.DeleteVersionNoTx(ctx, db, v); err != nil {
+			if err := p.store.DeleteVersionNoTx(ctx, p.db, m.Version); err != nil {
 				return fmt.Errorf("failed to delete goose version: %w", err)
 			}
 		}
 	}
 
-	return nil
+	return p.afterEach(ctx, m, direction, p.db)
 }
 
 const (
@@ -95,14 +137,15 @@ const (
 	resetColor = "\033[00m"
 )
 
-func verboseInfo(s string, args ...interface{}) {
-	if verbose {
-		if noColor {
-			log.Printf(s, args...)
-		} else {
-			log.Printf(grayColor+s+resetColor, args...)
-		}
+// verboseInfo reports a debug event in verbose mode, see Provider.log.
+func (p *Provider) verboseInfo(ctx context.Context, option *options, msg, format string, fields ...Field) {
+	if !p.logging.verbose {
+		return
+	}
+	if p.logging.structured == nil && !option.noColor {
+		format = grayColor + format + resetColor
 	}
+	p.log(ctx, LevelDebug, msg, format, fields...)
 }
 
 var (
@@ -0,0 +1,87 @@
+package goose
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"path/filepath"
+
+	"github.com/SergeiSkv/goose/v3/internal/dialect"
+	"github.com/SergeiSkv/goose/v3/internal/sqlparser"
+)
+
+// WithDryRun plans the command instead of running it: the migrations that
+// would be applied or rolled back are reported in order, along with their
+// transaction mode and statement count, but nothing is run and the database,
+// including the version table, is left untouched.
+//
+// The plan is based on the migration sources and the version table, so it
+// follows the same ordering rules as the command itself, such as
+// WithAllowMissing.
+func WithDryRun() OptionsFunc {
+	return func(o *options) { o.dryRun = true }
+}
+
+// planMigration reports the migration as planned and records it in the dry
+// run version table.
+func (p *Provider) planMigration(ctx context.Context, m *Migration, direction bool, option *options, result *MigrationResult) (*MigrationResult, error) {
+	result.DryRun = true
+	switch filepath.Ext(m.Source) {
+	case ".sql", ".tmpl":
+		data, rendered, err := p.readSQLMigration(m)
+		if err != nil {
+			result.Error = err
+			return result, result.Error
+		}
+		parsed, err := sqlparser.Parse(bytes.NewReader(rendered), sqlparser.FromBool(direction), false, p.env())
+		if err != nil {
+			result.Error = withClass(ErrParse, fmt.Errorf("ERROR %v: failed to parse SQL migration file: %w", filepath.Base(m.Source), err))
+			return result, result.Error
+		}
+		result.StatementCount = len(parsed.Statements)
+		result.UseTx = parsed.UseTx
+		if option.script != nil {
+			if err := p.writeScriptMigration(option, m, direction, parsed, checksum(data)); err != nil {
+				result.Error = err
+				return result, err
+			}
+		}
+	case ".go":
+		if option.script != nil {
+			result.Error = fmt.Errorf("ERROR %v: Go migrations cannot be rendered as SQL", filepath.Base(m.Source))
+			return result, result.Error
+		}
+		result.UseTx = m.UseTx
+		if (m.UseTx && m.goFunc(direction) != nil) || (!m.UseTx && m.goFuncNoTx(direction) != nil) {
+			result.StatementCount = 1
+		}
+	default:
+		return result, nil
+	}
+	result.Empty = result.StatementCount == 0
+
+	txMode := "no tx"
+	if result.UseTx {
+		txMode = "tx"
+	}
+	p.log(ctx, LevelInfo, "migration planned", "PLAN %-4[2]s %[3]s (%[4]s, %[5]d statements)\n",
+		Field{Key: "version", Value: m.Version},
+		Field{Key: "direction", Value: string(result.Direction)},
+		Field{Key: "file", Value: filepath.Base(m.Source)},
+		Field{Key: "tx", Value: txMode},
+		Field{Key: "statements", Value: result.StatementCount},
+	)
+
+	if !option.noVersioning && !m.Repeatable {
+		if direction {
+			option.dryRunVersions = append(
+				[]*dialect.ListMigrationsResult{{VersionID: m.Version, IsApplied: true}},
+				option.dryRunVersions...,
+			)
+		} else {
+			// DeleteVersion removes every row of the version.
+			option.dryRunVersions = withoutVersion(option.dryRunVersions, m.Version)
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,157 @@
+package goose
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"testing/fstest"
+
+	"github.com/SergeiSkv/goose/v3/internal/check"
+	"github.com/SergeiSkv/goose/v3/internal/dialect"
+)
+
+func TestDryRun(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	fsys := fstest.MapFS{
+		"00001_a.sql": {Data: []byte("-- +goose Up\nSELECT 1;\n-- +goose Down\nSELECT 1;\n")},
+		"00002_b.sql": {Data: []byte("-- +goose NO TRANSACTION\n-- +goose Up\nSELECT 2;\nSELECT 2;\n-- +goose Down\nSELECT 2;\n")},
+		"00003_c.sql": {Data: []byte("-- +goose Up\nSELECT 3;\n-- +goose Down\n")},
+		"00004_d.sql": {Data: []byte("-- +goose Up\nSELECT 4;\n-- +goose Down\nSELECT 4;\n")},
+	}
+	// Versions 1, 2 and 4 are applied, 3 is missing.
+	p := newTestProvider(t, DialectPostgres, fsys, []*dialect.ListMigrationsResult{
+		{VersionID: 4, IsApplied: true},
+		{VersionID: 2, IsApplied: true},
+		{VersionID: 1, IsApplied: true},
+		{VersionID: 0, IsApplied: true},
+	}, WithGoMigrations(NewGoMigrationNoTx("00005_e.go", nil, nil)))
+	// Dry runs must not make any other query.
+	p.store.(*memoryStore).Store = nil
+	type step struct {
+		version   int64
+		direction Direction
+	}
+	steps := func(results []*MigrationResult) []step {
+		var got []step
+		for _, r := range results {
+			check.Bool(t, r.DryRun, true)
+			got = append(got, step{r.Version, r.Direction})
+		}
+		return got
+	}
+
+	t.Run("up", func(t *testing.T) {
+		_, err := p.Up(ctx, WithDryRun())
+		check.HasError(t, err)
+		check.Contains(t, err.Error(), "found 1 missing migrations")
+	})
+	t.Run("up allow missing", func(t *testing.T) {
+		results, err := p.Up(ctx, WithDryRun(), WithAllowMissing())
+		check.NoError(t, err)
+		check.Equal(t, steps(results), []step{{3, DirectionUp}, {5, DirectionUp}})
+		check.Number(t, results[0].StatementCount, 1)
+		check.Bool(t, results[0].UseTx, true)
+		check.Bool(t, results[1].Empty, true)
+		check.Bool(t, results[1].UseTx, false)
+	})
+	t.Run("down to", func(t *testing.T) {
+		results, err := p.DownTo(ctx, 1, WithDryRun())
+		check.NoError(t, err)
+		check.Equal(t, steps(results), []step{{4, DirectionDown}, {2, DirectionDown}})
+		check.Bool(t, results[1].UseTx, false)
+		check.Number(t, results[1].StatementCount, 1)
+	})
+	t.Run("reset", func(t *testing.T) {
+		results, err := p.Reset(ctx, WithDryRun())
+		check.NoError(t, err)
+		check.Equal(t, steps(results), []step{{4, DirectionDown}, {2, DirectionDown}, {1, DirectionDown}})
+	})
+	t.Run("redo", func(t *testing.T) {
+		results, err := p.Redo(ctx, WithDryRun())
+		check.NoError(t, err)
+		check.Equal(t, steps(results), []step{{4, DirectionDown}, {4, DirectionUp}})
+	})
+	t.Run("up by one", func(t *testing.T) {
+		results, err := p.UpByOne(ctx, WithDryRun(), WithAllowMissing())
+		check.NoError(t, err)
+		check.Equal(t, steps(results), []step{{3, DirectionUp}})
+	})
+	t.Run("no versioning", func(t *testing.T) {
+		results, err := p.UpTo(ctx, 2, WithDryRun(), WithNoVersioning())
+		check.NoError(t, err)
+		check.Equal(t, steps(results), []step{{1, DirectionUp}, {2, DirectionUp}})
+		check.Number(t, results[1].StatementCount, 2)
+	})
+}
+
+func TestSQLScript(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	fsys := fstest.MapFS{
+		"00001_a.sql": {Data: []byte("-- +goose Up\nCREATE TABLE a (id int);\n-- +goose Down\nDROP TABLE a;\n")},
+		"00002_b.sql": {Data: []byte("-- +goose NO TRANSACTION\n-- +goose Up\nCREATE INDEX CONCURRENTLY a_idx ON a (id);\n-- +goose Down\nDROP INDEX a_idx;\n")},
+	}
+
+	t.Run("new database", func(t *testing.T) {
+		p := newTestProvider(t, DialectPostgres, fsys, nil)
+		var buf bytes.Buffer
+		_, err := p.Up(ctx, WithSQLScript(&buf))
+		check.NoError(t, err)
+		want := `-- create version table
+BEGIN;
+CREATE TABLE IF NOT EXISTS goose_db_version (
+		id serial NOT NULL,
+		version_id bigint NOT NULL,
+		is_applied boolean NOT NULL,
+		tstamp timestamp NULL default now(),
+		checksum varchar(64) NULL,
+		PRIMARY KEY(id)
+	);
+INSERT INTO goose_db_version (version_id, is_applied, checksum) VALUES (0, true, NULL);
+COMMIT;
+
+-- +goose up 00001_a.sql
+BEGIN;
+CREATE TABLE a (id int);
+INSERT INTO goose_db_version (version_id, is_applied, checksum) VALUES (1, true, '` + checksum(fsys["00001_a.sql"].Data) + `');
+COMMIT;
+
+-- +goose up 00002_b.sql
+CREATE INDEX CONCURRENTLY a_idx ON a (id);
+INSERT INTO goose_db_version (version_id, is_applied, checksum) VALUES (2, true, '` + checksum(fsys["00002_b.sql"].Data) + `');
+
+`
+		check.Equal(t, buf.String(), want)
+	})
+	t.Run("down to", func(t *testing.T) {
+		p := newTestProvider(t, DialectPostgres, fsys, []*dialect.ListMigrationsResult{
+			{VersionID: 2, IsApplied: true},
+			{VersionID: 1, IsApplied: true},
+			{VersionID: 0, IsApplied: true},
+		}, WithTableName("custom_version"))
+		var buf bytes.Buffer
+		_, err := p.DownTo(ctx, 0, WithSQLScript(&buf))
+		check.NoError(t, err)
+		want := `-- +goose down 00002_b.sql
+DROP INDEX a_idx;
+DELETE FROM custom_version WHERE version_id=2;
+
+-- +goose down 00001_a.sql
+BEGIN;
+DROP TABLE a;
+DELETE FROM custom_version WHERE version_id=1;
+COMMIT;
+
+`
+		check.Equal(t, buf.String(), want)
+	})
+	t.Run("go migration", func(t *testing.T) {
+		p := newTestProvider(t, DialectPostgres, fsys, nil, WithGoMigrations(NewGoMigration("00003_c.go", nil, nil)))
+		_, err := p.Up(ctx, WithSQLScript(&bytes.Buffer{}))
+		check.HasError(t, err)
+		check.Contains(t, err.Error(), "cannot be rendered as SQL")
+	})
+}
@@ -0,0 +1,287 @@
+package goose
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"sync"
+	"time"
+
+	"github.com/SergeiSkv/goose/v3/internal/dialect"
+)
+
+// Provider is a goose migration provider. It owns the dialect store, the
+// filesystem used to discover migrations, the version table name, the logger
+// and the Go migration registry. Unlike the package-level functions, which
+// share global state, several providers can be used within the same process
+// against different databases and tables.
+//
+// Commands on a single Provider are serialized because the underlying
+// connection is not safe for concurrent use.
+type Provider struct {
+	mu sync.Mutex
+
+	dialect Dialect
+	db      DB
+	store   dialect.Store
+	fsys    fs.FS
+	dir     string
+
+	tableName  string
+	registered map[int64]*Migration
+
+	logging     loggingOptions
+	lock        lockOptions
+	hooks       []Hooks
+	retryPolicy RetryPolicy
+	templates   templateOptions
+	tracing     tracingOptions
+
+	// versionTableChecked is set once the version table is known to have the
+	// latest schema.
+	versionTableChecked bool
+}
+
+// providerOptions configures a Provider, its settings being grouped by
+// feature.
+type providerOptions struct {
+	dialect   Dialect
+	tableName string
+	dir       string
+
+	logging     loggingOptions
+	registry    registryOptions
+	lock        lockOptions
+	hooks       []Hooks
+	retryPolicy RetryPolicy
+	templates   templateOptions
+	tracing     tracingOptions
+}
+
+// registryOptions configures the Go migrations of a Provider.
+type registryOptions struct {
+	goMigrations  []*Migration
+	disableGlobal bool
+}
+
+// defaultOptions are the options of the package-level functions. SetLocker,
+// SetTracer and the other setters of the package-level functions apply their
+// provider option to it.
+var defaultOptions providerOptions
+
+// ProviderOptionsFunc configures a Provider.
+type ProviderOptionsFunc func(o *providerOptions)
+
+// WithDialect sets the dialect of the package-level functions, of
+// UpTenantsContext and of the fleet commands, instead of the one set with
+// SetDialect, so that commands for databases of different drivers may run
+// concurrently. NewProvider takes its dialect as an argument and ignores it.
+func WithDialect(d Dialect) ProviderOptionsFunc {
+	return func(o *providerOptions) { o.dialect = d }
+}
+
+// WithTableName sets the name of the version table. Defaults to
+// "goose_db_version".
+func WithTableName(name string) ProviderOptionsFunc {
+	return func(o *providerOptions) { o.tableName = name }
+}
+
+// WithDir sets the directory, relative to the provider filesystem, in which
+// migrations are looked up. Defaults to the root of the filesystem.
+func WithDir(dir string) ProviderOptionsFunc {
+	return func(o *providerOptions) { o.dir = dir }
+}
+
+// WithLogger sets the logger used for provider output.
+func WithLogger(l Logger) ProviderOptionsFunc {
+	return func(o *providerOptions) { o.logging.logger = l }
+}
+
+// WithVerbose enables verbose output.
+func WithVerbose(b bool) ProviderOptionsFunc {
+	return func(o *providerOptions) { o.logging.verbose = b }
+}
+
+// WithGoMigrations registers Go migrations with the provider only, see
+// NewGoMigration and NewGoMigrationNoTx.
+func WithGoMigrations(migrations ...*Migration) ProviderOptionsFunc {
+	return func(o *providerOptions) { o.registry.goMigrations = append(o.registry.goMigrations, migrations...) }
+}
+
+// WithDisableGlobalRegistry prevents the provider from picking up Go migrations
+// registered globally via AddMigration and AddMigrationNoTx.
+func WithDisableGlobalRegistry(b bool) ProviderOptionsFunc {
+	return func(o *providerOptions) { o.registry.disableGlobal = b }
+}
+
+// WithLocker sets the Locker used to serialize commands that modify the
+// database across processes. Defaults to a Postgres advisory lock for the
+// postgres dialect, and no locking for other dialects. A nil Locker disables
+// locking.
+func WithLocker(l Locker) ProviderOptionsFunc {
+	return func(o *providerOptions) {
+		o.lock.locker = l
+		o.lock.set = true
+		o.lock.table = false
+	}
+}
+
+// WithTableLocker makes the provider hold its lock as a row of the goose_lock
+// table instead, for dialects without advisory locks. The lock has a lease,
+// renewed while it is held, and is taken over by others once the lease has
+// expired. A lease of 0 uses DefaultLockLease. See also ForceUnlock.
+//
+// The lock needs a connection of its own: db must be a *pgx.Conn, or a *sql.DB
+// adapted with NewSQLDB. ClickHouse is not supported, NewProvider fails.
+func WithTableLocker(lease time.Duration) ProviderOptionsFunc {
+	return func(o *providerOptions) {
+		o.lock.locker = nil
+		o.lock.set = true
+		o.lock.table = true
+		o.lock.lease = lease
+	}
+}
+
+// WithLockTimeout sets how long to wait for the lock before giving up with
+// ErrLockTimeout. Defaults to 0, which waits until the context is done.
+func WithLockTimeout(d time.Duration) ProviderOptionsFunc {
+	return func(o *providerOptions) { o.lock.timeout = d }
+}
+
+// NewProvider returns a new Provider for the given dialect and connection.
+//
+// Migrations are discovered in fsys, which may be nil to use the os
+// filesystem. Go migrations registered globally are copied into the provider
+// at construction time, unless disabled with WithDisableGlobalRegistry.
+func NewProvider(d Dialect, db DB, fsys fs.FS, opts ...ProviderOptionsFunc) (*Provider, error) {
+	if db == nil {
+		return nil, errors.New("db must not be nil")
+	}
+	option := &providerOptions{
+		tableName: defaultTableName,
+		dir:       ".",
+		logging:   loggingOptions{logger: &stdLogger{}},
+	}
+	for _, f := range opts {
+		f(option)
+	}
+	if option.logging.logger == nil {
+		option.logging.logger = NopLogger()
+	}
+	if fsys == nil {
+		fsys = osFS{}
+	}
+	store, err := dialect.NewStore(d, option.tableName)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case option.lock.table && d == DialectClickHouse:
+		return nil, errNoTableLock
+	case option.lock.table:
+		option.lock.locker = newTableLocker(store, option.lock.lease)
+	case !option.lock.set && d == DialectPostgres:
+		option.lock.locker = NewPostgresAdvisoryLocker(option.tableName)
+	}
+	registered := make(map[int64]*Migration)
+	if !option.registry.disableGlobal {
+		for v, m := range registeredGoMigrations {
+			clone := *m
+			registered[v] = &clone
+		}
+	}
+	for _, m := range option.registry.goMigrations {
+		if m == nil {
+			return nil, errors.New("go migration must not be nil")
+		}
+		if _, err := NumericComponent(m.Source); err != nil {
+			return nil, fmt.Errorf("could not parse go migration file %q: %w", m.Source, err)
+		}
+		if existing, ok := registered[m.Version]; ok {
+			return nil, fmt.Errorf("failed to add migration %q: version %d conflicts with %q",
+				m.Source,
+				m.Version,
+				existing.Source,
+			)
+		}
+		clone := *m
+		registered[m.Version] = &clone
+	}
+	return &Provider{
+		dialect:    d,
+		db:         db,
+		store:      store,
+		fsys:       fsys,
+		dir:        option.dir,
+		tableName:  option.tableName,
+		registered: registered,
+
+		logging:     option.logging,
+		lock:        option.lock,
+		hooks:       option.hooks,
+		retryPolicy: option.retryPolicy,
+		templates:   option.templates,
+		tracing:     option.tracing,
+	}, nil
+}
+
+// newGlobalProvider returns a Provider configured from the package-level state
+// set by SetDialect, unless extra holds WithDialect, SetBaseFS, SetTableName,
+// SetVerbose, SetLogger and defaultOptions, followed by extra. It backs the
+// package-level functions.
+func newGlobalProvider(db DB, dir string, extra ...ProviderOptionsFunc) (*Provider, error) {
+	opts := []ProviderOptionsFunc{
+		withDefaultOptions(),
+		WithDir(dir),
+		WithTableName(tableName),
+		WithLogger(log),
+		WithVerbose(verbose),
+	}
+	opts = append(opts, extra...)
+	return NewProvider(globalDialect(opts), db, baseFS, opts...)
+}
+
+// withDefaultOptions replaces the options with defaultOptions.
+func withDefaultOptions() ProviderOptionsFunc {
+	return func(o *providerOptions) {
+		*o = defaultOptions
+		// Hooks added by extra options must not share the array of AddHooks.
+		o.hooks = o.hooks[:len(o.hooks):len(o.hooks)]
+	}
+}
+
+// globalDialect returns the dialect set by WithDialect in opts, or else the
+// one set by SetDialect.
+func globalDialect(opts []ProviderOptionsFunc) Dialect {
+	option := &providerOptions{}
+	for _, f := range opts {
+		f(option)
+	}
+	if option.dialect != "" {
+		return option.dialect
+	}
+	return currentDialect
+}
+
+// TableName returns the name of the version table used by the provider.
+func (p *Provider) TableName() string {
+	return p.tableName
+}
+
+// ListSources returns all the migrations known to the provider, ordered by
+// version.
+func (p *Provider) ListSources() (Migrations, error) {
+	return p.collectMigrations(minVersion, maxVersion)
+}
+
+func (p *Provider) collectMigrations(current, target int64) (Migrations, error) {
+	return collectMigrationsFS(p.fsys, p.dir, current, target, p.registered)
+}
+
+func (p *Provider) applyOptions(opts []OptionsFunc) *options {
+	option := &options{}
+	for _, f := range opts {
+		f(option)
+	}
+	return option
+}
@@ -0,0 +1,211 @@
+package goose
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"io/fs"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/SergeiSkv/goose/v3/internal/check"
+	"github.com/SergeiSkv/goose/v3/internal/dialect"
+	"github.com/SergeiSkv/goose/v3/internal/dialect/dialectquery"
+	"github.com/jackc/pgx/v5"
+)
+
+// newTestProvider returns a provider for tests that must not reach the
+// database, such as dry runs and SQL scripts: it runs on a zero *pgx.Conn and
+// its store serves the given versions from memory, see memoryStore. It logs
+// nothing and ignores the global Go migrations, unless opts say otherwise.
+func newTestProvider(t *testing.T, d Dialect, fsys fs.FS, versions []*dialect.ListMigrationsResult, opts ...ProviderOptionsFunc) *Provider {
+	t.Helper()
+	opts = append([]ProviderOptionsFunc{WithLogger(NopLogger()), WithDisableGlobalRegistry(true)}, opts...)
+	p, err := NewProvider(d, &pgx.Conn{}, fsys, opts...)
+	check.NoError(t, err)
+	p.store = &memoryStore{Store: p.store, versions: versions}
+	return p
+}
+
+// newSQLiteProvider is like newTestProvider, but runs on a new SQLite
+// database, also returned, for the tests running migrations for real.
+func newSQLiteProvider(t *testing.T, fsys fs.FS, opts ...ProviderOptionsFunc) (*Provider, *sql.DB) {
+	t.Helper()
+	db := newSQLiteDB(t)
+	opts = append([]ProviderOptionsFunc{WithLogger(NopLogger()), WithDisableGlobalRegistry(true)}, opts...)
+	p, err := NewProvider(DialectSQLite3, NewSQLDB(db), fsys, opts...)
+	check.NoError(t, err)
+	return p, db
+}
+
+// newSQLiteDB opens a new SQLite database file.
+func newSQLiteDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite", "file:"+filepath.Join(t.TempDir(), "sqlite.db")+"?_pragma=busy_timeout(5000)")
+	check.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+	return db
+}
+
+// memoryStore serves the version table and the repeatable migrations table
+// from memory, or fails as if they did not exist when versions, respectively
+// repeatable, is nil. Other queries go to the embedded Store.
+type memoryStore struct {
+	dialect.Store
+	versions   []*dialect.ListMigrationsResult
+	repeatable []*dialect.ListRepeatableResult
+}
+
+func (s *memoryStore) ListMigrations(context.Context, dialect.Queryer) ([]*dialect.ListMigrationsResult, error) {
+	if s.versions == nil {
+		return nil, errRelationNotExist
+	}
+	return s.versions, nil
+}
+
+func (s *memoryStore) VersionTableGeneration(context.Context, dialect.Queryer) (int, error) {
+	if s.versions == nil {
+		return 0, nil
+	}
+	return dialectquery.LatestGeneration, nil
+}
+
+func (s *memoryStore) ListRepeatable(context.Context, dialect.Queryer) ([]*dialect.ListRepeatableResult, error) {
+	if s.repeatable == nil {
+		return nil, errRelationNotExist
+	}
+	return s.repeatable, nil
+}
+
+var errRelationNotExist = errors.New("relation does not exist")
+
+// count returns the result of a SELECT COUNT(*) query.
+func count(t *testing.T, db *sql.DB, query string, args ...any) int {
+	t.Helper()
+	var n int
+	check.NoError(t, db.QueryRow(query, args...).Scan(&n))
+	return n
+}
+
+func TestNewProvider(t *testing.T) {
+	t.Parallel()
+
+	db := &pgx.Conn{}
+	fsys := fstest.MapFS{
+		"migrations/00001_a.sql": {Data: []byte("-- +goose Up\nSELECT 1;\n")},
+		"migrations/00003_c.sql": {Data: []byte("-- +goose Up\nSELECT 3;\n")},
+	}
+
+	t.Run("invalid", func(t *testing.T) {
+		_, err := NewProvider(DialectPostgres, nil, fsys)
+		check.HasError(t, err)
+		_, err = NewProvider("unknown", db, fsys)
+		check.HasError(t, err)
+		_, err = NewProvider(DialectPostgres, db, fsys, WithTableName(""))
+		check.HasError(t, err)
+	})
+	t.Run("conflicting go migrations", func(t *testing.T) {
+		_, err := NewProvider(DialectPostgres, db, fsys,
+			WithDisableGlobalRegistry(true),
+			WithGoMigrations(
+				NewGoMigration("00002_b.go", nil, nil),
+				NewGoMigrationNoTx("00002_bb.go", nil, nil),
+			),
+		)
+		check.HasError(t, err)
+		check.Contains(t, err.Error(), "version 2 conflicts")
+	})
+	t.Run("sources", func(t *testing.T) {
+		p, err := NewProvider(DialectSQLite3, db, fsys,
+			WithDir("migrations"),
+			WithTableName("custom_version"),
+			WithDisableGlobalRegistry(true),
+			WithGoMigrations(NewGoMigration("00002_b.go", nil, nil)),
+		)
+		check.NoError(t, err)
+		check.Equal(t, p.TableName(), "custom_version")
+		sources, err := p.ListSources()
+		check.NoError(t, err)
+		check.Number(t, len(sources), 3)
+		check.Equal(t, sources[0].Source, "migrations/00001_a.sql")
+		check.Equal(t, sources[1].Source, "00002_b.go")
+		check.Bool(t, sources[1].Registered, true)
+		check.Equal(t, sources[2].Source, "migrations/00003_c.sql")
+	})
+}
+
+func TestProviderResults(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	errBoom := errors.New("boom")
+	var ran []int64
+	record := func(version int64, err error) GoMigrationNoTxContext {
+		return func(context.Context, DB) error {
+			ran = append(ran, version)
+			return err
+		}
+	}
+	p, db := newSQLiteProvider(t, fstest.MapFS{},
+		WithGoMigrations(
+			NewGoMigrationNoTx("00001_a.go", record(1, nil), nil),
+			NewGoMigrationNoTx("00002_b.go", nil, nil),
+			NewGoMigrationNoTx("00003_c.go", record(3, errBoom), nil),
+		),
+	)
+
+	results, err := p.Up(ctx)
+	check.IsError(t, err, errBoom)
+	check.Equal(t, ran, []int64{1, 3})
+	check.Number(t, len(results), 3)
+
+	check.Number(t, results[0].Version, 1)
+	check.Equal(t, results[0].Source, "00001_a.go")
+	check.Equal(t, results[0].Direction, DirectionUp)
+	check.Bool(t, results[0].Empty, false)
+	check.Number(t, results[0].StatementCount, 1)
+	check.NoError(t, results[0].Error)
+
+	check.Bool(t, results[1].Empty, true)
+	check.Number(t, results[1].StatementCount, 0)
+
+	check.IsError(t, results[2].Error, errBoom)
+
+	// Only the migrations that succeeded are recorded.
+	version, err := p.GetDBVersion(ctx)
+	check.NoError(t, err)
+	check.Number(t, version, 2)
+	check.Number(t, count(t, db, "SELECT COUNT(*) FROM goose_db_version WHERE version_id = 3"), 0)
+
+	results, err = p.DownTo(ctx, 0)
+	check.NoError(t, err)
+	check.Number(t, len(results), 2)
+	check.Equal(t, results[0].Direction, DirectionDown)
+	check.Number(t, count(t, db, "SELECT COUNT(*) FROM goose_db_version WHERE version_id > 0"), 0)
+}
+
+func TestGlobalProviderOptions(t *testing.T) {
+	saved := defaultOptions
+	t.Cleanup(func() { defaultOptions = saved })
+
+	noop := func(context.Context, DB) error { return nil }
+	for i := 0; i < 3; i++ {
+		AddHooks(Hooks{})
+	}
+	SetLockTimeout(time.Minute)
+	SetTableLocker(time.Hour)
+	SetStatementSpans(true)
+	p, err := newGlobalProvider(&pgx.Conn{}, ".", WithDialect(DialectMySQL), WithHooks(Hooks{BeforeAll: noop}))
+	check.NoError(t, err)
+	check.Number(t, len(p.hooks), 4)
+	check.Equal(t, p.lock.timeout, time.Minute)
+	check.Bool(t, p.lock.table, true)
+	check.Bool(t, p.tracing.statementSpans, true)
+
+	// Hooks added afterwards reach neither the provider nor its own hooks.
+	AddHooks(Hooks{})
+	check.Number(t, len(p.hooks), 4)
+	check.Bool(t, p.hooks[3].BeforeAll != nil, true)
+}
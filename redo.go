@@ -1,44 +1,66 @@
 package goose
 
 import (
-	"github.com/jackc/pgx/v5"
+	"context"
 )
 
 // Redo rolls back the most recently applied migration, then runs it again.
-func Redo(db *pgx.Conn, dir string, opts ...OptionsFunc) error {
-	option := &options{}
-	for _, f := range opts {
-		f(option)
-	}
-	migrations, err := CollectMigrations(dir, minVersion, maxVersion)
+func Redo(db DB, dir string, opts ...OptionsFunc) error {
+	return RedoContext(context.Background(), db, dir, opts...)
+}
+
+// RedoContext rolls back the most recently applied migration, then runs it again.
+func RedoContext(ctx context.Context, db DB, dir string, opts ...OptionsFunc) error {
+	p, err := newGlobalProvider(db, dir)
 	if err != nil {
 		return err
 	}
+	_, err = p.Redo(ctx, opts...)
+	return err
+}
+
+// Redo rolls back the most recently applied migration, then runs it again. It
+// returns the results of both the down and the up migration.
+func (p *Provider) Redo(ctx context.Context, opts ...OptionsFunc) ([]*MigrationResult, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	option := p.applyOptions(opts)
+	return p.runCommand(ctx, "redo", option, func(ctx context.Context) ([]*MigrationResult, error) {
+		return p.redo(ctx, option)
+	})
+}
+
+func (p *Provider) redo(ctx context.Context, option *options) ([]*MigrationResult, error) {
+	migrations, err := p.collectMigrations(minVersion, maxVersion)
+	if err != nil {
+		return nil, err
+	}
 	var (
 		currentVersion int64
 	)
 	if option.noVersioning {
 		if len(migrations) == 0 {
-			return nil
+			return nil, nil
 		}
 		currentVersion = migrations[len(migrations)-1].Version
 	} else {
-		if currentVersion, err = GetDBVersion(db); err != nil {
-			return err
+		if currentVersion, err = p.getDBVersion(ctx, option); err != nil {
+			return nil, err
 		}
 	}
 
 	current, err := migrations.Current(currentVersion)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	current.noVersioning = option.noVersioning
 
-	if err := current.Down(db); err != nil {
-		return err
-	}
-	if err := current.Up(db); err != nil {
-		return err
+	var results []*MigrationResult
+	for _, direction := range []bool{false, true} {
+		result, err := p.runMigration(ctx, current, direction, option)
+		results = append(results, result)
+		if err != nil {
+			return results, err
+		}
 	}
-	return nil
+	return results, nil
 }
@@ -0,0 +1,324 @@
+package goose
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+
+	"github.com/SergeiSkv/goose/v3/internal/dialect"
+)
+
+// MarkApplied records version as applied in the version table without running
+// its migration.
+func MarkApplied(db DB, dir string, version int64, opts ...OptionsFunc) error {
+	return MarkAppliedContext(context.Background(), db, dir, version, opts...)
+}
+
+// MarkAppliedContext records version as applied in the version table without
+// running its migration.
+func MarkAppliedContext(ctx context.Context, db DB, dir string, version int64, opts ...OptionsFunc) error {
+	p, err := newGlobalProvider(db, dir)
+	if err != nil {
+		return err
+	}
+	return p.MarkApplied(ctx, version, opts...)
+}
+
+// MarkPending records version as rolled back in the version table without
+// running its migration.
+func MarkPending(db DB, dir string, version int64, opts ...OptionsFunc) error {
+	return MarkPendingContext(context.Background(), db, dir, version, opts...)
+}
+
+// MarkPendingContext records version as rolled back in the version table
+// without running its migration.
+func MarkPendingContext(ctx context.Context, db DB, dir string, version int64, opts ...OptionsFunc) error {
+	p, err := newGlobalProvider(db, dir)
+	if err != nil {
+		return err
+	}
+	return p.MarkPending(ctx, version, opts...)
+}
+
+// Repair removes duplicate rows from the version table and reports applied
+// versions that have no migration file.
+func Repair(db DB, dir string, opts ...OptionsFunc) error {
+	return RepairContext(context.Background(), db, dir, opts...)
+}
+
+// RepairContext removes duplicate rows from the version table and reports
+// applied versions that have no migration file.
+func RepairContext(ctx context.Context, db DB, dir string, opts ...OptionsFunc) error {
+	p, err := newGlobalProvider(db, dir)
+	if err != nil {
+		return err
+	}
+	_, err = p.Repair(ctx, opts...)
+	return err
+}
+
+// MarkApplied records version as applied in the version table without running
+// its migration, for example after a migration without a transaction failed
+// half-way and was completed by hand. version must be the version of a
+// migration newer than the current version: the latest row of the version
+// table holds the current version, so recording an older one would move the
+// database back to it. With WithAllowMissing, an older version is recorded
+// anyway, as up applies missing migrations, and becomes the current version.
+// With WithDryRun, the change is only reported.
+func (p *Provider) MarkApplied(ctx context.Context, version int64, opts ...OptionsFunc) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	option := p.applyOptions(opts)
+	return p.traceCommand(ctx, "mark-applied", option, func(ctx context.Context) error {
+		return p.withLock(ctx, option, func(ctx context.Context) error {
+			return p.markApplied(ctx, version, option)
+		})
+	})
+}
+
+func (p *Provider) markApplied(ctx context.Context, version int64, option *options) error {
+	migrations, err := p.collectMigrations(minVersion, maxVersion)
+	if err != nil {
+		return fmt.Errorf("failed to collect migrations: %w", err)
+	}
+	m, err := migrations.Current(version)
+	if err != nil {
+		return fmt.Errorf("no migration %d", version)
+	}
+	current, statuses, err := p.versionTableStatus(ctx, option)
+	if err != nil {
+		return err
+	}
+	if statuses[version] {
+		p.log(ctx, LevelInfo, "migration already applied", "goose: %[2]s is already applied\n",
+			Field{Key: "version", Value: version}, Field{Key: "file", Value: filepath.Base(m.Source)})
+		return nil
+	}
+	if version < current && !option.allowMissing {
+		return fmt.Errorf("cannot mark version %d as applied: it is older than the current version %d, which it would replace, use WithAllowMissing (-allow-missing) to record it anyway", version, current)
+	}
+	var sum string
+	if migrationType(m) == TypeSQL {
+		data, err := fs.ReadFile(p.fsys, m.Source)
+		if err != nil {
+			return fmt.Errorf("failed to read SQL migration file %q: %w", filepath.Base(m.Source), err)
+		}
+		sum = checksum(data)
+	}
+	if option.dryRun {
+		p.log(ctx, LevelInfo, "mark-applied planned", "PLAN mark-applied %[2]s\n",
+			Field{Key: "version", Value: version}, Field{Key: "file", Value: filepath.Base(m.Source)})
+		option.dryRunVersions = append(
+			[]*dialect.ListMigrationsResult{{VersionID: version, IsApplied: true, Checksum: sum}},
+			option.dryRunVersions...,
+		)
+		return p.writeRepairScript(option, fmt.Sprintf("-- +goose mark-applied %d", version),
+			p.store.InsertVersionSQL(version, sum))
+	}
+	if err := p.store.InsertVersionNoTx(ctx, p.db, version, sum); err != nil {
+		return fmt.Errorf("failed to insert version %d: %w", version, err)
+	}
+	p.log(ctx, LevelInfo, "migration marked as applied", "goose: marked %[2]s as applied\n",
+		Field{Key: "version", Value: version}, Field{Key: "file", Value: filepath.Base(m.Source)})
+	return nil
+}
+
+// MarkPending records version as rolled back in the version table without
+// running its migration. Like down, it deletes every row of version, so its
+// history in the version table is lost. Unlike MarkApplied, version does not
+// need a migration file, so that rows of deleted migrations can be removed.
+// With WithDryRun, the change is only reported.
+func (p *Provider) MarkPending(ctx context.Context, version int64, opts ...OptionsFunc) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	option := p.applyOptions(opts)
+	return p.traceCommand(ctx, "mark-pending", option, func(ctx context.Context) error {
+		return p.withLock(ctx, option, func(ctx context.Context) error {
+			return p.markPending(ctx, version, option)
+		})
+	})
+}
+
+func (p *Provider) markPending(ctx context.Context, version int64, option *options) error {
+	name := fmt.Sprintf("version %d", version)
+	migrations, err := p.collectMigrations(minVersion, maxVersion)
+	if err != nil {
+		return fmt.Errorf("failed to collect migrations: %w", err)
+	}
+	if m, err := migrations.Current(version); err == nil {
+		name = filepath.Base(m.Source)
+	}
+	_, statuses, err := p.versionTableStatus(ctx, option)
+	if err != nil {
+		return err
+	}
+	if !statuses[version] {
+		p.log(ctx, LevelInfo, "migration not applied", "goose: %[2]s is not applied\n",
+			Field{Key: "version", Value: version}, Field{Key: "migration", Value: name})
+		return nil
+	}
+	if option.dryRun {
+		p.log(ctx, LevelInfo, "mark-pending planned", "PLAN mark-pending %[2]s\n", Field{Key: "version", Value: version}, Field{Key: "migration", Value: name})
+		option.dryRunVersions = withoutVersion(option.dryRunVersions, version)
+		return p.writeRepairScript(option, fmt.Sprintf("-- +goose mark-pending %d", version),
+			p.store.DeleteVersionSQL(version))
+	}
+	if err := p.store.DeleteVersionNoTx(ctx, p.db, version); err != nil {
+		return fmt.Errorf("failed to delete version %d: %w", version, err)
+	}
+	p.log(ctx, LevelInfo, "migration marked as pending", "goose: marked %[2]s as pending\n",
+		Field{Key: "version", Value: version}, Field{Key: "migration", Value: name})
+	return nil
+}
+
+// RepairResult reports the changes made by Repair.
+type RepairResult struct {
+	// Deduplicated are the versions whose duplicate rows were collapsed into
+	// one.
+	Deduplicated []int64
+	// Orphaned are the applied versions without a migration file. They are
+	// reported only, see MarkPending.
+	Orphaned []int64
+}
+
+// Repair collapses the rows of each version recorded several times in the
+// version table into its latest row, and reports the applied versions that
+// have no migration file. With WithDryRun, the changes are only reported.
+func (p *Provider) Repair(ctx context.Context, opts ...OptionsFunc) (*RepairResult, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	option := p.applyOptions(opts)
+	var result *RepairResult
+	err := p.traceCommand(ctx, "repair", option, func(ctx context.Context) error {
+		return p.withLock(ctx, option, func(ctx context.Context) (err error) {
+			result, err = p.repair(ctx, option)
+			return err
+		})
+	})
+	return result, err
+}
+
+func (p *Provider) repair(ctx context.Context, option *options) (*RepairResult, error) {
+	migrations, err := p.collectMigrations(minVersion, maxVersion)
+	if err != nil {
+		return nil, fmt.Errorf("failed to collect migrations: %w", err)
+	}
+	if _, _, err := p.versionTableStatus(ctx, option); err != nil {
+		return nil, err
+	}
+	rows, err := p.listMigrations(ctx, option)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list version table: %w", err)
+	}
+
+	// Rows are ordered by descending id, the first row of a version holds its
+	// latest state.
+	latest := make(map[int64]*dialect.ListMigrationsResult)
+	counts := make(map[int64]int)
+	var versions []int64
+	for _, row := range rows {
+		if _, ok := latest[row.VersionID]; !ok {
+			latest[row.VersionID] = row
+			versions = append(versions, row.VersionID)
+		}
+		counts[row.VersionID]++
+	}
+
+	result := &RepairResult{}
+	for i := len(versions) - 1; i >= 0; i-- {
+		version := versions[i]
+		row := latest[version]
+		if counts[version] > 1 {
+			if err := p.deduplicateVersion(ctx, row, counts[version], option); err != nil {
+				return result, err
+			}
+			result.Deduplicated = append(result.Deduplicated, version)
+		}
+		if version == 0 || !row.IsApplied {
+			continue
+		}
+		if _, err := migrations.Current(version); err != nil {
+			p.log(ctx, LevelWarn, "applied version without migration file", "goose: WARNING version %d is applied but has no migration file\n",
+				Field{Key: "version", Value: version})
+			result.Orphaned = append(result.Orphaned, version)
+		}
+	}
+	if len(result.Deduplicated) == 0 && len(result.Orphaned) == 0 {
+		p.log(ctx, LevelInfo, "version table consistent", "goose: version table is consistent\n")
+	}
+	return result, nil
+}
+
+// deduplicateVersion deletes the rows of a version but its latest one, row.
+// Row keeps its id, and so its place in the version table: a new row would
+// make its version the current one.
+func (p *Provider) deduplicateVersion(ctx context.Context, row *dialect.ListMigrationsResult, count int, option *options) error {
+	version := row.VersionID
+	if option.dryRun {
+		p.log(ctx, LevelInfo, "repair planned", "PLAN repair version %d (%d rows)\n",
+			Field{Key: "version", Value: version}, Field{Key: "rows", Value: count})
+		kept := make([]*dialect.ListMigrationsResult, 0, len(option.dryRunVersions))
+		for _, r := range option.dryRunVersions {
+			if r.VersionID != version || r == row {
+				kept = append(kept, r)
+			}
+		}
+		option.dryRunVersions = kept
+		return p.writeRepairScript(option, fmt.Sprintf("-- +goose repair %d", version), p.store.DeleteVersionDuplicatesSQL(row))
+	}
+
+	tx, err := p.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	if err := p.store.DeleteVersionDuplicates(ctx, tx, row); err != nil {
+		_ = rollback(tx)
+		return fmt.Errorf("failed to delete duplicates of version %d: %w", version, err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	p.log(ctx, LevelInfo, "duplicate rows removed", "goose: removed %[2]d duplicate rows of version %[1]d\n",
+		Field{Key: "version", Value: version}, Field{Key: "rows", Value: count - 1})
+	return nil
+}
+
+// versionTableStatus ensures the version table exists and returns the current
+// version and whether each version is applied.
+func (p *Provider) versionTableStatus(ctx context.Context, option *options) (int64, map[int64]bool, error) {
+	if option.noVersioning {
+		return 0, nil, fmt.Errorf("the version table cannot be changed without versioning")
+	}
+	current, err := p.ensureDBVersion(ctx, option)
+	if err != nil && !errors.Is(err, ErrNoNextVersion) {
+		return 0, nil, fmt.Errorf("failed to ensure DB version: %w", err)
+	}
+	statuses, err := p.dbMigrationsStatus(ctx, option)
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to get status of migrations: %w", err)
+	}
+	return current, statuses, nil
+}
+
+// writeRepairScript renders a version table change, if the command is rendered
+// as a SQL script.
+func (p *Provider) writeRepairScript(option *options, header string, statements ...string) error {
+	if option.script == nil {
+		return nil
+	}
+	return p.writeScript(option, header, statements, true)
+}
+
+// withoutVersion returns the rows of the version table except those of
+// version.
+func withoutVersion(rows []*dialect.ListMigrationsResult, version int64) []*dialect.ListMigrationsResult {
+	kept := make([]*dialect.ListMigrationsResult, 0, len(rows))
+	for _, row := range rows {
+		if row.VersionID != version {
+			kept = append(kept, row)
+		}
+	}
+	return kept
+}
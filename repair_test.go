@@ -0,0 +1,144 @@
+package goose
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"testing/fstest"
+
+	"github.com/SergeiSkv/goose/v3/internal/check"
+	"github.com/SergeiSkv/goose/v3/internal/dialect"
+)
+
+func TestRepairCommands(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	fsys := fstest.MapFS{
+		"00001_a.sql": {Data: []byte("-- +goose Up\nCREATE TABLE a (id int);\n")},
+		"00002_b.sql": {Data: []byte("-- +goose Up\nCREATE TABLE b (id int);\n")},
+		"00003_c.sql": {Data: []byte("-- +goose Up\nCREATE TABLE c (id int);\n")},
+	}
+	newProvider := func(t *testing.T, versions []*dialect.ListMigrationsResult) *Provider {
+		t.Helper()
+		return newTestProvider(t, DialectPostgres, fsys, versions)
+	}
+	applied := func(versions ...int64) []*dialect.ListMigrationsResult {
+		var rows []*dialect.ListMigrationsResult
+		for _, v := range versions {
+			rows = append(rows, &dialect.ListMigrationsResult{VersionID: v, IsApplied: true, Checksum: "sum"})
+		}
+		return rows
+	}
+
+	t.Run("mark applied", func(t *testing.T) {
+		p := newProvider(t, applied(1, 0))
+		var buf bytes.Buffer
+		check.NoError(t, p.MarkApplied(ctx, 2, WithSQLScript(&buf)))
+		check.Equal(t, buf.String(), `-- +goose mark-applied 2
+BEGIN;
+INSERT INTO goose_db_version (version_id, is_applied, checksum) VALUES (2, true, '`+checksum(fsys["00002_b.sql"].Data)+`');
+COMMIT;
+
+`)
+	})
+	t.Run("mark applied already applied", func(t *testing.T) {
+		p := newProvider(t, applied(1, 0))
+		var buf bytes.Buffer
+		check.NoError(t, p.MarkApplied(ctx, 1, WithSQLScript(&buf)))
+		check.Equal(t, buf.String(), "")
+	})
+	t.Run("mark applied older version", func(t *testing.T) {
+		p := newProvider(t, applied(3, 1, 0))
+		err := p.MarkApplied(ctx, 2, WithSQLScript(&bytes.Buffer{}))
+		check.HasError(t, err)
+		check.Contains(t, err.Error(), "older than the current version 3")
+	})
+	t.Run("mark applied older version allow missing", func(t *testing.T) {
+		p := newProvider(t, applied(3, 1, 0))
+		var buf bytes.Buffer
+		check.NoError(t, p.MarkApplied(ctx, 2, WithSQLScript(&buf), WithAllowMissing()))
+		check.Contains(t, buf.String(), "VALUES (2, true, ")
+	})
+	t.Run("mark applied unknown version", func(t *testing.T) {
+		p := newProvider(t, applied(1, 0))
+		err := p.MarkApplied(ctx, 9, WithDryRun())
+		check.HasError(t, err)
+		check.Contains(t, err.Error(), "no migration 9")
+	})
+	t.Run("mark pending", func(t *testing.T) {
+		// Version 9 has no migration file.
+		p := newProvider(t, applied(9, 1, 0))
+		var buf bytes.Buffer
+		check.NoError(t, p.MarkPending(ctx, 9, WithSQLScript(&buf)))
+		check.NoError(t, p.MarkPending(ctx, 2, WithSQLScript(&buf)))
+		check.Equal(t, buf.String(), `-- +goose mark-pending 9
+BEGIN;
+DELETE FROM goose_db_version WHERE version_id=9;
+COMMIT;
+
+`)
+	})
+	t.Run("repair", func(t *testing.T) {
+		p := newProvider(t, []*dialect.ListMigrationsResult{
+			{ID: 7, VersionID: 9, IsApplied: true},
+			{ID: 6, VersionID: 3, IsApplied: false},
+			{ID: 5, VersionID: 2, IsApplied: true, Checksum: "new"},
+			{ID: 4, VersionID: 3, IsApplied: true},
+			{ID: 3, VersionID: 2, IsApplied: true, Checksum: "old"},
+			{ID: 2, VersionID: 1, IsApplied: true},
+			{ID: 1, VersionID: 0, IsApplied: true},
+		})
+		var buf bytes.Buffer
+		result, err := p.Repair(ctx, WithSQLScript(&buf))
+		check.NoError(t, err)
+		check.Equal(t, result.Deduplicated, []int64{2, 3})
+		check.Equal(t, result.Orphaned, []int64{9})
+		check.Equal(t, buf.String(), `-- +goose repair 2
+BEGIN;
+DELETE FROM goose_db_version WHERE version_id=2 AND id<5;
+COMMIT;
+
+-- +goose repair 3
+BEGIN;
+DELETE FROM goose_db_version WHERE version_id=3 AND id<6;
+COMMIT;
+
+`)
+
+		// The same rows in a database: the latest row of each version stays,
+		// and so does the current version.
+		p, db := newSQLiteProvider(t, fsys)
+		_, err = p.EnsureDBVersion(ctx)
+		check.NoError(t, err)
+		for _, row := range []dialect.ListMigrationsResult{
+			{VersionID: 1, IsApplied: true},
+			{VersionID: 2, IsApplied: true, Checksum: "old"},
+			{VersionID: 3, IsApplied: true},
+			{VersionID: 2, IsApplied: true, Checksum: "new"},
+			{VersionID: 3, IsApplied: false},
+			{VersionID: 9, IsApplied: true},
+		} {
+			_, err := db.Exec("INSERT INTO goose_db_version (version_id, is_applied, checksum) VALUES (?, ?, ?)", row.VersionID, row.IsApplied, row.Checksum)
+			check.NoError(t, err)
+		}
+		result, err = p.Repair(ctx)
+		check.NoError(t, err)
+		check.Equal(t, result.Deduplicated, []int64{2, 3})
+		check.Equal(t, result.Orphaned, []int64{9})
+		version, err := p.GetDBVersion(ctx)
+		check.NoError(t, err)
+		check.Number(t, version, 9)
+		var versions string
+		check.NoError(t, db.QueryRow("SELECT group_concat(version_id) FROM (SELECT version_id FROM goose_db_version ORDER BY id)").Scan(&versions))
+		check.Equal(t, versions, "0,1,2,3,9")
+		check.Number(t, count(t, db, "SELECT COUNT(*) FROM goose_db_version WHERE version_id = 2 AND checksum = 'new'"), 1)
+	})
+	t.Run("repair consistent", func(t *testing.T) {
+		p := newProvider(t, applied(2, 1, 0))
+		result, err := p.Repair(ctx, WithDryRun())
+		check.NoError(t, err)
+		check.Number(t, len(result.Deduplicated), 0)
+		check.Number(t, len(result.Orphaned), 0)
+	})
+}
@@ -0,0 +1,148 @@
+package goose
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/SergeiSkv/goose/v3/internal/sqlparser"
+)
+
+// repeatablePrefix marks a SQL migration file as repeatable, as does the
+// '-- +goose Repeatable' annotation.
+const repeatablePrefix = "R_"
+
+// CollectRepeatableMigrations returns the repeatable SQL migrations in the
+// migrations folder, ordered by file name.
+//
+// Repeatable migrations have no version. They are named with an R_ prefix,
+// such as R_views.sql, or annotated with '-- +goose Repeatable', and are
+// tracked by name and checksum. Up runs them after all versioned migrations,
+// whenever their content has changed since they were last applied.
+func CollectRepeatableMigrations(dirpath string) (Migrations, error) {
+	return collectRepeatableFS(baseFS, dirpath)
+}
+
+func collectRepeatableFS(fsys fs.FS, dirpath string) (Migrations, error) {
+	files, err := globSQLMigrations(fsys, dirpath)
+	if err != nil {
+		return nil, err
+	}
+	var migrations Migrations
+	for _, file := range files {
+		_, versionErr := NumericComponent(file)
+		repeatable, err := isRepeatable(fsys, file)
+		if err != nil {
+			return nil, err
+		}
+		if versionErr == nil {
+			// A versioned file cannot also be repeatable: reject it rather
+			// than running it once as a versioned migration.
+			if repeatable {
+				return nil, withClass(ErrParse, fmt.Errorf("SQL migration file %q has a version and a +goose Repeatable annotation, remove one of them", filepath.Base(file)))
+			}
+			continue
+		}
+		if repeatable {
+			migrations = append(migrations, &Migration{
+				Next:       -1,
+				Previous:   -1,
+				Source:     file,
+				Repeatable: true,
+			})
+		}
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return filepath.Base(migrations[i].Source) < filepath.Base(migrations[j].Source)
+	})
+	return migrations, nil
+}
+
+// isRepeatable reports whether the SQL migration file without a version is a
+// repeatable migration.
+func isRepeatable(fsys fs.FS, file string) (bool, error) {
+	if strings.HasPrefix(filepath.Base(file), repeatablePrefix) {
+		return true, nil
+	}
+	f, err := fsys.Open(file)
+	if err != nil {
+		return false, fmt.Errorf("failed to open SQL migration file: %w", err)
+	}
+	defer f.Close()
+	repeatable, err := sqlparser.IsRepeatable(f)
+	if err != nil {
+		return false, withClass(ErrParse, fmt.Errorf("failed to parse SQL migration file %q: %w", filepath.Base(file), err))
+	}
+	return repeatable, nil
+}
+
+func (p *Provider) collectRepeatable() (Migrations, error) {
+	return collectRepeatableFS(p.fsys, p.dir)
+}
+
+// upRepeatable runs the repeatable migrations whose checksum differs from the
+// one recorded when they were last applied. With WithNoVersioning, all of
+// them are run.
+func (p *Provider) upRepeatable(ctx context.Context, migrations Migrations, option *options) ([]*MigrationResult, error) {
+	if len(migrations) == 0 {
+		return nil, nil
+	}
+	applied, err := p.appliedRepeatable(ctx, option)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []*MigrationResult
+	for _, m := range migrations {
+		data, err := fs.ReadFile(p.fsys, m.Source)
+		if err != nil {
+			return results, fmt.Errorf("failed to read repeatable migration %q: %w", filepath.Base(m.Source), err)
+		}
+		if sum, ok := applied[filepath.Base(m.Source)]; ok && sum == checksum(data) {
+			continue
+		}
+		result, err := p.runMigration(ctx, m, true, option)
+		results = append(results, result)
+		if err != nil {
+			return results, err
+		}
+	}
+	return results, nil
+}
+
+// appliedRepeatable returns the checksums of the applied repeatable
+// migrations, keyed by file name. The repeatable migrations table is created
+// if needed, unless for dry runs.
+func (p *Provider) appliedRepeatable(ctx context.Context, option *options) (map[string]string, error) {
+	applied := make(map[string]string)
+	if option.noVersioning {
+		return applied, nil
+	}
+	if option.dryRun {
+		if option.script != nil {
+			if err := p.writeScript(option, "-- create repeatable migrations table", []string{
+				p.store.CreateRepeatableTableSQL(),
+			}, false); err != nil {
+				return nil, err
+			}
+		}
+	} else if err := p.store.CreateRepeatableTable(ctx, p.db); err != nil {
+		return nil, fmt.Errorf("failed to create repeatable migrations table: %w", err)
+	}
+	rows, err := p.store.ListRepeatable(ctx, p.db)
+	if err != nil {
+		if option.dryRun && ctx.Err() == nil {
+			// The table does not exist yet, every repeatable migration is
+			// pending.
+			return applied, nil
+		}
+		return nil, fmt.Errorf("failed to list repeatable migrations: %w", err)
+	}
+	for _, r := range rows {
+		applied[r.Name] = r.Checksum
+	}
+	return applied, nil
+}
@@ -0,0 +1,111 @@
+package goose
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/SergeiSkv/goose/v3/internal/check"
+	"github.com/SergeiSkv/goose/v3/internal/dialect"
+)
+
+func TestRepeatableMigrations(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	fsys := fstest.MapFS{
+		"00001_a.sql":        {Data: []byte("-- +goose Up\nCREATE TABLE a (id int);\n")},
+		"00002_b.sql":        {Data: []byte("-- +goose Up\nALTER TABLE a ADD b int;\n")},
+		"R_views.sql":        {Data: []byte("-- +goose Up\nCREATE OR REPLACE VIEW v AS SELECT * FROM a;\n")},
+		"functions.sql":      {Data: []byte("-- +goose Repeatable\n-- +goose Up\nCREATE OR REPLACE FUNCTION f() RETURNS int AS 'SELECT 1' LANGUAGE sql;\n")},
+		"R_unchanged.sql":    {Data: []byte("-- +goose Up\nSELECT 1;\n")},
+		"R_no_tx_grants.sql": {Data: []byte("-- +goose NO TRANSACTION\n-- +goose Up\nGRANT SELECT ON v TO reader;\n")},
+	}
+	newProvider := func(t *testing.T, versions []*dialect.ListMigrationsResult, repeatable []*dialect.ListRepeatableResult) *Provider {
+		t.Helper()
+		p := newTestProvider(t, DialectPostgres, fsys, versions)
+		p.store.(*memoryStore).repeatable = repeatable
+		return p
+	}
+	applied := []*dialect.ListRepeatableResult{
+		{Name: "R_unchanged.sql", Checksum: checksum(fsys["R_unchanged.sql"].Data)},
+		{Name: "R_views.sql", Checksum: "outdated"},
+	}
+	sources := func(results []*MigrationResult) []string {
+		var got []string
+		for _, r := range results {
+			got = append(got, r.Source)
+		}
+		return got
+	}
+
+	t.Run("collect", func(t *testing.T) {
+		p := newProvider(t, nil, nil)
+		migrations, err := p.ListSources()
+		check.NoError(t, err)
+		check.Number(t, len(migrations), 2)
+		repeatable, err := p.collectRepeatable()
+		check.NoError(t, err)
+		check.Equal(t, repeatable.String(), "R_no_tx_grants.sql\nR_unchanged.sql\nR_views.sql\nfunctions.sql\n")
+		for _, m := range repeatable {
+			check.Bool(t, m.Repeatable, true)
+			check.Number(t, m.Version, 0)
+		}
+	})
+	t.Run("up runs changed after versioned", func(t *testing.T) {
+		p := newProvider(t, []*dialect.ListMigrationsResult{{VersionID: 0, IsApplied: true}}, applied)
+		results, err := p.Up(ctx, WithDryRun())
+		check.NoError(t, err)
+		check.Equal(t, sources(results), []string{
+			"00001_a.sql", "00002_b.sql", "R_no_tx_grants.sql", "R_views.sql", "functions.sql",
+		})
+		check.Bool(t, results[2].UseTx, false)
+	})
+	t.Run("new table", func(t *testing.T) {
+		p := newProvider(t, []*dialect.ListMigrationsResult{
+			{VersionID: 2, IsApplied: true},
+			{VersionID: 1, IsApplied: true},
+			{VersionID: 0, IsApplied: true},
+		}, nil)
+		results, err := p.Up(ctx, WithDryRun())
+		check.NoError(t, err)
+		check.Number(t, len(results), 4)
+	})
+	t.Run("up to", func(t *testing.T) {
+		p := newProvider(t, []*dialect.ListMigrationsResult{{VersionID: 0, IsApplied: true}}, nil)
+		results, err := p.UpTo(ctx, 2, WithDryRun())
+		check.NoError(t, err)
+		check.Equal(t, sources(results), []string{"00001_a.sql", "00002_b.sql"})
+	})
+	t.Run("sql script", func(t *testing.T) {
+		p := newProvider(t, []*dialect.ListMigrationsResult{
+			{VersionID: 2, IsApplied: true},
+			{VersionID: 1, IsApplied: true},
+			{VersionID: 0, IsApplied: true},
+		}, applied)
+		var buf bytes.Buffer
+		_, err := p.Up(ctx, WithSQLScript(&buf))
+		check.NoError(t, err)
+		script := buf.String()
+		check.Contains(t, script, "-- create repeatable migrations table\nCREATE TABLE IF NOT EXISTS goose_db_version_repeatable")
+		check.Contains(t, script, `-- +goose up R_views.sql
+BEGIN;
+CREATE OR REPLACE VIEW v AS SELECT * FROM a;
+DELETE FROM goose_db_version_repeatable WHERE name='R_views.sql';
+INSERT INTO goose_db_version_repeatable (name, checksum) VALUES ('R_views.sql', '`+checksum(fsys["R_views.sql"].Data)+`');
+COMMIT;`)
+		check.Bool(t, strings.Contains(script, "R_unchanged.sql"), false)
+	})
+	t.Run("versioned and annotated", func(t *testing.T) {
+		p := newTestProvider(t, DialectPostgres, fstest.MapFS{
+			"00001_a.sql": {Data: []byte("-- +goose Up\nCREATE TABLE a (id int);\n")},
+			"00002_b.sql": {Data: []byte("-- +goose Repeatable\n-- +goose Up\nCREATE OR REPLACE VIEW v AS SELECT * FROM a;\n")},
+		}, []*dialect.ListMigrationsResult{{VersionID: 0, IsApplied: true}})
+		results, err := p.Up(ctx, WithDryRun())
+		check.IsError(t, err, ErrParse)
+		check.Contains(t, err.Error(), `"00002_b.sql" has a version and a +goose Repeatable annotation`)
+		check.Number(t, len(results), 0)
+	})
+}
@@ -4,45 +4,66 @@ import (
 	"context"
 	"fmt"
 	"sort"
-
-	"github.com/jackc/pgx/v5"
 )
 
 // Reset rolls back all migrations
-func Reset(db *pgx.Conn, dir string, opts ...OptionsFunc) error {
-	ctx := context.Background()
-	option := &options{}
-	for _, f := range opts {
-		f(option)
+func Reset(db DB, dir string, opts ...OptionsFunc) error {
+	return ResetContext(context.Background(), db, dir, opts...)
+}
+
+// ResetContext rolls back all migrations
+func ResetContext(ctx context.Context, db DB, dir string, opts ...OptionsFunc) error {
+	p, err := newGlobalProvider(db, dir)
+	if err != nil {
+		return err
 	}
-	migrations, err := CollectMigrations(dir, minVersion, maxVersion)
+	_, err = p.Reset(ctx, opts...)
+	return err
+}
+
+// Reset rolls back all migrations and returns the result of each migration
+// that was rolled back.
+func (p *Provider) Reset(ctx context.Context, opts ...OptionsFunc) ([]*MigrationResult, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	option := p.applyOptions(opts)
+	return p.runCommand(ctx, "reset", option, func(ctx context.Context) ([]*MigrationResult, error) {
+		return p.reset(ctx, option)
+	})
+}
+
+func (p *Provider) reset(ctx context.Context, option *options) ([]*MigrationResult, error) {
+	migrations, err := p.collectMigrations(minVersion, maxVersion)
 	if err != nil {
-		return fmt.Errorf("failed to collect migrations: %w", err)
+		return nil, fmt.Errorf("failed to collect migrations: %w", err)
 	}
 	if option.noVersioning {
-		return DownTo(db, dir, minVersion, opts...)
+		return p.downTo(ctx, minVersion, option)
 	}
 
-	statuses, err := dbMigrationsStatus(ctx, db)
+	statuses, err := p.dbMigrationsStatus(ctx, option)
 	if err != nil {
-		return fmt.Errorf("failed to get status of migrations: %w", err)
+		return nil, fmt.Errorf("failed to get status of migrations: %w", err)
 	}
 	sort.Sort(sort.Reverse(migrations))
 
+	var results []*MigrationResult
 	for _, migration := range migrations {
 		if !statuses[migration.Version] {
 			continue
 		}
-		if err = migration.Down(db); err != nil {
-			return fmt.Errorf("failed to db-down: %w", err)
+		result, err := p.runMigration(ctx, migration, false, option)
+		results = append(results, result)
+		if err != nil {
+			return results, fmt.Errorf("failed to db-down: %w", err)
 		}
 	}
 
-	return nil
+	return results, nil
 }
 
-func dbMigrationsStatus(ctx context.Context, db *pgx.Conn) (map[int64]bool, error) {
-	dbMigrations, err := store.ListMigrations(ctx, db)
+func (p *Provider) dbMigrationsStatus(ctx context.Context, option *options) (map[int64]bool, error) {
+	dbMigrations, err := p.listMigrations(ctx, option)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,106 @@
+package goose
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// RetryPolicy retries migrations that run within a transaction when they fail
+// with a transient error, such as a serialization failure or a deadlock.
+// Retrying is safe because a transactional migration and its version table
+// update commit atomically: a failed attempt leaves nothing behind.
+//
+// Migrations that run outside a transaction are never retried.
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of attempts, including the first
+	// one. Values below 2 disable retries.
+	MaxAttempts int
+	// Backoff returns how long to wait before the given retry, starting at 1.
+	// Nil uses ExponentialBackoff(100*time.Millisecond, 5*time.Second).
+	Backoff func(retry int) time.Duration
+	// Retryable reports whether the error of a failed attempt is transient.
+	// Nil uses RetryableSQLStates(DefaultRetryableSQLStates...).
+	Retryable func(err error) bool
+}
+
+// DefaultRetryableSQLStates are the SQLSTATE codes retried unless configured
+// otherwise: serialization_failure, deadlock_detected and lock_not_available,
+// raised when lock_timeout expires.
+var DefaultRetryableSQLStates = []string{"40001", "40P01", "55P03"}
+
+// RetryableSQLStates returns a RetryPolicy classifier that retries errors
+// wrapping a *pgconn.PgError with one of the given SQLSTATE codes.
+func RetryableSQLStates(codes ...string) func(err error) bool {
+	retryable := make(map[string]bool, len(codes))
+	for _, code := range codes {
+		retryable[code] = true
+	}
+	return func(err error) bool {
+		var pgErr *pgconn.PgError
+		return errors.As(err, &pgErr) && retryable[pgErr.Code]
+	}
+}
+
+// ExponentialBackoff returns a RetryPolicy backoff that doubles from base on
+// every retry, up to max.
+func ExponentialBackoff(base, max time.Duration) func(retry int) time.Duration {
+	return func(retry int) time.Duration {
+		d := base
+		for i := 1; i < retry && d < max; i++ {
+			d *= 2
+		}
+		if d > max {
+			d = max
+		}
+		return d
+	}
+}
+
+// SetRetryPolicy sets the RetryPolicy used by the package-level functions.
+func SetRetryPolicy(policy RetryPolicy) {
+	WithRetryPolicy(policy)(&defaultOptions)
+}
+
+// WithRetryPolicy retries transactional migrations that fail with a transient
+// error, see RetryPolicy. By default migrations are not retried.
+func WithRetryPolicy(policy RetryPolicy) ProviderOptionsFunc {
+	return func(o *providerOptions) { o.retryPolicy = policy }
+}
+
+// withRetry runs fn, an attempt at running migration m, until it succeeds,
+// fails with an error that is not retryable or runs out of attempts.
+func (p *Provider) withRetry(ctx context.Context, m *Migration, fn func() error) error {
+	policy := p.retryPolicy
+	backoff := policy.Backoff
+	if backoff == nil {
+		backoff = ExponentialBackoff(100*time.Millisecond, 5*time.Second)
+	}
+	retryable := policy.Retryable
+	if retryable == nil {
+		retryable = RetryableSQLStates(DefaultRetryableSQLStates...)
+	}
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil || attempt >= policy.MaxAttempts || ctx.Err() != nil || !retryable(err) {
+			return err
+		}
+		wait := backoff(attempt)
+		p.log(ctx, LevelWarn, "migration attempt failed, retrying", "goose: attempt %[3]d/%[4]d of %[2]s failed, retrying in %[5]s: %[6]v\n",
+			Field{Key: "version", Value: m.Version},
+			Field{Key: "file", Value: filepath.Base(m.Source)},
+			Field{Key: "attempt", Value: attempt},
+			Field{Key: "max_attempts", Value: policy.MaxAttempts},
+			Field{Key: "backoff", Value: wait},
+			Field{Key: "error", Value: err},
+		)
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(wait):
+		}
+	}
+}
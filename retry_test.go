@@ -0,0 +1,201 @@
+package goose
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/SergeiSkv/goose/v3/internal/check"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+func TestRetryPolicy(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	serializationFailure := fmt.Errorf("failed to execute SQL query: %w", &pgconn.PgError{Code: "40001"})
+	uniqueViolation := &pgconn.PgError{Code: "23505"}
+	m := &Migration{Version: 1, Source: "00001_a.sql"}
+	newProvider := func(t *testing.T, policy RetryPolicy) *Provider {
+		t.Helper()
+		policy.Backoff = func(int) time.Duration { return 0 }
+		return newTestProvider(t, DialectPostgres, nil, nil, WithRetryPolicy(policy))
+	}
+	failing := func(attempts *int, errs ...error) func() error {
+		return func() error {
+			*attempts++
+			if *attempts <= len(errs) {
+				return errs[*attempts-1]
+			}
+			return nil
+		}
+	}
+
+	t.Run("transient error", func(t *testing.T) {
+		var attempts int
+		p := newProvider(t, RetryPolicy{MaxAttempts: 3})
+		err := p.withRetry(ctx, m, failing(&attempts, serializationFailure, serializationFailure))
+		check.NoError(t, err)
+		check.Number(t, attempts, 3)
+	})
+	t.Run("out of attempts", func(t *testing.T) {
+		var attempts int
+		p := newProvider(t, RetryPolicy{MaxAttempts: 2})
+		err := p.withRetry(ctx, m, failing(&attempts, serializationFailure, serializationFailure))
+		check.IsError(t, err, serializationFailure)
+		check.Number(t, attempts, 2)
+	})
+	t.Run("permanent error", func(t *testing.T) {
+		var attempts int
+		p := newProvider(t, RetryPolicy{MaxAttempts: 3})
+		err := p.withRetry(ctx, m, failing(&attempts, uniqueViolation))
+		check.Bool(t, errors.Is(err, uniqueViolation), true)
+		check.Number(t, attempts, 1)
+	})
+	t.Run("disabled", func(t *testing.T) {
+		var attempts int
+		p := newProvider(t, RetryPolicy{})
+		err := p.withRetry(ctx, m, failing(&attempts, serializationFailure))
+		check.HasError(t, err)
+		check.Number(t, attempts, 1)
+	})
+	t.Run("custom classifier", func(t *testing.T) {
+		var attempts int
+		p := newProvider(t, RetryPolicy{MaxAttempts: 3, Retryable: RetryableSQLStates("23505")})
+		err := p.withRetry(ctx, m, failing(&attempts, uniqueViolation))
+		check.NoError(t, err)
+		check.Number(t, attempts, 2)
+	})
+	t.Run("cancelled", func(t *testing.T) {
+		var attempts int
+		p := newProvider(t, RetryPolicy{MaxAttempts: 3})
+		ctx, cancel := context.WithCancel(ctx)
+		cancel()
+		err := p.withRetry(ctx, m, failing(&attempts, serializationFailure))
+		check.HasError(t, err)
+		check.Number(t, attempts, 1)
+	})
+}
+
+func TestExponentialBackoff(t *testing.T) {
+	t.Parallel()
+
+	backoff := ExponentialBackoff(100*time.Millisecond, time.Second)
+	check.Equal(t, backoff(1), 100*time.Millisecond)
+	check.Equal(t, backoff(2), 200*time.Millisecond)
+	check.Equal(t, backoff(4), 800*time.Millisecond)
+	check.Equal(t, backoff(5), time.Second)
+	check.Equal(t, backoff(50), time.Second)
+}
+
+// flakyDB fails the statements containing match with a serialization failure,
+// the first failures times they run.
+type flakyDB struct {
+	DB
+	match    string
+	failures int
+	attempts int
+}
+
+func (d *flakyDB) Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+	if err := d.attempt(sql); err != nil {
+		return pgconn.CommandTag{}, err
+	}
+	return d.DB.Exec(ctx, sql, args...)
+}
+
+func (d *flakyDB) Begin(ctx context.Context) (pgx.Tx, error) {
+	tx, err := d.DB.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &flakyTx{Tx: tx, db: d}, nil
+}
+
+func (d *flakyDB) attempt(sql string) error {
+	if !strings.Contains(sql, d.match) {
+		return nil
+	}
+	d.attempts++
+	if d.attempts <= d.failures {
+		return &pgconn.PgError{Code: "40001", Message: "could not serialize access"}
+	}
+	return nil
+}
+
+type flakyTx struct {
+	pgx.Tx
+	db *flakyDB
+}
+
+func (t *flakyTx) Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+	if err := t.db.attempt(sql); err != nil {
+		return pgconn.CommandTag{}, err
+	}
+	return t.Tx.Exec(ctx, sql, args...)
+}
+
+func TestRetryMigrations(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	fsys := fstest.MapFS{
+		"00001_a.sql": {Data: []byte("-- +goose Up\nCREATE TABLE a (id INTEGER);\nINSERT INTO a VALUES (1);\n")},
+		"00002_b.sql": {Data: []byte("-- +goose NO TRANSACTION\n-- +goose Up\nCREATE TABLE b (id INTEGER);\nINSERT INTO b VALUES (1);\n")},
+	}
+	newProvider := func(t *testing.T, match string, failures int, onError *int) (*Provider, *flakyDB, *sql.DB) {
+		t.Helper()
+		p, sqlDB := newSQLiteProvider(t, fsys,
+			WithRetryPolicy(RetryPolicy{MaxAttempts: 3, Backoff: func(int) time.Duration { return 0 }}),
+			WithHooks(Hooks{
+				OnError: func(context.Context, *Migration, Direction, DB, error) { *onError++ },
+			}),
+		)
+		db := &flakyDB{DB: p.db, match: match, failures: failures}
+		p.db = db
+		return p, db, sqlDB
+	}
+
+	t.Run("transaction", func(t *testing.T) {
+		var onError int
+		p, db, sqlDB := newProvider(t, "INSERT INTO a", 2, &onError)
+		results, err := p.UpTo(ctx, 1)
+		check.NoError(t, err)
+		check.Number(t, len(results), 1)
+		check.Number(t, db.attempts, 3)
+		check.Number(t, onError, 0)
+		// The failed attempts were rolled back, CREATE TABLE included.
+		check.Number(t, count(t, sqlDB, "SELECT COUNT(*) FROM a"), 1)
+		check.Number(t, count(t, sqlDB, "SELECT COUNT(*) FROM goose_db_version WHERE version_id = 1"), 1)
+	})
+	t.Run("out of attempts", func(t *testing.T) {
+		var onError int
+		p, db, sqlDB := newProvider(t, "INSERT INTO a", 5, &onError)
+		_, err := p.UpTo(ctx, 1)
+		check.HasError(t, err)
+		check.Bool(t, RetryableSQLStates("40001")(err), true)
+		check.Number(t, db.attempts, 3)
+		// Hooks see the final failure only.
+		check.Number(t, onError, 1)
+		check.Number(t, count(t, sqlDB, "SELECT COUNT(*) FROM goose_db_version WHERE version_id = 1"), 0)
+	})
+	t.Run("no transaction", func(t *testing.T) {
+		var onError int
+		p, db, sqlDB := newProvider(t, "INSERT INTO b", 1, &onError)
+		_, err := p.Up(ctx)
+		check.HasError(t, err)
+		check.Number(t, db.attempts, 1)
+		check.Number(t, onError, 1)
+		// A retry would find the table created by the failed attempt.
+		check.Number(t, count(t, sqlDB, "SELECT COUNT(*) FROM sqlite_master WHERE name = 'b'"), 1)
+		version, err := p.GetDBVersion(ctx)
+		check.NoError(t, err)
+		check.Number(t, version, 1)
+	})
+}
@@ -0,0 +1,92 @@
+package goose
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/SergeiSkv/goose/v3/internal/sqlparser"
+)
+
+// WithSQLScript renders the command as a SQL script, written to w, instead of
+// running it. The script holds the statements of the planned migrations, see
+// WithDryRun, wrapped in a transaction where the migration uses one, and the
+// matching version table updates. Running it by hand leaves the database in the
+// same state as running the command.
+//
+// Go migrations cannot be rendered, planning one fails the command.
+func WithSQLScript(w io.Writer) OptionsFunc {
+	return func(o *options) {
+		o.dryRun = true
+		o.script = w
+	}
+}
+
+// writeScriptVersionTable renders the creation of the version table.
+func (p *Provider) writeScriptVersionTable(option *options) error {
+	return p.writeScript(option, "-- create version table", []string{
+		p.store.CreateVersionTableSQL(),
+		p.store.InsertVersionSQL(0, ""),
+	}, true)
+}
+
+// writeScriptMigration renders a migration along with its version table update.
+func (p *Provider) writeScriptMigration(option *options, m *Migration, direction bool, parsed *sqlparser.Parsed, checksum string) error {
+	apply, revert, err := p.settingsStatements(parsed.Settings, parsed.UseTx)
+	if err != nil {
+		return fmt.Errorf("ERROR %v: %w", filepath.Base(m.Source), err)
+	}
+	var statements []string
+	for _, q := range apply {
+		statements = append(statements, q+";")
+	}
+	statements = append(statements, parsed.Statements...)
+	if !option.noVersioning {
+		if m.Repeatable {
+			statements = append(statements, p.store.SetRepeatableSQL(filepath.Base(m.Source), checksum))
+		} else if direction {
+			statements = append(statements, p.store.InsertVersionSQL(m.Version, checksum))
+		} else {
+			statements = append(statements, p.store.DeleteVersionSQL(m.Version))
+		}
+	}
+	for _, q := range revert {
+		statements = append(statements, q+";")
+	}
+	header := fmt.Sprintf("-- +goose %s %s", sqlparser.FromBool(direction), filepath.Base(m.Source))
+	return p.writeScript(option, header, statements, parsed.UseTx)
+}
+
+func (p *Provider) writeScript(option *options, header string, statements []string, useTx bool) error {
+	var b strings.Builder
+	b.WriteString(header + "\n")
+	begin, commit := p.scriptTx()
+	if useTx && begin != "" {
+		b.WriteString(begin + "\n")
+	}
+	for _, s := range statements {
+		b.WriteString(strings.TrimSpace(s) + "\n")
+	}
+	if useTx && commit != "" {
+		b.WriteString(commit + "\n")
+	}
+	b.WriteString("\n")
+	if _, err := io.WriteString(option.script, b.String()); err != nil {
+		return fmt.Errorf("failed to write SQL script: %w", err)
+	}
+	return nil
+}
+
+// scriptTx returns the statements starting and committing a transaction in
+// the provider dialect, or empty strings if it has no transactions.
+func (p *Provider) scriptTx() (begin, commit string) {
+	switch p.dialect {
+	case DialectMSSQL:
+		return "BEGIN TRANSACTION;", "COMMIT TRANSACTION;"
+	case DialectClickHouse:
+		return "", ""
+	default:
+		return "BEGIN;", "COMMIT;"
+	}
+}
@@ -0,0 +1,169 @@
+package goose
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/SergeiSkv/goose/v3/internal/dialect"
+	"github.com/SergeiSkv/goose/v3/internal/sqlparser"
+	"github.com/jackc/pgx/v5"
+)
+
+// SessionSettings are the session settings a migration runs with. SQL
+// migrations declare them with annotations:
+//
+//	-- +goose Set lock_timeout=5s
+//	-- +goose Set search_path=tenant, public
+//	-- +goose Role migrator
+//	-- +goose Isolation serializable
+//
+// and Go migrations with WithSessionSetting, WithSessionRole and
+// WithIsolationLevel.
+//
+// Within a transaction, the settings are local to it. Migrations without a
+// transaction change the session settings and restore their previous values
+// afterwards, they cannot set an isolation level. Session settings are
+// supported by the postgres and redshift dialects.
+type SessionSettings = sqlparser.Settings
+
+// SessionSetting is a configuration parameter, such as lock_timeout, and its
+// value.
+type SessionSetting = sqlparser.Setting
+
+// GoMigrationOption configures a Go migration, see NewGoMigration.
+type GoMigrationOption func(m *Migration)
+
+// WithSessionSetting sets the configuration parameter name to value while the
+// Go migration runs, see SessionSettings.
+func WithSessionSetting(name, value string) GoMigrationOption {
+	return func(m *Migration) {
+		m.Settings.Set = append(m.Settings.Set, SessionSetting{Name: name, Value: value})
+	}
+}
+
+// WithSessionRole runs the Go migration as role, see SessionSettings.
+func WithSessionRole(role string) GoMigrationOption {
+	return func(m *Migration) { m.Settings.Role = role }
+}
+
+// WithIsolationLevel runs the Go migration in a transaction with the given
+// isolation level, such as "serializable", see SessionSettings.
+func WithIsolationLevel(level string) GoMigrationOption {
+	return func(m *Migration) { m.Settings.Isolation = strings.ToLower(level) }
+}
+
+// settingsStatements returns the statements applying the settings at the start
+// of a migration and, for migrations without a transaction, the statements
+// resetting them to their defaults afterwards. Only SQL scripts reset them, a
+// script starting a new session; setSession restores the previous values.
+func (p *Provider) settingsStatements(settings SessionSettings, useTx bool) (apply, revert []string, err error) {
+	if settings.IsZero() {
+		return nil, nil, nil
+	}
+	switch p.dialect {
+	case DialectPostgres, DialectRedshift:
+	default:
+		return nil, nil, fmt.Errorf("session settings are not supported by the %s dialect", p.dialect)
+	}
+	if err := settings.Validate(); err != nil {
+		return nil, nil, err
+	}
+	if settings.Isolation != "" {
+		if !useTx {
+			return nil, nil, errors.New("an isolation level requires a transaction")
+		}
+		// Must be the first statement of the transaction.
+		apply = append(apply, fmt.Sprintf("SET TRANSACTION ISOLATION LEVEL %s", strings.ToUpper(settings.Isolation)))
+	}
+	for _, set := range settings.Set {
+		apply = append(apply, fmt.Sprintf("SELECT set_config(%s, %s, %t)", dialect.StringLiteral(set.Name), dialect.StringLiteral(set.Value), useTx))
+	}
+	if settings.Role != "" {
+		role := pgx.Identifier{settings.Role}.Sanitize()
+		if useTx {
+			apply = append(apply, "SET LOCAL ROLE "+role)
+		} else {
+			apply = append(apply, "SET ROLE "+role)
+			revert = append(revert, "RESET ROLE")
+		}
+	}
+	if !useTx {
+		for i := len(settings.Set) - 1; i >= 0; i-- {
+			revert = append(revert, "RESET "+settings.Set[i].Name)
+		}
+	}
+	return apply, revert, nil
+}
+
+// beginTx begins the transaction of a migration and applies its settings.
+func (p *Provider) beginTx(ctx context.Context, settings SessionSettings) (pgx.Tx, error) {
+	apply, _, err := p.settingsStatements(settings, true)
+	if err != nil {
+		return nil, err
+	}
+	tx, err := p.db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	for _, q := range apply {
+		if _, err := tx.Exec(ctx, q); err != nil {
+			_ = rollback(tx)
+			return nil, fmt.Errorf("failed to apply session settings %q: %w", q, err)
+		}
+	}
+	return tx, nil
+}
+
+// setSession applies the settings of a migration without a transaction to the
+// session. The returned function restores their previous values, which are
+// not the defaults if the caller changed them.
+func (p *Provider) setSession(ctx context.Context, settings SessionSettings) (func() error, error) {
+	apply, _, err := p.settingsStatements(settings, false)
+	if err != nil {
+		return nil, err
+	}
+	revert, err := p.restoreStatements(ctx, settings)
+	if err != nil {
+		return nil, err
+	}
+	reset := func() error {
+		// Reset even if the command context has been cancelled.
+		for _, q := range revert {
+			if _, err := p.db.Exec(context.Background(), q); err != nil {
+				return fmt.Errorf("failed to reset session settings %q: %w", q, err)
+			}
+		}
+		return nil
+	}
+	for _, q := range apply {
+		if _, err := p.db.Exec(ctx, q); err != nil {
+			_ = reset()
+			return nil, fmt.Errorf("failed to apply session settings %q: %w", q, err)
+		}
+	}
+	return reset, nil
+}
+
+// restoreStatements returns the statements restoring the current session
+// values of the settings, and of the role if they set one, in the reverse
+// order of settingsStatements.
+func (p *Provider) restoreStatements(ctx context.Context, settings SessionSettings) ([]string, error) {
+	var names []string
+	if settings.Role != "" {
+		names = append(names, "role")
+	}
+	for i := len(settings.Set) - 1; i >= 0; i-- {
+		names = append(names, settings.Set[i].Name)
+	}
+	revert := make([]string, 0, len(names))
+	for _, name := range names {
+		var value string
+		if err := p.db.QueryRow(ctx, "SELECT current_setting("+dialect.StringLiteral(name)+")").Scan(&value); err != nil {
+			return nil, fmt.Errorf("failed to read session setting %s: %w", name, err)
+		}
+		revert = append(revert, fmt.Sprintf("SELECT set_config(%s, %s, false)", dialect.StringLiteral(name), dialect.StringLiteral(value)))
+	}
+	return revert, nil
+}
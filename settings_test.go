@@ -0,0 +1,177 @@
+package goose
+
+import (
+	"bytes"
+	"context"
+	"database/sql/driver"
+	"sync"
+	"testing"
+	"testing/fstest"
+
+	"github.com/SergeiSkv/goose/v3/internal/check"
+	"github.com/jackc/pgx/v5"
+	"modernc.org/sqlite"
+)
+
+func TestSessionSettings(t *testing.T) {
+	t.Parallel()
+
+	newProvider := func(t *testing.T, d Dialect, fsys fstest.MapFS) *Provider {
+		t.Helper()
+		return newTestProvider(t, d, fsys, nil)
+	}
+	settings := SessionSettings{
+		Set:       []SessionSetting{{Name: "lock_timeout", Value: "5s"}, {Name: "search_path", Value: "o'neil, public"}},
+		Role:      "migrator",
+		Isolation: "serializable",
+	}
+
+	t.Run("transaction", func(t *testing.T) {
+		p := newProvider(t, DialectPostgres, nil)
+		apply, revert, err := p.settingsStatements(settings, true)
+		check.NoError(t, err)
+		check.Equal(t, apply, []string{
+			"SET TRANSACTION ISOLATION LEVEL SERIALIZABLE",
+			"SELECT set_config('lock_timeout', '5s', true)",
+			"SELECT set_config('search_path', 'o''neil, public', true)",
+			`SET LOCAL ROLE "migrator"`,
+		})
+		check.Number(t, len(revert), 0)
+	})
+	t.Run("no transaction", func(t *testing.T) {
+		p := newProvider(t, DialectPostgres, nil)
+		noIsolation := settings
+		noIsolation.Isolation = ""
+		apply, revert, err := p.settingsStatements(noIsolation, false)
+		check.NoError(t, err)
+		check.Equal(t, apply, []string{
+			"SELECT set_config('lock_timeout', '5s', false)",
+			"SELECT set_config('search_path', 'o''neil, public', false)",
+			`SET ROLE "migrator"`,
+		})
+		check.Equal(t, revert, []string{"RESET ROLE", "RESET search_path", "RESET lock_timeout"})
+
+		_, _, err = p.settingsStatements(settings, false)
+		check.HasError(t, err)
+		check.Contains(t, err.Error(), "requires a transaction")
+	})
+	t.Run("invalid", func(t *testing.T) {
+		p := newProvider(t, DialectPostgres, nil)
+		_, _, err := p.settingsStatements(SessionSettings{Set: []SessionSetting{{Name: "a; DROP TABLE b", Value: "1"}}}, true)
+		check.HasError(t, err)
+		check.Contains(t, err.Error(), "invalid setting name")
+		_, _, err = p.settingsStatements(SessionSettings{Isolation: "sometimes"}, true)
+		check.HasError(t, err)
+	})
+	t.Run("unsupported dialect", func(t *testing.T) {
+		p := newProvider(t, DialectMySQL, nil)
+		_, _, err := p.settingsStatements(settings, true)
+		check.HasError(t, err)
+		check.Contains(t, err.Error(), "not supported by the mysql dialect")
+		apply, revert, err := p.settingsStatements(SessionSettings{}, true)
+		check.NoError(t, err)
+		check.Number(t, len(apply)+len(revert), 0)
+	})
+	t.Run("go migration options", func(t *testing.T) {
+		m := NewGoMigration("00001_a.go", nil, nil,
+			WithSessionSetting("lock_timeout", "5s"),
+			WithSessionRole("migrator"),
+			WithIsolationLevel("REPEATABLE READ"),
+		)
+		check.Equal(t, m.Settings, SessionSettings{
+			Set:       []SessionSetting{{Name: "lock_timeout", Value: "5s"}},
+			Role:      "migrator",
+			Isolation: "repeatable read",
+		})
+	})
+	t.Run("sql script", func(t *testing.T) {
+		p := newProvider(t, DialectPostgres, fstest.MapFS{
+			"00001_a.sql": {Data: []byte("-- +goose Up\n-- +goose Set lock_timeout=5s\n-- +goose Role migrator\nCREATE TABLE a (id int);\n-- +goose Down\nDROP TABLE a;\n")},
+			"00002_b.sql": {Data: []byte("-- +goose NO TRANSACTION\n-- +goose Set lock_timeout=5s\n-- +goose Up\nCREATE INDEX CONCURRENTLY a_idx ON a (id);\n-- +goose Down\nDROP INDEX a_idx;\n")},
+		})
+		var buf bytes.Buffer
+		_, err := p.Up(context.Background(), WithSQLScript(&buf), WithNoVersioning())
+		check.NoError(t, err)
+		want := `-- +goose up 00001_a.sql
+BEGIN;
+SELECT set_config('lock_timeout', '5s', true);
+SET LOCAL ROLE "migrator";
+CREATE TABLE a (id int);
+COMMIT;
+
+-- +goose up 00002_b.sql
+SELECT set_config('lock_timeout', '5s', false);
+CREATE INDEX CONCURRENTLY a_idx ON a (id);
+RESET lock_timeout;
+
+`
+		check.Equal(t, buf.String(), want)
+	})
+}
+
+// session emulates the settings of a Postgres session on SQLite, through the
+// current_setting and set_config functions. Local settings are not emulated,
+// they are kept until changed.
+var session = struct {
+	sync.Mutex
+	register sync.Once
+	settings map[string]string
+}{settings: make(map[string]string)}
+
+func registerSessionFunctions() {
+	session.register.Do(func() {
+		sqlite.MustRegisterScalarFunction("current_setting", 1, func(_ *sqlite.FunctionContext, args []driver.Value) (driver.Value, error) {
+			session.Lock()
+			defer session.Unlock()
+			return session.settings[args[0].(string)], nil
+		})
+		sqlite.MustRegisterScalarFunction("set_config", 3, func(_ *sqlite.FunctionContext, args []driver.Value) (driver.Value, error) {
+			session.Lock()
+			defer session.Unlock()
+			session.settings[args[0].(string)] = args[1].(string)
+			return args[1], nil
+		})
+	})
+}
+
+func TestSessionSettingsRestored(t *testing.T) {
+	t.Parallel()
+
+	registerSessionFunctions()
+	ctx := context.Background()
+	var seen []string
+	read := func(ctx context.Context, db Queryer) error {
+		var value string
+		if err := db.QueryRow(ctx, "SELECT current_setting('goose.restored')").Scan(&value); err != nil {
+			return err
+		}
+		seen = append(seen, value)
+		return nil
+	}
+	p := newTestProvider(t, DialectPostgres, nil, nil, WithLocker(nil), WithGoMigrations(
+		NewGoMigrationNoTx("00001_a.go",
+			func(ctx context.Context, db DB) error { return read(ctx, db) },
+			nil, WithSessionSetting("goose.restored", "10s"),
+		),
+		NewGoMigration("00002_b.go",
+			func(ctx context.Context, tx pgx.Tx) error { return read(ctx, tx) },
+			nil, WithSessionSetting("goose.restored", "5s"),
+		),
+	))
+	p.db = NewSQLDB(newSQLiteDB(t))
+	current := func() string {
+		var value string
+		check.NoError(t, p.db.QueryRow(ctx, "SELECT current_setting('goose.restored')").Scan(&value))
+		return value
+	}
+
+	_, err := p.db.Exec(ctx, "SELECT set_config('goose.restored', '1s', false)")
+	check.NoError(t, err)
+	_, err = p.UpTo(ctx, 1, WithNoVersioning())
+	check.NoError(t, err)
+	// The value set by the caller is restored, not reset to the default.
+	check.Equal(t, current(), "1s")
+	_, err = p.Up(ctx, WithNoVersioning())
+	check.NoError(t, err)
+	check.Equal(t, seen[len(seen)-2:], []string{"10s", "5s"})
+}
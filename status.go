@@ -1,6 +1,7 @@
 package goose
 
 import (
+	"bytes"
 	"context"
 	"database/sql"
 	"errors"
@@ -8,54 +9,181 @@ import (
 	"path/filepath"
 	"time"
 
+	"github.com/SergeiSkv/goose/v3/internal/sqlparser"
 	"github.com/jackc/pgx/v5"
 )
 
+// MigrationType is the type of a migration file.
+type MigrationType string
+
+const (
+	TypeSQL MigrationType = "sql"
+	TypeGo  MigrationType = "go"
+)
+
+// MigrationStatus is the status of a single migration.
+type MigrationStatus struct {
+	Version int64
+	Source  string
+	Type    MigrationType
+	// UseTx is false if the migration runs outside a transaction, i.e. the .sql
+	// file is annotated with NO TRANSACTION or the Go migration was added with
+	// AddMigrationNoTx.
+	UseTx   bool
+	Applied bool
+	// AppliedAt is the time the migration was applied, zero if pending.
+	AppliedAt time.Time
+}
+
 // Status prints the status of all migrations.
-func Status(db *pgx.Conn, dir string, opts ...OptionsFunc) error {
-	ctx := context.Background()
-	option := &options{}
-	for _, f := range opts {
-		f(option)
+func Status(db DB, dir string, opts ...OptionsFunc) error {
+	return StatusContext(context.Background(), db, dir, opts...)
+}
+
+// StatusContext prints the status of all migrations.
+func StatusContext(ctx context.Context, db DB, dir string, opts ...OptionsFunc) error {
+	p, err := newGlobalProvider(db, dir)
+	if err != nil {
+		return err
+	}
+	return p.Status(ctx, opts...)
+}
+
+// ListStatus returns the status of all migrations, ordered by version.
+func ListStatus(db DB, dir string, opts ...OptionsFunc) ([]*MigrationStatus, error) {
+	return ListStatusContext(context.Background(), db, dir, opts...)
+}
+
+// ListStatusContext returns the status of all migrations, ordered by version.
+func ListStatusContext(ctx context.Context, db DB, dir string, opts ...OptionsFunc) ([]*MigrationStatus, error) {
+	p, err := newGlobalProvider(db, dir)
+	if err != nil {
+		return nil, err
 	}
-	migrations, err := CollectMigrations(dir, minVersion, maxVersion)
+	return p.ListStatus(ctx, opts...)
+}
+
+// Status prints the status of all migrations.
+func (p *Provider) Status(ctx context.Context, opts ...OptionsFunc) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	option := p.applyOptions(opts)
+	return p.traceCommand(ctx, "status", option, func(ctx context.Context) error {
+		return p.status(ctx, option)
+	})
+}
+
+func (p *Provider) status(ctx context.Context, option *options) error {
+	statuses, err := p.listStatus(ctx, option)
 	if err != nil {
-		return fmt.Errorf("failed to collect migrations: %w", err)
+		return err
+	}
+
+	if p.logging.structured == nil {
+		p.logging.logger.Println("    Applied At                  Migration")
+		p.logging.logger.Println("    =======================================")
 	}
-	if option.noVersioning {
-		log.Println("    Applied At                  Migration")
-		log.Println("    =======================================")
-		for _, current := range migrations {
-			log.Printf("    %-24s -- %v\n", "no versioning", filepath.Base(current.Source))
+	for _, s := range statuses {
+		appliedAt := "Pending"
+		switch {
+		case option.noVersioning:
+			appliedAt = "no versioning"
+		case s.Applied:
+			appliedAt = s.AppliedAt.Format(time.ANSIC)
 		}
-		return nil
+		p.log(ctx, LevelInfo, "migration status", "    %-24[3]s -- %[2]v\n",
+			Field{Key: "version", Value: s.Version},
+			Field{Key: "file", Value: filepath.Base(s.Source)},
+			Field{Key: "applied_at", Value: appliedAt},
+		)
 	}
 
-	// must ensure that the version table exists if we're running on a pristine DB
-	if _, err := EnsureDBVersion(db); err != nil {
-		return fmt.Errorf("failed to ensure DB version: %w", err)
+	return nil
+}
+
+// ListStatus returns the status of all migrations, ordered by version. With
+// WithNoVersioning the database is not queried and all migrations are
+// reported as pending.
+func (p *Provider) ListStatus(ctx context.Context, opts ...OptionsFunc) ([]*MigrationStatus, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	option := p.applyOptions(opts)
+	var statuses []*MigrationStatus
+	err := p.traceCommand(ctx, "status", option, func(ctx context.Context) (err error) {
+		statuses, err = p.listStatus(ctx, option)
+		return err
+	})
+	return statuses, err
+}
+
+func (p *Provider) listStatus(ctx context.Context, option *options) ([]*MigrationStatus, error) {
+	migrations, err := p.collectMigrations(minVersion, maxVersion)
+	if err != nil {
+		return nil, fmt.Errorf("failed to collect migrations: %w", err)
+	}
+	// The status is read without the lock, so the version table must not be
+	// created or upgraded: on a pristine database every migration is pending.
+	versioned := false
+	if !option.noVersioning {
+		if versioned, err = p.checkVersionTable(ctx); err != nil {
+			return nil, err
+		}
 	}
 
-	log.Println("    Applied At                  Migration")
-	log.Println("    =======================================")
+	statuses := make([]*MigrationStatus, 0, len(migrations))
 	for _, migration := range migrations {
-		if err := printMigrationStatus(ctx, db, migration.Version, filepath.Base(migration.Source)); err != nil {
-			return fmt.Errorf("failed to print status: %w", err)
+		useTx, err := p.migrationUseTx(migration)
+		if err != nil {
+			return nil, err
+		}
+		s := &MigrationStatus{
+			Version: migration.Version,
+			Source:  migration.Source,
+			Type:    migrationType(migration),
+			UseTx:   useTx,
 		}
+		if versioned {
+			m, err := p.store.GetMigration(ctx, p.db, migration.Version)
+			if err != nil && !isNoRows(err) {
+				return nil, fmt.Errorf("failed to query the latest migration: %w", err)
+			}
+			if m != nil && m.IsApplied {
+				s.Applied = true
+				s.AppliedAt = m.Timestamp
+			}
+		}
+		statuses = append(statuses, s)
 	}
-
-	return nil
+	return statuses, nil
 }
 
-func printMigrationStatus(ctx context.Context, db *pgx.Conn, version int64, script string) error {
-	m, err := store.GetMigration(ctx, db, version)
-	if err != nil && !errors.Is(err, sql.ErrNoRows) {
-		return fmt.Errorf("failed to query the latest migration: %w", err)
+// migrationUseTx reports whether the migration runs within a transaction,
+// parsing .sql files for the NO TRANSACTION annotation.
+func (p *Provider) migrationUseTx(m *Migration) (bool, error) {
+	if migrationType(m) != TypeSQL {
+		return m.UseTx, nil
 	}
-	appliedAt := "Pending"
-	if m != nil && m.IsApplied {
-		appliedAt = m.Timestamp.Format(time.ANSIC)
+	_, rendered, err := p.readSQLMigration(m)
+	if err != nil {
+		return false, err
 	}
-	log.Printf("    %-24s -- %v\n", appliedAt, script)
-	return nil
+	_, useTx, err := sqlparser.ParseSQLMigration(bytes.NewReader(rendered), sqlparser.DirectionUp, false)
+	if err != nil {
+		return false, fmt.Errorf("failed to parse SQL migration file %q: %w", filepath.Base(m.Source), err)
+	}
+	return useTx, nil
+}
+
+func migrationType(m *Migration) MigrationType {
+	if filepath.Ext(m.Source) == ".sql" || sqlparser.IsTemplate(m.Source) {
+		return TypeSQL
+	}
+	return TypeGo
+}
+
+// isNoRows reports whether err signals an empty result, as returned by either
+// database/sql or pgx.
+func isNoRows(err error) bool {
+	return errors.Is(err, sql.ErrNoRows) || errors.Is(err, pgx.ErrNoRows)
 }
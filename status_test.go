@@ -0,0 +1,27 @@
+package goose
+
+import (
+	"context"
+	"testing"
+	"testing/fstest"
+
+	"github.com/SergeiSkv/goose/v3/internal/check"
+)
+
+func TestListStatusNoVersioning(t *testing.T) {
+	t.Parallel()
+
+	fsys := fstest.MapFS{
+		"00001_a.sql": {Data: []byte("-- +goose Up\nSELECT 1;\n")},
+		"00003_c.sql": {Data: []byte("-- +goose NO TRANSACTION\n-- +goose Up\nSELECT 3;\n")},
+	}
+	p := newTestProvider(t, DialectPostgres, fsys, nil, WithGoMigrations(NewGoMigrationNoTx("00002_b.go", nil, nil)))
+	// Versioning is disabled, so the database is never queried.
+	statuses, err := p.ListStatus(context.Background(), WithNoVersioning())
+	check.NoError(t, err)
+	check.Number(t, len(statuses), 3)
+
+	check.Equal(t, *statuses[0], MigrationStatus{Version: 1, Source: "00001_a.sql", Type: TypeSQL, UseTx: true})
+	check.Equal(t, *statuses[1], MigrationStatus{Version: 2, Source: "00002_b.go", Type: TypeGo, UseTx: false})
+	check.Equal(t, *statuses[2], MigrationStatus{Version: 3, Source: "00003_c.sql", Type: TypeSQL, UseTx: false})
+}
@@ -0,0 +1,83 @@
+package goose
+
+import (
+	"fmt"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"text/template"
+
+	"github.com/SergeiSkv/goose/v3/internal/sqlparser"
+)
+
+// templateOptions configures how SQL migrations are rendered: the variables
+// they expand and the data and functions of their templates.
+type templateOptions struct {
+	envVars map[string]string
+	data    map[string]interface{}
+	funcs   template.FuncMap
+}
+
+// SetTemplateData sets the data SQL migration templates are rendered with by
+// the package-level functions, see WithTemplateData.
+func SetTemplateData(data map[string]interface{}) {
+	WithTemplateData(data)(&defaultOptions)
+}
+
+// SetTemplateFuncs sets the functions available to SQL migration templates
+// rendered by the package-level functions, see WithTemplateFuncs.
+func SetTemplateFuncs(funcs template.FuncMap) {
+	WithTemplateFuncs(funcs)(&defaultOptions)
+}
+
+// WithTemplateData sets the data SQL migration templates are rendered with.
+//
+// SQL migrations with the .sql.tmpl extension, such as 00002_partitions.sql.tmpl,
+// are text/template templates, rendered before they are parsed:
+//
+//	-- +goose Up
+//	{{ range .Months }}
+//	CREATE TABLE events_{{ . }} PARTITION OF events FOR VALUES IN ('{{ . }}');
+//	{{- end }}
+//
+// Referencing a key missing from data is an error. The checksum of a template
+// is that of its source, not of the rendered SQL.
+func WithTemplateData(data map[string]interface{}) ProviderOptionsFunc {
+	return func(o *providerOptions) { o.templates.data = data }
+}
+
+// WithTemplateFuncs sets the functions available to SQL migration templates,
+// in addition to the text/template builtins, see WithTemplateData.
+func WithTemplateFuncs(funcs template.FuncMap) ProviderOptionsFunc {
+	return func(o *providerOptions) { o.templates.funcs = funcs }
+}
+
+// readSQLMigration reads the SQL migration file of m. It returns its content,
+// and the SQL to parse, which is rendered if the file is a template.
+func (p *Provider) readSQLMigration(m *Migration) (data, rendered []byte, err error) {
+	data, err = fs.ReadFile(p.fsys, m.Source)
+	if err != nil {
+		return nil, nil, withClass(ErrParse, fmt.Errorf("ERROR %v: failed to open SQL migration file: %w", filepath.Base(m.Source), err))
+	}
+	if !sqlparser.IsTemplate(m.Source) {
+		return data, data, nil
+	}
+	rendered, err = sqlparser.RenderTemplate(m.Source, data, p.templates.data, p.templates.funcs)
+	if err != nil {
+		return nil, nil, withClass(ErrParse, fmt.Errorf("ERROR %v: %w", filepath.Base(m.Source), err))
+	}
+	return data, rendered, nil
+}
+
+// globSQLMigrations returns the SQL migration files and templates in dirpath.
+func globSQLMigrations(fsys fs.FS, dirpath string) ([]string, error) {
+	var files []string
+	for _, pattern := range []string{"*.sql", "*" + sqlparser.TemplateExt} {
+		matches, err := fs.Glob(fsys, path.Join(dirpath, pattern))
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, matches...)
+	}
+	return files, nil
+}
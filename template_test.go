@@ -0,0 +1,64 @@
+package goose
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"text/template"
+
+	"github.com/SergeiSkv/goose/v3/internal/check"
+)
+
+func TestSQLTemplates(t *testing.T) {
+	t.Parallel()
+
+	fsys := fstest.MapFS{
+		"00001_events.sql":          {Data: []byte("-- +goose Up\nCREATE TABLE events (month text) PARTITION BY LIST (month);\n")},
+		"00002_partitions.sql.tmpl": {Data: []byte("-- +goose Up\n{{ range .Months }}CREATE TABLE events_{{ . }} PARTITION OF events FOR VALUES IN ('{{ upper . }}');\n{{ end }}")},
+		"R_views.sql.tmpl":          {Data: []byte("-- +goose Up\nCREATE OR REPLACE VIEW {{ .View }} AS SELECT * FROM events;\n")},
+	}
+	newProvider := func(t *testing.T, opts ...ProviderOptionsFunc) *Provider {
+		t.Helper()
+		return newTestProvider(t, DialectPostgres, fsys, nil, opts...)
+	}
+
+	v, err := NumericComponent("00002_partitions.sql.tmpl")
+	check.NoError(t, err)
+	check.Number(t, v, 2)
+
+	p := newProvider(t,
+		WithTemplateData(map[string]interface{}{"Months": []string{"jan", "feb"}, "View": "all_events"}),
+		WithTemplateFuncs(template.FuncMap{"upper": strings.ToUpper}),
+	)
+	sources, err := p.ListSources()
+	check.NoError(t, err)
+	check.Number(t, len(sources), 2)
+	var buf bytes.Buffer
+	_, err = p.Up(context.Background(), WithSQLScript(&buf), WithNoVersioning())
+	check.NoError(t, err)
+	want := `-- +goose up 00001_events.sql
+BEGIN;
+CREATE TABLE events (month text) PARTITION BY LIST (month);
+COMMIT;
+
+-- +goose up 00002_partitions.sql.tmpl
+BEGIN;
+CREATE TABLE events_jan PARTITION OF events FOR VALUES IN ('JAN');
+CREATE TABLE events_feb PARTITION OF events FOR VALUES IN ('FEB');
+COMMIT;
+
+-- +goose up R_views.sql.tmpl
+BEGIN;
+CREATE OR REPLACE VIEW all_events AS SELECT * FROM events;
+COMMIT;
+
+`
+	check.Equal(t, buf.String(), want)
+
+	_, err = newProvider(t).Up(context.Background(), WithDryRun())
+	check.HasError(t, err)
+	check.Contains(t, err.Error(), "00002_partitions.sql.tmpl")
+	check.Contains(t, err.Error(), `function "upper" not defined`)
+}
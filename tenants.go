@@ -0,0 +1,261 @@
+package goose
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// TenantResult is the outcome of a command for a single tenant schema.
+type TenantResult struct {
+	Tenant string
+	// Version is the version of the tenant once the command is done, or -1 if
+	// it could not be read.
+	Version int64
+	// Results are the migrations run for the tenant.
+	Results  []*MigrationResult
+	Duration time.Duration
+	// Skipped is true if the command was not run for the tenant, because an
+	// earlier tenant failed or the context was cancelled.
+	Skipped bool
+	// Error is the error the command failed with for the tenant, if any.
+	Error error
+}
+
+// TenantReport is the outcome of a command run across tenant schemas, see
+// UpTenantsContext.
+type TenantReport struct {
+	// Tenants holds a result for every tenant, in the order the tenants were
+	// given.
+	Tenants []*TenantResult
+}
+
+// Failed returns the results of the tenants the command failed for.
+func (r *TenantReport) Failed() []*TenantResult {
+	var failed []*TenantResult
+	for _, t := range r.Tenants {
+		if t.Error != nil {
+			failed = append(failed, t)
+		}
+	}
+	return failed
+}
+
+// Skipped returns the results of the tenants the command was not run for.
+func (r *TenantReport) Skipped() []*TenantResult {
+	var skipped []*TenantResult
+	for _, t := range r.Tenants {
+		if t.Skipped {
+			skipped = append(skipped, t)
+		}
+	}
+	return skipped
+}
+
+// ByVersion groups the tenants whose version is known by version. The tenants
+// of each version are sorted by name.
+func (r *TenantReport) ByVersion() map[int64][]string {
+	versions := make(map[int64][]string)
+	for _, t := range r.Tenants {
+		if t.Skipped || t.Version < 0 {
+			continue
+		}
+		versions[t.Version] = append(versions[t.Version], t.Tenant)
+	}
+	for _, tenants := range versions {
+		sort.Strings(tenants)
+	}
+	return versions
+}
+
+type tenantOptions struct {
+	concurrency     int
+	continueOnError bool
+	providerOptions []ProviderOptionsFunc
+	options         []OptionsFunc
+}
+
+// TenantOption configures a command run across tenant schemas.
+type TenantOption func(o *tenantOptions)
+
+// WithTenantConcurrency sets how many tenants are migrated at the same time,
+// each over its own connection. Defaults to 1.
+func WithTenantConcurrency(n int) TenantOption {
+	return func(o *tenantOptions) { o.concurrency = n }
+}
+
+// WithContinueOnError keeps migrating the remaining tenants after a tenant
+// failed. By default, no new tenant is started once one failed and the
+// remaining ones are reported as skipped.
+func WithContinueOnError() TenantOption {
+	return func(o *tenantOptions) { o.continueOnError = true }
+}
+
+// WithTenantProviderOptions configures the provider of every tenant, on top of
+// the package-level state.
+func WithTenantProviderOptions(opts ...ProviderOptionsFunc) TenantOption {
+	return func(o *tenantOptions) { o.providerOptions = append(o.providerOptions, opts...) }
+}
+
+// WithTenantOptions sets the options of the command run for every tenant, such
+// as WithAllowMissing or WithDryRun.
+func WithTenantOptions(opts ...OptionsFunc) TenantOption {
+	return func(o *tenantOptions) { o.options = append(o.options, opts...) }
+}
+
+// tenantName matches the schema names that need no quoting, so that the
+// qualified version table name can be used as is in queries.
+var tenantName = regexp.MustCompile(`^[a-z_][a-z0-9_]*$`)
+
+// ReadTenants reads a list of tenant schemas, one per line. Blank lines and
+// lines starting with # are ignored.
+func ReadTenants(r io.Reader) ([]string, error) {
+	return readList(r, "tenants")
+}
+
+// QueryTenants returns the tenant schemas listed by the first column of query,
+// for example:
+//
+//	SELECT schema_name FROM tenants WHERE active
+func QueryTenants(ctx context.Context, db DB, query string) ([]string, error) {
+	rows, err := db.Query(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query tenants: %w", err)
+	}
+	defer rows.Close()
+	var tenants []string
+	for rows.Next() {
+		values, err := rows.Values()
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan tenant: %w", err)
+		}
+		if len(values) == 0 {
+			return nil, errors.New("tenant query must return a column")
+		}
+		tenants = append(tenants, fmt.Sprint(values[0]))
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query tenants: %w", err)
+	}
+	return tenants, nil
+}
+
+// UpTenantsContext applies all available migrations in dir to every tenant
+// schema. It is meant for multi-tenant databases with one schema per tenant,
+// which must already exist.
+//
+// Each tenant is migrated over its own connection, opened with connect, with
+// the tenant schema first in the search_path and its own version table, such
+// as tenant_a.goose_db_version, and migration lock. Tenant names must be
+// lowercase unquoted identifiers. Providers are configured from the
+// package-level state, which is only read, and the options set with
+// WithTenantProviderOptions. Log output is prefixed with the tenant, or has a
+// tenant field for structured loggers.
+//
+// The report has a result for every tenant. The returned error, if any, tells
+// how many tenants failed and wraps the error of the first of them. Only the
+// postgres dialect is supported.
+func UpTenantsContext(ctx context.Context, connect ConnectFunc, dir string, tenants []string, opts ...TenantOption) (*TenantReport, error) {
+	return runTenants(ctx, connect, dir, tenants, opts, func(ctx context.Context, p *Provider, option *tenantOptions) ([]*MigrationResult, error) {
+		return p.Up(ctx, option.options...)
+	})
+}
+
+// TenantVersionsContext reads the version of every tenant schema, without
+// migrating them nor creating their version table: a tenant without one is at
+// version 0. See UpTenantsContext.
+func TenantVersionsContext(ctx context.Context, connect ConnectFunc, dir string, tenants []string, opts ...TenantOption) (*TenantReport, error) {
+	return runTenants(ctx, connect, dir, tenants, opts, func(ctx context.Context, p *Provider, option *tenantOptions) ([]*MigrationResult, error) {
+		return nil, nil
+	})
+}
+
+type tenantCommand func(ctx context.Context, p *Provider, option *tenantOptions) ([]*MigrationResult, error)
+
+func runTenants(ctx context.Context, connect ConnectFunc, dir string, tenants []string, opts []TenantOption, fn tenantCommand) (*TenantReport, error) {
+	option := &tenantOptions{concurrency: 1}
+	for _, f := range opts {
+		f(option)
+	}
+	if option.concurrency < 1 {
+		return nil, fmt.Errorf("tenant concurrency must be at least 1, got %d", option.concurrency)
+	}
+	if d := globalDialect(option.providerOptions); d != DialectPostgres {
+		return nil, fmt.Errorf("tenant schemas are not supported by the %s dialect", d)
+	}
+	seen := make(map[string]bool, len(tenants))
+	report := &TenantReport{Tenants: make([]*TenantResult, 0, len(tenants))}
+	for _, tenant := range tenants {
+		if !tenantName.MatchString(tenant) {
+			return nil, fmt.Errorf("invalid tenant %q: must be a lowercase unquoted identifier", tenant)
+		}
+		if seen[tenant] {
+			return nil, fmt.Errorf("duplicate tenant %q", tenant)
+		}
+		seen[tenant] = true
+		report.Tenants = append(report.Tenants, &TenantResult{Tenant: tenant, Version: -1})
+	}
+
+	fanOut(ctx, 0, len(report.Tenants), option.concurrency, option.continueOnError, func(i int) error {
+		result := report.Tenants[i]
+		start := time.Now()
+		result.Error = runTenant(ctx, connect, dir, result, option, fn)
+		result.Duration = time.Since(start)
+		return result.Error
+	}, func(i int) {
+		report.Tenants[i].Skipped = true
+	})
+
+	if failed := report.Failed(); len(failed) > 0 {
+		return report, fmt.Errorf("%d of %d tenants failed, %s: %w", len(failed), len(report.Tenants), failed[0].Tenant, failed[0].Error)
+	}
+	return report, nil
+}
+
+// runTenant runs fn for a single tenant and records its version.
+func runTenant(ctx context.Context, connect ConnectFunc, dir string, result *TenantResult, option *tenantOptions, fn tenantCommand) error {
+	tenant := result.Tenant
+	db, err := connect(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to connect: %w", err)
+	}
+	defer db.Close(context.Background())
+	if _, err := db.Exec(ctx,
+		"SELECT set_config('search_path', $1 || ', ' || current_setting('search_path'), false)",
+		pgx.Identifier{tenant}.Sanitize(),
+	); err != nil {
+		return fmt.Errorf("failed to set search_path: %w", err)
+	}
+	opts := append([]ProviderOptionsFunc{}, option.providerOptions...)
+	opts = append(opts, withTenant(tenant))
+	p, err := newGlobalProvider(db, dir, opts...)
+	if err != nil {
+		return err
+	}
+	result.Results, err = fn(ctx, p, option)
+	// Report the version even if the command failed, the failed migration
+	// having been rolled back. The version is read without creating the
+	// version table, which only the command itself may do.
+	if version, versionErr := p.GetDBVersion(ctx); versionErr == nil {
+		result.Version = version
+	} else if err == nil {
+		err = versionErr
+	}
+	return err
+}
+
+// withTenant qualifies the version table with the tenant schema and tags the
+// log output with the tenant. It must be the last option applied.
+func withTenant(tenant string) ProviderOptionsFunc {
+	tag := withLogTag("tenant", tenant)
+	return func(o *providerOptions) {
+		o.tableName = tenant + "." + o.tableName
+		tag(o)
+	}
+}
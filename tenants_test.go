@@ -0,0 +1,157 @@
+package goose
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"testing/fstest"
+
+	"github.com/SergeiSkv/goose/v3/internal/check"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// fieldsLogger is a StructuredLogger recording the fields of events.
+type fieldsLogger struct {
+	fields [][]Field
+}
+
+func (l *fieldsLogger) Log(ctx context.Context, level Level, msg string, fields ...Field) {
+	l.fields = append(l.fields, fields)
+}
+
+func TestReadTenants(t *testing.T) {
+	t.Parallel()
+
+	tenants, err := ReadTenants(strings.NewReader("# tenants\nacme\n\n  globex  \n#initech\numbrella\n"))
+	check.NoError(t, err)
+	check.Equal(t, strings.Join(tenants, ","), "acme,globex,umbrella")
+}
+
+func TestTenantReport(t *testing.T) {
+	t.Parallel()
+
+	report := &TenantReport{Tenants: []*TenantResult{
+		{Tenant: "globex", Version: 3},
+		{Tenant: "acme", Version: 3},
+		{Tenant: "initech", Version: 2, Error: errors.New("boom")},
+		{Tenant: "umbrella", Version: -1, Error: errors.New("connection refused")},
+		{Tenant: "hooli", Version: -1, Skipped: true},
+	}}
+	versions := report.ByVersion()
+	check.Number(t, len(versions), 2)
+	check.Equal(t, strings.Join(versions[3], ","), "acme,globex")
+	check.Equal(t, strings.Join(versions[2], ","), "initech")
+	check.Number(t, len(report.Failed()), 2)
+	check.Number(t, len(report.Skipped()), 1)
+}
+
+func TestWithTenant(t *testing.T) {
+	t.Parallel()
+
+	fsys := fstest.MapFS{
+		"00001_a.sql": {Data: []byte("-- +goose Up\nSELECT 1;\n")},
+	}
+	logger := &bufferLogger{}
+	p := newTestProvider(t, DialectPostgres, fsys, nil, WithLogger(logger), withTenant("acme"))
+	check.Equal(t, p.TableName(), "acme.goose_db_version")
+	_, err := p.Up(context.Background(), WithDryRun())
+	check.NoError(t, err)
+	check.Equal(t, logger.String(), "[acme] PLAN up   00001_a.sql (tx, 1 statements)\n"+
+		"[acme] goose: no migrations to run. current version: 1\n")
+
+	events := &fieldsLogger{}
+	p = newTestProvider(t, DialectPostgres, fsys, nil, WithStructuredLogger(events), WithTableName("migrations"), withTenant("acme"))
+	check.Equal(t, p.TableName(), "acme.migrations")
+	p.log(context.Background(), LevelInfo, "migration applied", "", Field{Key: "version", Value: int64(1)})
+	check.Number(t, len(events.fields), 1)
+	fields := events.fields[0]
+	check.Number(t, len(fields), 2)
+	check.Equal(t, fields[1].Key, "tenant")
+	check.Equal(t, fields[1].Value, "acme")
+}
+
+func TestTenantsContinueOnError(t *testing.T) {
+	tenants := []string{"acme", "globex", "initech"}
+	var connects int32
+	connect := func(ctx context.Context) (Conn, error) {
+		atomic.AddInt32(&connects, 1)
+		return nil, errors.New("connection refused")
+	}
+
+	report, err := UpTenantsContext(context.Background(), connect, ".", tenants)
+	check.HasError(t, err)
+	check.Contains(t, err.Error(), "1 of 3 tenants failed, acme: failed to connect: connection refused")
+	check.Number(t, atomic.LoadInt32(&connects), 1)
+	check.Number(t, len(report.Failed()), 1)
+	check.Number(t, len(report.Skipped()), 2)
+
+	atomic.StoreInt32(&connects, 0)
+	report, err = UpTenantsContext(context.Background(), connect, ".", tenants,
+		WithContinueOnError(),
+		WithTenantConcurrency(2),
+	)
+	check.HasError(t, err)
+	check.Contains(t, err.Error(), "3 of 3 tenants failed")
+	check.Number(t, atomic.LoadInt32(&connects), 3)
+	check.Number(t, len(report.Failed()), 3)
+	check.Number(t, len(report.Skipped()), 0)
+	for _, result := range report.Tenants {
+		check.Number(t, result.Version, -1)
+	}
+
+	_, err = UpTenantsContext(context.Background(), connect, ".", []string{"Acme"})
+	check.HasError(t, err)
+	check.Contains(t, err.Error(), `invalid tenant "Acme"`)
+	_, err = UpTenantsContext(context.Background(), connect, ".", []string{"acme", "acme"})
+	check.HasError(t, err)
+	check.Contains(t, err.Error(), `duplicate tenant "acme"`)
+}
+
+// pristineConn is a Postgres connection to a database without version table,
+// recording the statements it runs.
+type pristineConn struct {
+	statements []string
+}
+
+func (c *pristineConn) Exec(_ context.Context, sql string, _ ...any) (pgconn.CommandTag, error) {
+	c.statements = append(c.statements, sql)
+	if strings.Contains(sql, "goose_db_version") {
+		return pgconn.CommandTag{}, &pgconn.PgError{Code: "42P01", Message: "relation does not exist"}
+	}
+	return pgconn.CommandTag{}, nil
+}
+
+func (c *pristineConn) Query(_ context.Context, sql string, _ ...any) (pgx.Rows, error) {
+	c.statements = append(c.statements, sql)
+	return nil, errors.New("unexpected query")
+}
+
+func (c *pristineConn) QueryRow(_ context.Context, sql string, _ ...any) pgx.Row {
+	c.statements = append(c.statements, sql)
+	return nil
+}
+
+func (c *pristineConn) Begin(context.Context) (pgx.Tx, error) {
+	c.statements = append(c.statements, "BEGIN")
+	return nil, errors.New("unexpected transaction")
+}
+
+func (c *pristineConn) Close(context.Context) error { return nil }
+
+func TestTenantVersionsReadOnly(t *testing.T) {
+	conn := &pristineConn{}
+	connect := func(ctx context.Context) (Conn, error) { return conn, nil }
+
+	report, err := TenantVersionsContext(context.Background(), connect, ".", []string{"acme"})
+	check.NoError(t, err)
+	check.Number(t, report.Tenants[0].Version, 0)
+	// Only the search_path and the version table probes ran.
+	for _, sql := range conn.statements {
+		if !strings.HasPrefix(sql, "SELECT") {
+			t.Errorf("unexpected statement %q", sql)
+		}
+	}
+}
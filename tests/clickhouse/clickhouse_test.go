@@ -1,6 +1,7 @@
 package clickhouse_test
 
 import (
+	"context"
 	"log"
 	"os"
 	"path/filepath"
@@ -91,7 +92,7 @@ func TestClickHouseFirstThree(t *testing.T) {
 		countryCode    string    `db:"country_code"`
 		sourceID       int64     `db:"source_id"`
 	}
-	rows, err := db.Query(`SELECT * FROM clickstream ORDER BY customer_id`)
+	rows, err := db.Query(context.Background(), `SELECT * FROM clickstream ORDER BY customer_id`)
 	check.NoError(t, err)
 	var results []result
 	for rows.Next() {
@@ -101,7 +102,7 @@ func TestClickHouseFirstThree(t *testing.T) {
 		results = append(results, r)
 	}
 	check.Number(t, len(results), 3)
-	check.NoError(t, rows.Close())
+	rows.Close()
 	check.NoError(t, rows.Err())
 
 	parseTime := func(t *testing.T, s string) time.Time {
@@ -151,7 +152,7 @@ func TestRemoteImportMigration(t *testing.T) {
 	check.NoError(t, err)
 
 	var count int
-	err = db.QueryRow(`SELECT COUNT(*) FROM taxi_zone_dictionary`).Scan(&count)
+	err = db.QueryRow(context.Background(), `SELECT COUNT(*) FROM taxi_zone_dictionary`).Scan(&count)
 	check.NoError(t, err)
 	check.Number(t, count, 265)
 }
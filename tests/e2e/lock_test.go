@@ -0,0 +1,111 @@
+package e2e
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/SergeiSkv/goose/v3"
+	"github.com/SergeiSkv/goose/v3/internal/check"
+	"github.com/jackc/pgx/v5"
+)
+
+func TestAdvisoryLock(t *testing.T) {
+	if *dialect != dialectPostgres {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	ctx := context.Background()
+	db, err := newDockerDB(t)
+	check.NoError(t, err)
+	other, err := pgx.ConnectConfig(ctx, db.Config())
+	check.NoError(t, err)
+	t.Cleanup(func() { _ = other.Close(ctx) })
+
+	p, err := goose.NewProvider(goose.DialectPostgres, db, os.DirFS(migrationsDir),
+		goose.WithLockTimeout(2*time.Second),
+	)
+	check.NoError(t, err)
+
+	// Another deployer holds the lock.
+	locker := goose.NewPostgresAdvisoryLocker(p.TableName())
+	check.NoError(t, locker.Lock(ctx, other))
+	_, err = p.Up(ctx)
+	check.IsError(t, err, goose.ErrLockTimeout)
+	version, err := p.GetDBVersion(ctx)
+	check.NoError(t, err)
+	check.Number(t, version, 0)
+
+	check.NoError(t, locker.Unlock(ctx, other))
+	_, err = p.Up(ctx)
+	check.NoError(t, err)
+}
+
+func TestTableLock(t *testing.T) {
+	if *dialect != dialectPostgres {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	ctx := context.Background()
+	db, err := newDockerDB(t)
+	check.NoError(t, err)
+	p, err := goose.NewProvider(goose.DialectPostgres, db, os.DirFS(migrationsDir),
+		goose.WithTableLocker(time.Minute),
+		goose.WithLockTimeout(2*time.Second),
+	)
+	check.NoError(t, err)
+	migrations, err := p.ListSources()
+	check.NoError(t, err)
+
+	// Creates the lock table and releases the lock afterwards.
+	_, err = p.UpTo(ctx, migrations[0].Version)
+	check.NoError(t, err)
+	owner, err := getLockOwner(db, p.TableName())
+	check.NoError(t, err)
+	check.Equal(t, owner, "")
+
+	setLock := func(owner string, expiresAt time.Time) {
+		t.Helper()
+		_, err := db.Exec(ctx,
+			`UPDATE goose_lock SET owner=$1, expires_at=$2 WHERE lock_id=$3`,
+			owner, expiresAt, p.TableName(),
+		)
+		check.NoError(t, err)
+	}
+
+	t.Run("held", func(t *testing.T) {
+		setLock("other", time.Now().Add(time.Hour))
+		_, err := p.Down(ctx)
+		check.IsError(t, err, goose.ErrLockTimeout)
+		owner, err := getLockOwner(db, p.TableName())
+		check.NoError(t, err)
+		check.Equal(t, owner, "other")
+	})
+	t.Run("force unlock", func(t *testing.T) {
+		check.NoError(t, p.ForceUnlock(ctx))
+		results, err := p.Down(ctx)
+		check.NoError(t, err)
+		check.Number(t, len(results), 1)
+	})
+	t.Run("stale takeover", func(t *testing.T) {
+		setLock("crashed", time.Now().Add(-time.Minute))
+		_, err := p.Up(ctx)
+		check.NoError(t, err)
+		owner, err := getLockOwner(db, p.TableName())
+		check.NoError(t, err)
+		check.Equal(t, owner, "")
+	})
+}
+
+func getLockOwner(db *pgx.Conn, gooseTable string) (string, error) {
+	var owner string
+	if err := db.QueryRow(context.Background(),
+		`SELECT owner FROM goose_lock WHERE lock_id=$1`, gooseTable,
+	).Scan(&owner); err != nil {
+		return "", err
+	}
+	return owner, nil
+}
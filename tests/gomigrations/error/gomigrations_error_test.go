@@ -32,7 +32,7 @@ func TestGoMigrationByOne(t *testing.T) {
 	check.NoError(t, err)
 	check.Number(t, version, 1)
 
-	// Registered Go migration run outside a goose tx using*pgx.Conn.
+	// Registered Go migration run outside a goose tx using the goose.DB.
 	err = migrations[1].Up(db)
 	check.HasError(t, err)
 	check.Contains(t, err.Error(), "failed to run go migration")
@@ -5,14 +5,13 @@ import (
 	"fmt"
 
 	"github.com/SergeiSkv/goose/v3"
-	"github.com/jackc/pgx/v5"
 )
 
 func init() {
 	goose.AddMigrationNoTx(up002, nil)
 }
 
-func up002(db *pgx.Conn) error {
+func up002(db goose.DB) error {
 	for i := 1; i <= 100; i++ {
 		q := "INSERT INTO foo VALUES ($1)"
 		if _, err := db.Exec(context.Background(), q, i); err != nil {
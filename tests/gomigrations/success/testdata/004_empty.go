@@ -1,5 +1,9 @@
 package gomigrations
 
+import (
+	"github.com/SergeiSkv/goose/v3"
+)
+
 func init() {
 	goose.AddMigration(nil, nil)
 }
@@ -0,0 +1,306 @@
+// Package sqlite runs goose end to end against the in-process modernc.org/sqlite
+// driver, through database/sql. Unlike tests/e2e, it needs no Docker.
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/SergeiSkv/goose/v3"
+	"github.com/SergeiSkv/goose/v3/internal/check"
+	"github.com/jackc/pgx/v5"
+	_ "modernc.org/sqlite"
+)
+
+const migrationsDir = "testdata/migrations"
+
+// newDB opens a new SQLite database file.
+func newDB(t *testing.T) *sql.DB {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "sqlite.db")
+	db, err := sql.Open("sqlite", "file:"+path+"?_pragma=busy_timeout(5000)&_pragma=foreign_keys(1)")
+	check.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+	return db
+}
+
+// goMigrations are Go migrations following the SQL ones of migrationsDir: the
+// first one runs within a transaction, the second one without.
+func goMigrations() []*goose.Migration {
+	return []*goose.Migration{
+		goose.NewGoMigration("00004_rename_root.go",
+			func(ctx context.Context, tx pgx.Tx) error {
+				_, err := tx.Exec(ctx, "UPDATE users SET username = ? WHERE username = ?", "admin", "root")
+				return err
+			},
+			func(ctx context.Context, tx pgx.Tx) error {
+				_, err := tx.Exec(ctx, "UPDATE users SET username = ? WHERE username = ?", "root", "admin")
+				return err
+			},
+		),
+		goose.NewGoMigrationNoTx("00005_add_post.go",
+			func(ctx context.Context, db goose.DB) error {
+				var id int64
+				if err := db.QueryRow(ctx, "SELECT id FROM users WHERE username = ?", "admin").Scan(&id); err != nil {
+					return err
+				}
+				_, err := db.Exec(ctx, "INSERT INTO posts (user_id, title) VALUES (?, ?)", id, "hello")
+				return err
+			},
+			func(ctx context.Context, db goose.DB) error {
+				_, err := db.Exec(ctx, "DELETE FROM posts WHERE title = ?", "hello")
+				return err
+			},
+		),
+	}
+}
+
+func newProvider(t *testing.T, db *sql.DB, opts ...goose.ProviderOptionsFunc) *goose.Provider {
+	t.Helper()
+	opts = append([]goose.ProviderOptionsFunc{
+		goose.WithDir(migrationsDir),
+		goose.WithLogger(goose.NopLogger()),
+		goose.WithDisableGlobalRegistry(true),
+		goose.WithGoMigrations(goMigrations()...),
+	}, opts...)
+	p, err := goose.NewProvider(goose.DialectSQLite3, goose.NewSQLDB(db), nil, opts...)
+	check.NoError(t, err)
+	return p
+}
+
+func count(t *testing.T, db *sql.DB, query string, args ...any) int {
+	t.Helper()
+	var n int
+	check.NoError(t, db.QueryRow(query, args...).Scan(&n))
+	return n
+}
+
+func tableExists(t *testing.T, db *sql.DB, name string) bool {
+	t.Helper()
+	return count(t, db, "SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?", name) > 0
+}
+
+func TestMigrationCycle(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	db := newDB(t)
+	p := newProvider(t, db)
+
+	results, err := p.Up(ctx)
+	check.NoError(t, err)
+	check.Number(t, len(results), 5)
+	for i, r := range results {
+		check.Number(t, r.Version, i+1)
+		check.NoError(t, r.Error)
+	}
+	check.Bool(t, results[2].UseTx, false)
+	check.Bool(t, results[4].UseTx, false)
+	version, err := p.GetDBVersion(ctx)
+	check.NoError(t, err)
+	check.Number(t, version, 5)
+	check.Number(t, count(t, db, "SELECT COUNT(*) FROM users WHERE username = 'admin'"), 1)
+	check.Number(t, count(t, db, "SELECT COUNT(*) FROM posts"), 1)
+
+	statuses, err := p.ListStatus(ctx)
+	check.NoError(t, err)
+	check.Number(t, len(statuses), 5)
+	for _, s := range statuses {
+		check.Bool(t, s.Applied, true)
+		check.Bool(t, s.AppliedAt.IsZero(), false)
+	}
+	check.Equal(t, statuses[3].Type, goose.TypeGo)
+
+	// Nothing left to run.
+	results, err = p.Up(ctx)
+	check.NoError(t, err)
+	check.Number(t, len(results), 0)
+
+	_, err = p.Redo(ctx)
+	check.NoError(t, err)
+	check.Number(t, count(t, db, "SELECT COUNT(*) FROM posts"), 1)
+
+	_, err = p.DownTo(ctx, 2)
+	check.NoError(t, err)
+	version, err = p.GetDBVersion(ctx)
+	check.NoError(t, err)
+	check.Number(t, version, 2)
+	check.Number(t, count(t, db, "SELECT COUNT(*) FROM users WHERE username = 'root'"), 1)
+	check.Number(t, count(t, db, "SELECT COUNT(*) FROM posts"), 0)
+
+	results, err = p.Reset(ctx)
+	check.NoError(t, err)
+	check.Number(t, len(results), 2)
+	version, err = p.GetDBVersion(ctx)
+	check.NoError(t, err)
+	check.Number(t, version, 0)
+	check.Bool(t, tableExists(t, db, "users"), false)
+	check.Bool(t, tableExists(t, db, "posts"), false)
+}
+
+func TestFailedMigrationRollsBack(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	db := newDB(t)
+	failing := goose.NewGoMigration("00006_fail.go",
+		func(ctx context.Context, tx pgx.Tx) error {
+			if _, err := tx.Exec(ctx, "CREATE TABLE comments (id INTEGER PRIMARY KEY)"); err != nil {
+				return err
+			}
+			// Rejected by the trigger of 00002_create_posts.sql.
+			_, err := tx.Exec(ctx, "INSERT INTO posts (user_id, title) VALUES (1, '')")
+			return err
+		},
+		nil,
+	)
+	p := newProvider(t, db, goose.WithGoMigrations(failing))
+
+	results, err := p.Up(ctx)
+	check.HasError(t, err)
+	check.IsError(t, err, goose.ErrExecution)
+	check.Contains(t, err.Error(), "empty title")
+	check.Number(t, len(results), 6)
+	check.HasError(t, results[5].Error)
+	version, err := p.GetDBVersion(ctx)
+	check.NoError(t, err)
+	check.Number(t, version, 5)
+	check.Bool(t, tableExists(t, db, "comments"), false)
+}
+
+func TestTableLock(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	db := newDB(t)
+	p := newProvider(t, db, goose.WithTableLocker(time.Minute))
+
+	_, err := p.Up(ctx)
+	check.NoError(t, err)
+	var owner string
+	check.NoError(t, db.QueryRow("SELECT owner FROM goose_lock WHERE lock_id = ?", p.TableName()).Scan(&owner))
+	check.Equal(t, owner, "")
+
+	// A lock left behind by a crashed process blocks until the lock timeout.
+	_, err = db.Exec("UPDATE goose_lock SET owner = 'crashed', expires_at = ?", time.Now().Add(time.Hour).UTC())
+	check.NoError(t, err)
+	blocked := newProvider(t, db, goose.WithTableLocker(time.Minute), goose.WithLockTimeout(100*time.Millisecond))
+	_, err = blocked.Down(ctx)
+	check.IsError(t, err, goose.ErrLockTimeout)
+	check.NoError(t, blocked.ForceUnlock(ctx))
+	_, err = blocked.Down(ctx)
+	check.NoError(t, err)
+}
+
+func TestVerify(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	db := newDB(t)
+	fsys := fstest.MapFS{}
+	entries, err := os.ReadDir(migrationsDir)
+	check.NoError(t, err)
+	for _, e := range entries {
+		data, err := os.ReadFile(filepath.Join(migrationsDir, e.Name()))
+		check.NoError(t, err)
+		fsys[e.Name()] = &fstest.MapFile{Data: data}
+	}
+	p, err := goose.NewProvider(goose.DialectSQLite3, goose.NewSQLDB(db), fsys,
+		goose.WithLogger(goose.NopLogger()),
+		goose.WithDisableGlobalRegistry(true),
+	)
+	check.NoError(t, err)
+
+	_, err = p.Up(ctx)
+	check.NoError(t, err)
+	mismatches, err := p.Verify(ctx)
+	check.NoError(t, err)
+	check.Number(t, len(mismatches), 0)
+
+	fsys["00002_create_posts.sql"].Data = append(fsys["00002_create_posts.sql"].Data, "-- edited\n"...)
+	mismatches, err = p.Verify(ctx)
+	check.NoError(t, err)
+	check.Number(t, len(mismatches), 1)
+	check.Number(t, mismatches[0].Version, 2)
+}
+
+// TestPackageFunctions runs the package-level functions on a database opened
+// by OpenDBWithDriver, like the goose binary does. It modifies the
+// package-level state, so it does not run in parallel.
+func TestPackageFunctions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sqlite.db")
+	db, err := goose.OpenDBWithDriver("sqlite", path)
+	check.NoError(t, err)
+	t.Cleanup(func() {
+		check.NoError(t, db.Close(context.Background()))
+		check.NoError(t, goose.SetDialect("postgres"))
+	})
+
+	check.NoError(t, goose.Up(db, migrationsDir))
+	version, err := goose.GetDBVersion(db)
+	check.NoError(t, err)
+	check.Number(t, version, 3)
+	check.NoError(t, goose.Down(db, migrationsDir))
+	version, err = goose.GetDBVersion(db)
+	check.NoError(t, err)
+	check.Number(t, version, 2)
+
+	_, err = goose.OpenDBWithDriver("sqlite-unregistered", path)
+	check.HasError(t, err)
+}
+
+func TestSQLAdapter(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	db := goose.NewSQLDB(newDB(t))
+
+	tag, err := db.Exec(ctx, "CREATE TABLE kv (k TEXT PRIMARY KEY, v TEXT)")
+	check.NoError(t, err)
+	tx, err := db.Begin(ctx)
+	check.NoError(t, err)
+	tag, err = tx.Exec(ctx, "INSERT INTO kv (k, v) VALUES (?, ?), (?, ?)", "a", "1", "b", nil)
+	check.NoError(t, err)
+	check.Number(t, tag.RowsAffected(), 2)
+	check.NoError(t, tx.Commit(ctx))
+	check.IsError(t, tx.Rollback(ctx), pgx.ErrTxClosed)
+
+	rows, err := db.Query(ctx, "SELECT k, v FROM kv ORDER BY k")
+	check.NoError(t, err)
+	check.Equal(t, rows.FieldDescriptions()[1].Name, "v")
+	var got []string
+	for rows.Next() {
+		var k string
+		var v *string
+		check.NoError(t, rows.Scan(&k, &v))
+		if v != nil {
+			k += "=" + *v
+		}
+		got = append(got, k)
+	}
+	rows.Close()
+	check.NoError(t, rows.Err())
+	check.Equal(t, got, []string{"a=1", "b"})
+
+	err = db.QueryRow(ctx, "SELECT v FROM kv WHERE k = ?", "c").Scan(new(string))
+	check.Bool(t, errors.Is(err, sql.ErrNoRows), true)
+
+	tx, err = db.Begin(ctx)
+	check.NoError(t, err)
+	_, err = tx.Begin(ctx)
+	check.HasError(t, err)
+	check.Bool(t, tx.Conn() == nil, true)
+	func() {
+		defer func() {
+			check.Contains(t, recover().(error).Error(), "LargeObjects is not supported")
+		}()
+		tx.LargeObjects()
+	}()
+	check.NoError(t, tx.Rollback(ctx))
+}
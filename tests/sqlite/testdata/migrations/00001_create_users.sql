@@ -0,0 +1,9 @@
+-- +goose Up
+CREATE TABLE users (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    username TEXT NOT NULL UNIQUE
+);
+INSERT INTO users (username) VALUES ('root');
+
+-- +goose Down
+DROP TABLE users;
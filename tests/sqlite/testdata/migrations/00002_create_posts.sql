@@ -0,0 +1,16 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE posts (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL REFERENCES users(id),
+    title TEXT NOT NULL
+);
+CREATE TRIGGER posts_title_not_empty BEFORE INSERT ON posts
+WHEN NEW.title = ''
+BEGIN
+    SELECT RAISE(ABORT, 'empty title');
+END;
+-- +goose StatementEnd
+
+-- +goose Down
+DROP TABLE posts;
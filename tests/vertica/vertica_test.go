@@ -1,6 +1,7 @@
 package vertica_test
 
 import (
+	"context"
 	"path/filepath"
 	"testing"
 	"time"
@@ -51,7 +52,7 @@ func TestVerticaUpDownAll(t *testing.T) {
 		IsCurrent  bool      `db:"is_current"`
 		ExternalID string    `db:"external_id"`
 	}
-	rows, err := db.Query(`SELECT * FROM testing.dim_test_scd ORDER BY test_key`)
+	rows, err := db.Query(context.Background(), `SELECT * FROM testing.dim_test_scd ORDER BY test_key`)
 	check.NoError(t, err)
 	var results []result
 	for rows.Next() {
@@ -61,7 +62,7 @@ func TestVerticaUpDownAll(t *testing.T) {
 		results = append(results, r)
 	}
 	check.Number(t, len(results), 3)
-	check.NoError(t, rows.Close())
+	rows.Close()
 	check.NoError(t, rows.Err())
 
 	parseTime := func(t *testing.T, s string) time.Time {
@@ -0,0 +1,129 @@
+package goose
+
+import (
+	"context"
+	"path/filepath"
+
+	"github.com/SergeiSkv/goose/v3/internal/sqlparser"
+)
+
+// Tracer starts the spans goose reports, such as those of an OpenTelemetry
+// tracer, without goose depending on a tracing SDK.
+//
+// Each command (up, down, redo, baseline, status, version, verify, ...) is
+// reported as a span named "goose <command>", with a child span named
+// "goose migration" for each migration it runs. With WithStatementSpans, each
+// statement of a SQL migration is reported as a child span of its migration,
+// named "goose statement". Spans of failed commands, migrations and
+// statements record the error.
+type Tracer interface {
+	// Start starts a span with the given name and attributes, as a child of
+	// the span in ctx, if any. The returned context holds the new span.
+	Start(ctx context.Context, name string, attrs ...Attribute) (context.Context, Span)
+}
+
+// Span is a span started by a Tracer.
+type Span interface {
+	// SetAttributes sets attributes of the span.
+	SetAttributes(attrs ...Attribute)
+	// RecordError records err and marks the span as failed.
+	RecordError(err error)
+	// End ends the span.
+	End()
+}
+
+// Attribute is a span attribute. Value is a string, an int64 or a bool.
+//
+// Command spans have the goose.command, goose.table and goose.dry_run
+// attributes. Migration spans have the goose.version, goose.source,
+// goose.direction and goose.tx attributes. Statement spans have the
+// db.statement attribute, with the values of secret variables masked.
+type Attribute struct {
+	Key   string
+	Value interface{}
+}
+
+// tracingOptions configures the spans of a Provider.
+type tracingOptions struct {
+	tracer         Tracer
+	statementSpans bool
+}
+
+// SetTracer sets the Tracer used by the package-level functions.
+func SetTracer(t Tracer) {
+	WithTracer(t)(&defaultOptions)
+}
+
+// SetStatementSpans enables statement spans for the package-level functions,
+// see WithStatementSpans.
+func SetStatementSpans(b bool) {
+	WithStatementSpans(b)(&defaultOptions)
+}
+
+// WithTracer reports commands and migrations as spans of t, see Tracer. By
+// default nothing is traced.
+func WithTracer(t Tracer) ProviderOptionsFunc {
+	return func(o *providerOptions) { o.tracing.tracer = t }
+}
+
+// WithStatementSpans also reports each statement of SQL migrations as a span,
+// see Tracer.
+func WithStatementSpans(b bool) ProviderOptionsFunc {
+	return func(o *providerOptions) { o.tracing.statementSpans = b }
+}
+
+// traceCommand runs fn, the body of command name, within the span of the
+// command.
+func (p *Provider) traceCommand(ctx context.Context, name string, option *options, fn func(ctx context.Context) error) error {
+	ctx, span := p.startSpan(ctx, "goose "+name,
+		Attribute{Key: "goose.command", Value: name},
+		Attribute{Key: "goose.table", Value: p.tableName},
+		Attribute{Key: "goose.dry_run", Value: option.dryRun},
+	)
+	err := fn(ctx)
+	endSpan(span, err)
+	return err
+}
+
+// startMigrationSpan starts the span of migration m.
+func (p *Provider) startMigrationSpan(ctx context.Context, m *Migration, direction bool) (context.Context, Span) {
+	return p.startSpan(ctx, "goose migration",
+		Attribute{Key: "goose.version", Value: m.Version},
+		Attribute{Key: "goose.source", Value: filepath.Base(m.Source)},
+		Attribute{Key: "goose.direction", Value: string(sqlparser.FromBool(direction))},
+	)
+}
+
+// traceStatement runs fn, which executes query, within a statement span if
+// they are enabled.
+func (p *Provider) traceStatement(ctx context.Context, parsed *sqlparser.Parsed, query string, fn func(ctx context.Context) error) error {
+	if !p.tracing.statementSpans {
+		return fn(ctx)
+	}
+	ctx, span := p.startSpan(ctx, "goose statement",
+		Attribute{Key: "db.statement", Value: clearStatement(parsed.Mask(query))},
+	)
+	err := fn(ctx)
+	endSpan(span, err)
+	return err
+}
+
+func (p *Provider) startSpan(ctx context.Context, name string, attrs ...Attribute) (context.Context, Span) {
+	if p.tracing.tracer == nil {
+		return ctx, nopSpan{}
+	}
+	return p.tracing.tracer.Start(ctx, name, attrs...)
+}
+
+func endSpan(span Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+	}
+	span.End()
+}
+
+type nopSpan struct{}
+
+func (nopSpan) SetAttributes(...Attribute) {}
+func (nopSpan) RecordError(error)          {}
+func (nopSpan) End()                       {}
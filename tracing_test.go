@@ -0,0 +1,140 @@
+package goose
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"testing/fstest"
+
+	"github.com/SergeiSkv/goose/v3/internal/check"
+	"github.com/SergeiSkv/goose/v3/internal/dialect"
+	"github.com/SergeiSkv/goose/v3/internal/sqlparser"
+)
+
+// memoryTracer records ended spans in memory.
+type memoryTracer struct {
+	mu    sync.Mutex
+	spans []*memorySpan
+}
+
+type memorySpan struct {
+	tracer *memoryTracer
+	name   string
+	parent string
+	attrs  map[string]interface{}
+	err    error
+}
+
+type spanKey struct{}
+
+func (t *memoryTracer) Start(ctx context.Context, name string, attrs ...Attribute) (context.Context, Span) {
+	span := &memorySpan{tracer: t, name: name, attrs: make(map[string]interface{})}
+	if parent, ok := ctx.Value(spanKey{}).(*memorySpan); ok {
+		span.parent = parent.name
+	}
+	span.SetAttributes(attrs...)
+	return context.WithValue(ctx, spanKey{}, span), span
+}
+
+func (s *memorySpan) SetAttributes(attrs ...Attribute) {
+	for _, a := range attrs {
+		s.attrs[a.Key] = a.Value
+	}
+}
+
+func (s *memorySpan) RecordError(err error) { s.err = err }
+
+func (s *memorySpan) End() {
+	s.tracer.mu.Lock()
+	defer s.tracer.mu.Unlock()
+	s.tracer.spans = append(s.tracer.spans, s)
+}
+
+func TestTracer(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	fsys := fstest.MapFS{
+		"00001_a.sql": {Data: []byte("-- +goose Up\nSELECT 1;\n-- +goose Down\nSELECT 1;\n")},
+		"00002_b.sql": {Data: []byte("-- +goose NO TRANSACTION\n-- +goose Up\nSELECT 2;\n-- +goose Down\nSELECT 2;\n")},
+		"00003_c.sql": {Data: []byte("-- +goose Up\nSELECT 3;\n")},
+	}
+	newProvider := func(t *testing.T, tracer Tracer, versions []*dialect.ListMigrationsResult) *Provider {
+		t.Helper()
+		return newTestProvider(t, DialectPostgres, fsys, versions, WithTracer(tracer), WithStatementSpans(true))
+	}
+
+	t.Run("command and migrations", func(t *testing.T) {
+		tracer := &memoryTracer{}
+		_, err := newProvider(t, tracer, nil).UpTo(ctx, 2, WithDryRun())
+		check.NoError(t, err)
+		check.Number(t, len(tracer.spans), 3)
+		for i, version := range []int64{1, 2} {
+			span := tracer.spans[i]
+			check.Equal(t, span.name, "goose migration")
+			check.Equal(t, span.parent, "goose up-to")
+			check.Equal(t, span.attrs["goose.version"], version)
+			check.Equal(t, span.attrs["goose.direction"], "up")
+			check.Equal(t, span.attrs["goose.tx"], version == 1)
+			check.NoError(t, span.err)
+		}
+		check.Equal(t, tracer.spans[0].attrs["goose.source"], "00001_a.sql")
+		command := tracer.spans[2]
+		check.Equal(t, command.name, "goose up-to")
+		check.Equal(t, command.parent, "")
+		check.Equal(t, command.attrs["goose.command"], "up-to")
+		check.Equal(t, command.attrs["goose.dry_run"], true)
+	})
+	t.Run("failed command", func(t *testing.T) {
+		tracer := &memoryTracer{}
+		_, err := newProvider(t, tracer, []*dialect.ListMigrationsResult{
+			{VersionID: 2, IsApplied: true},
+			{VersionID: 0, IsApplied: true},
+		}).Up(ctx, WithDryRun())
+		check.HasError(t, err)
+		check.Number(t, len(tracer.spans), 1)
+		check.Equal(t, tracer.spans[0].name, "goose up")
+		check.HasError(t, tracer.spans[0].err)
+		check.Contains(t, tracer.spans[0].err.Error(), "missing migrations")
+	})
+	t.Run("read-only commands", func(t *testing.T) {
+		tracer := &memoryTracer{}
+		p := newProvider(t, tracer, []*dialect.ListMigrationsResult{
+			{VersionID: 1, IsApplied: true},
+			{VersionID: 0, IsApplied: true},
+		})
+		check.NoError(t, p.Status(ctx, WithNoVersioning()))
+		_, err := p.ListStatus(ctx, WithNoVersioning())
+		check.NoError(t, err)
+		check.NoError(t, p.Version(ctx))
+		_, err = p.Verify(ctx)
+		check.NoError(t, err)
+		var names []string
+		for _, span := range tracer.spans {
+			check.Equal(t, span.parent, "")
+			check.NoError(t, span.err)
+			names = append(names, span.name)
+		}
+		check.Equal(t, names, []string{"goose status", "goose status", "goose version", "goose verify"})
+	})
+	t.Run("statements", func(t *testing.T) {
+		tracer := &memoryTracer{}
+		p := newProvider(t, tracer, nil)
+		parsed, err := sqlparser.Parse(strings.NewReader("-- +goose Up\n-- +goose ENVSUB ON\nCREATE ROLE app PASSWORD '${APP_PASSWORD}';\n"),
+			DirectionUp, false, sqlparser.EnvMap(map[string]string{"APP_PASSWORD": "hunter2"}))
+		check.NoError(t, err)
+		ctx, span := p.startSpan(ctx, "goose migration")
+		want := errors.New("permission denied")
+		err = p.traceStatement(ctx, parsed, parsed.Statements[0], func(context.Context) error { return want })
+		check.Bool(t, errors.Is(err, want), true)
+		span.End()
+		check.Number(t, len(tracer.spans), 2)
+		statement := tracer.spans[0]
+		check.Equal(t, statement.name, "goose statement")
+		check.Equal(t, statement.parent, "goose migration")
+		check.Equal(t, statement.attrs["db.statement"], "CREATE ROLE app PASSWORD '******';")
+		check.Bool(t, errors.Is(statement.err, want), true)
+	})
+}
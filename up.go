@@ -4,16 +4,31 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"sort"
 	"strings"
 
-	"github.com/jackc/pgx/v5"
+	"github.com/SergeiSkv/goose/v3/internal/dialect"
 )
 
 type options struct {
 	allowMissing bool
 	applyUpByOne bool
 	noVersioning bool
+	noColor      bool
+	dryRun       bool
+	script       io.Writer
+
+	strictChecksums bool
+
+	// dryRunVersions is the version table as seen by a dry run, see
+	// listMigrations.
+	dryRunVersions []*dialect.ListMigrationsResult
+
+	// failed is the migration the command failed on, passed to the OnError
+	// hooks.
+	failed          *Migration
+	failedDirection Direction
 }
 
 type OptionsFunc func(o *options)
@@ -27,7 +42,7 @@ func WithNoVersioning() OptionsFunc {
 }
 
 func WithNoColor(b bool) OptionsFunc {
-	return func(o *options) { noColor = b }
+	return func(o *options) { o.noColor = b }
 }
 
 func withApplyUpByOne() OptionsFunc {
@@ -35,35 +50,115 @@ func withApplyUpByOne() OptionsFunc {
 }
 
 // UpTo migrates up to a specific version.
-func UpTo(db *pgx.Conn, dir string, version int64, opts ...OptionsFunc) error {
-	ctx := context.Background()
-	option := &options{}
-	for _, f := range opts {
-		f(option)
-	}
-	foundMigrations, err := CollectMigrations(dir, minVersion, version)
+func UpTo(db DB, dir string, version int64, opts ...OptionsFunc) error {
+	return UpToContext(context.Background(), db, dir, version, opts...)
+}
+
+// UpToContext migrates up to a specific version.
+func UpToContext(ctx context.Context, db DB, dir string, version int64, opts ...OptionsFunc) error {
+	p, err := newGlobalProvider(db, dir)
 	if err != nil {
 		return err
 	}
+	_, err = p.UpTo(ctx, version, opts...)
+	return err
+}
+
+// Up applies all available migrations.
+func Up(db DB, dir string, opts ...OptionsFunc) error {
+	return UpToContext(context.Background(), db, dir, maxVersion, opts...)
+}
+
+// UpContext applies all available migrations.
+func UpContext(ctx context.Context, db DB, dir string, opts ...OptionsFunc) error {
+	return UpToContext(ctx, db, dir, maxVersion, opts...)
+}
+
+// UpByOne migrates up by a single version.
+func UpByOne(db DB, dir string, opts ...OptionsFunc) error {
+	return UpByOneContext(context.Background(), db, dir, opts...)
+}
+
+// UpByOneContext migrates up by a single version.
+func UpByOneContext(ctx context.Context, db DB, dir string, opts ...OptionsFunc) error {
+	opts = append(opts, withApplyUpByOne())
+	return UpToContext(ctx, db, dir, maxVersion, opts...)
+}
+
+// Up applies all available migrations and returns the result of each
+// migration that was run.
+func (p *Provider) Up(ctx context.Context, opts ...OptionsFunc) ([]*MigrationResult, error) {
+	return p.UpTo(ctx, maxVersion, opts...)
+}
+
+// UpByOne migrates up by a single version.
+func (p *Provider) UpByOne(ctx context.Context, opts ...OptionsFunc) ([]*MigrationResult, error) {
+	opts = append(opts, withApplyUpByOne())
+	return p.UpTo(ctx, maxVersion, opts...)
+}
+
+// UpTo migrates up to a specific version and returns the result of each
+// migration that was run. On failure, the results include the failed
+// migration.
+func (p *Provider) UpTo(ctx context.Context, version int64, opts ...OptionsFunc) ([]*MigrationResult, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	option := p.applyOptions(opts)
+	name := "up-to"
+	switch {
+	case option.applyUpByOne:
+		name = "up-by-one"
+	case version == maxVersion:
+		name = "up"
+	}
+	return p.runCommand(ctx, name, option, func(ctx context.Context) ([]*MigrationResult, error) {
+		// Repeatable migrations run once every versioned migration has
+		// been applied, but are collected first so that an invalid one
+		// fails before any migration runs.
+		withRepeatable := version == maxVersion && !option.applyUpByOne
+		var repeatable Migrations
+		if withRepeatable {
+			var err error
+			if repeatable, err = p.collectRepeatable(); err != nil {
+				return nil, fmt.Errorf("failed to collect repeatable migrations: %w", err)
+			}
+		}
+		results, err := p.upTo(ctx, version, option)
+		if err != nil || !withRepeatable {
+			return results, err
+		}
+		repeatableResults, err := p.upRepeatable(ctx, repeatable, option)
+		return append(results, repeatableResults...), err
+	})
+}
+
+func (p *Provider) upTo(ctx context.Context, version int64, option *options) ([]*MigrationResult, error) {
+	foundMigrations, err := p.collectMigrations(minVersion, version)
+	if err != nil {
+		return nil, err
+	}
 
 	if option.noVersioning {
 		if len(foundMigrations) == 0 {
-			return nil
+			return nil, nil
 		}
 		if option.applyUpByOne {
 			// For up-by-one this means keep re-applying the first
 			// migration over and over.
 			version = foundMigrations[0].Version
 		}
-		return upToNoVersioning(db, foundMigrations, version)
+		return p.upToNoVersioning(ctx, foundMigrations, version, option)
 	}
 
-	if _, err := EnsureDBVersion(db); err != nil {
-		return err
+	if _, err := p.ensureDBVersion(ctx, option); err != nil {
+		return nil, err
 	}
-	dbMigrations, err := listAllDBVersions(ctx, db)
+	if err := p.checkChecksums(ctx, foundMigrations, option); err != nil {
+		return nil, err
+	}
+	dbMigrations, err := p.listAllDBVersions(ctx, option)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	missingMigrations := findMissingMigrations(dbMigrations, foundMigrations)
@@ -77,13 +172,13 @@ func UpTo(db *pgx.Conn, dir string, version int64, opts ...OptionsFunc) error {
 			output := fmt.Sprintf("version %d: %s", m.Version, m.Source)
 			collected = append(collected, output)
 		}
-		return fmt.Errorf("error: found %d missing migrations:\n\t%s",
+		return nil, fmt.Errorf("error: found %d missing migrations:\n\t%s",
 			len(missingMigrations), strings.Join(collected, "\n\t"))
 	}
 
 	if option.allowMissing {
-		return upWithMissing(
-			db,
+		return p.upWithMissing(
+			ctx,
 			missingMigrations,
 			foundMigrations,
 			dbMigrations,
@@ -91,90 +186,102 @@ func UpTo(db *pgx.Conn, dir string, version int64, opts ...OptionsFunc) error {
 		)
 	}
 
-	var current int64
+	var (
+		current int64
+		results []*MigrationResult
+	)
 	for {
 		var err error
-		current, err = GetDBVersion(db)
+		current, err = p.getDBVersion(ctx, option)
 		if err != nil {
-			return err
+			return results, err
 		}
 		next, err := foundMigrations.Next(current)
 		if err != nil {
 			if errors.Is(err, ErrNoNextVersion) {
 				break
 			}
-			return fmt.Errorf("failed to find next migration: %v", err)
+			return results, fmt.Errorf("failed to find next migration: %v", err)
 		}
-		if err := next.Up(db); err != nil {
-			return err
+		result, err := p.runMigration(ctx, next, true, option)
+		results = append(results, result)
+		if err != nil {
+			return results, err
 		}
 		if option.applyUpByOne {
-			return nil
+			return results, nil
 		}
 	}
 	// At this point there are no more migrations to apply. But we need to maintain
 	// the following behaviour:
 	// UpByOne returns an error to signifying there are no more migrations.
 	// Up and UpTo return nil
-	log.Printf("goose: no migrations to run. current version: %d\n", current)
+	p.log(ctx, LevelInfo, "no migrations to run", "goose: no migrations to run. current version: %d\n", Field{Key: "current_version", Value: current})
 	if option.applyUpByOne {
-		return ErrNoNextVersion
+		return results, ErrNoNextVersion
 	}
-	return nil
+	return results, nil
 }
 
 // upToNoVersioning applies up migrations up to, and including, the
 // target version.
-func upToNoVersioning(db *pgx.Conn, migrations Migrations, version int64) error {
-	var finalVersion int64
+func (p *Provider) upToNoVersioning(ctx context.Context, migrations Migrations, version int64, option *options) ([]*MigrationResult, error) {
+	var (
+		finalVersion int64
+		results      []*MigrationResult
+	)
 	for _, current := range migrations {
 		if current.Version > version {
 			break
 		}
-		current.noVersioning = true
-		if err := current.Up(db); err != nil {
-			return err
+		result, err := p.runMigration(ctx, current, true, option)
+		results = append(results, result)
+		if err != nil {
+			return results, err
 		}
 		finalVersion = current.Version
 	}
-	log.Printf("goose: up to current file version: %d\n", finalVersion)
-	return nil
+	p.log(ctx, LevelInfo, "up to current file version", "goose: up to current file version: %d\n", Field{Key: "current_version", Value: finalVersion})
+	return results, nil
 }
 
-func upWithMissing(
-	db *pgx.Conn,
+func (p *Provider) upWithMissing(
+	ctx context.Context,
 	missingMigrations Migrations,
 	foundMigrations Migrations,
 	dbMigrations Migrations,
 	option *options,
-) error {
+) ([]*MigrationResult, error) {
 	lookupApplied := make(map[int64]bool)
 	for _, found := range dbMigrations {
 		lookupApplied[found.Version] = true
 	}
 
+	var results []*MigrationResult
 	// Apply all missing migrations first.
 	for _, missing := range missingMigrations {
-		if err := missing.Up(db); err != nil {
-			return err
+		result, err := p.runMigration(ctx, missing, true, option)
+		results = append(results, result)
+		if err != nil {
+			return results, err
 		}
 		// Apply one migration and return early.
 		if option.applyUpByOne {
-			return nil
+			return results, nil
 		}
 		// TODO(mf): do we need this check? It's a bit redundant, but we may
 		// want to keep it as a safe-guard. Maybe we should instead have
 		// the underlying query (if possible) return the current version as
 		// part of the same transaction.
-		current, err := GetDBVersion(db)
+		current, err := p.getDBVersion(ctx, option)
 		if err != nil {
-			return err
+			return results, err
 		}
 		if current == missing.Version {
 			lookupApplied[missing.Version] = true
 			continue
 		}
-		return fmt.Errorf("error: missing migration:%d does not match current db version:%d",
+		return results, fmt.Errorf("error: missing migration:%d does not match current db version:%d",
 			current, missing.Version)
 	}
 
@@ -190,43 +297,34 @@ func upWithMissing(
 		if lookupApplied[found.Version] {
 			continue
 		}
-		if err := found.Up(db); err != nil {
-			return err
+		result, err := p.runMigration(ctx, found, true, option)
+		results = append(results, result)
+		if err != nil {
+			return results, err
 		}
 		if option.applyUpByOne {
-			return nil
+			return results, nil
 		}
 	}
-	current, err := GetDBVersion(db)
+	current, err := p.getDBVersion(ctx, option)
 	if err != nil {
-		return err
+		return results, err
 	}
 	// At this point there are no more migrations to apply. But we need to maintain
 	// the following behaviour:
 	// UpByOne returns an error to signifying there are no more migrations.
 	// Up and UpTo return nil
-	log.Printf("goose: no migrations to run. current version: %d\n", current)
+	p.log(ctx, LevelInfo, "no migrations to run", "goose: no migrations to run. current version: %d\n", Field{Key: "current_version", Value: current})
 	if option.applyUpByOne {
-		return ErrNoNextVersion
+		return results, ErrNoNextVersion
 	}
-	return nil
-}
-
-// Up applies all available migrations.
-func Up(db *pgx.Conn, dir string, opts ...OptionsFunc) error {
-	return UpTo(db, dir, maxVersion, opts...)
-}
-
-// UpByOne migrates up by a single version.
-func UpByOne(db *pgx.Conn, dir string, opts ...OptionsFunc) error {
-	opts = append(opts, withApplyUpByOne())
-	return UpTo(db, dir, maxVersion, opts...)
+	return results, nil
 }
 
 // listAllDBVersions returns a list of all migrations, ordered ascending.
 // TODO(mf): fairly cheap, but a nice-to-have is pagination support.
-func listAllDBVersions(ctx context.Context, db *pgx.Conn) (Migrations, error) {
-	dbMigrations, err := store.ListMigrations(ctx, db)
+func (p *Provider) listAllDBVersions(ctx context.Context, option *options) (Migrations, error) {
+	dbMigrations, err := p.listMigrations(ctx, option)
 	if err != nil {
 		return nil, err
 	}
@@ -1,39 +1,60 @@
 package goose
 
 import (
+	"context"
 	"fmt"
-
-	"github.com/jackc/pgx/v5"
 )
 
 // Version prints the current version of the database.
-func Version(db *pgx.Conn, dir string, opts ...OptionsFunc) error {
-	option := &options{}
-	for _, f := range opts {
-		f(option)
+func Version(db DB, dir string, opts ...OptionsFunc) error {
+	return VersionContext(context.Background(), db, dir, opts...)
+}
+
+// VersionContext prints the current version of the database.
+func VersionContext(ctx context.Context, db DB, dir string, opts ...OptionsFunc) error {
+	p, err := newGlobalProvider(db, dir)
+	if err != nil {
+		return err
 	}
+	return p.Version(ctx, opts...)
+}
+
+// Version prints the current version of the database.
+func (p *Provider) Version(ctx context.Context, opts ...OptionsFunc) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	option := p.applyOptions(opts)
+	return p.traceCommand(ctx, "version", option, func(ctx context.Context) error {
+		return p.version(ctx, option)
+	})
+}
+
+func (p *Provider) version(ctx context.Context, option *options) error {
 	if option.noVersioning {
 		var current int64
-		migrations, err := CollectMigrations(dir, minVersion, maxVersion)
+		migrations, err := p.collectMigrations(minVersion, maxVersion)
 		if err != nil {
 			return fmt.Errorf("failed to collect migrations: %w", err)
 		}
 		if len(migrations) > 0 {
 			current = migrations[len(migrations)-1].Version
 		}
-		log.Printf("goose: file version %v\n", current)
+		p.log(ctx, LevelInfo, "file version", "goose: file version %v\n", Field{Key: "version", Value: current})
 		return nil
 	}
 
-	current, err := GetDBVersion(db)
+	current, err := p.readDBVersion(ctx)
 	if err != nil {
 		return err
 	}
-	log.Printf("goose: version %v\n", current)
+	p.log(ctx, LevelInfo, "version", "goose: version %v\n", Field{Key: "version", Value: current})
 	return nil
 }
 
-var tableName = "goose_db_version"
+const defaultTableName = "goose_db_version"
+
+var tableName = defaultTableName
 
 // TableName returns goose db version table name
 func TableName() string {